@@ -1,8 +1,13 @@
 package amazon
 
 const (
-	// This values Eventually can be obtained from config
-	// or the client as appropriate.
-	// Fixed value at the moment for the sake of simplicity (PoC).
-	region = "eu-west-1"
+	// defRegion is used when no region is
+	// provided in the provider config.
+	defRegion = "eu-west-1"
+	// maxTagLen is the max length of SES
+	// message tag names and values.
+	maxTagLen = 256
+	// requestIDTag is the message tag that
+	// carries poslan request ID.
+	requestIDTag = "poslan_request_id"
 )
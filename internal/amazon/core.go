@@ -11,41 +11,71 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 
 	//go get -u github.com/aws/aws-sdk-go
 	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/rfc822"
 	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ses"
 )
 
 // SESProvider is an Amazon SES delivery provider.
 type SESProvider struct {
-	ctx      context.Context
-	cfg      *config.Config
-	logger   log.Logger
-	client   *ses.SES
-	name     string
-	priority int
+	ctx       context.Context
+	cfg       *config.Config
+	logger    log.Logger
+	client    *ses.SES
+	name      string
+	priority  int
+	configSet string
 }
 
 // Init amazon SES mail server handler.
-func Init(ctx context.Context, cfg *config.Config, log log.Logger) (*SESProvider, error) {
+// If name is provided the provider is built from the
+// Amazon SES provider config that matches it,
+// otherwise the first one of its type is used.
+func Init(ctx context.Context, cfg *config.Config, log log.Logger, name ...string) (*SESProvider, error) {
 	ok := cfg.HasProviderType(config.ProviderType.AmazonSES)
 	if !ok {
 		return nil, errors.New("no config associated to an Amazon SES provider")
 	}
-	return newProvider(ctx, cfg, log)
+	return newProvider(ctx, cfg, log, name...)
 }
 
 // Send an email.
+// SES has no personalizations support so an
+// individual message is sent for each one of them.
 func (p *SESProvider) Send(em *model.Email) (resend bool, err error) {
-	email := newSESEmail(em.From, em.To, em.CC, em.BCC, em.Subject, em.Body, em.Charset)
-	result, err := p.client.SendEmail(email)
+	var sent []int
+	for i, e := range em.Expand() {
+		resend, err = p.send(e)
+		if err != nil {
+			// Report the sent ones so that only the rest is resent.
+			return resend, model.Partial(sent, err)
+		}
+		sent = append(sent, i)
+	}
+	return false, nil
+}
+
+func (p *SESProvider) send(em *model.Email) (resend bool, err error) {
+	email, err := newSESRawEmail(em, p.configSet)
+	if err != nil {
+		// Malformed message, resending it will not help.
+		return false, fmt.Errorf("cannot compose the email: %s", err.Error())
+	}
+
+	result, err := p.client.SendRawEmail(email)
 
 	// Actually, all error cases are solved in the same way.
 	// In case that, eventually, it is not required to modify
@@ -80,84 +110,192 @@ func (p *SESProvider) Send(em *model.Email) (resend bool, err error) {
 		return true, fmt.Errorf("cannot send the email: %s", err.Error())
 	}
 
-	p.logger.Log(
-		"level", config.LogLevel.Info,
+	level.Info(p.logger).Log(
 		"package", "amazon",
-		"method", "Send",
+		"method", "send",
 		"result", result.GoString(),
 	)
 
 	return false, nil
 }
 
-func newSESEmail(from, to, cc, bcc, subject, body, charset string) *ses.SendEmailInput {
-	// Assemble the email.
-	email := &ses.SendEmailInput{
-		Destination: &ses.Destination{
-			BccAddresses: []*string{
-				aws.String(bcc),
-			},
-			CcAddresses: []*string{
-				aws.String(cc),
-			},
-			ToAddresses: []*string{
-				aws.String(to),
-			},
-		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Text: &ses.Content{
-					Charset: aws.String(charset),
-					Data:    aws.String(body),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: aws.String(charset),
-				Data:    aws.String(subject),
-			},
+// newSESRawEmail assembles a raw email so that
+// custom headers (i.e.: Reply-To, List-Unsubscribe, X-*) can be set.
+// Email metadata is attached as SES message tags.
+func newSESRawEmail(em *model.Email, configSet string) (*ses.SendRawEmailInput, error) {
+	data, err := rfc822.Compose(em)
+	if err != nil {
+		return nil, err
+	}
+
+	rcpts, err := rfc822.Recipients(em)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bcc recipients are not included in message headers
+	// so destinations must be explicitly set.
+	email := &ses.SendRawEmailInput{
+		Destinations: aws.StringSlice(rcpts),
+		RawMessage: &ses.RawMessage{
+			Data: data,
 		},
-		Source: aws.String(from),
+		Source: aws.String(em.From),
+		Tags:   messageTags(em),
+	}
+
+	if configSet != "" {
+		email.ConfigurationSetName = aws.String(configSet)
 	}
 
-	return email
+	return email, nil
 }
 
-func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger) (*SESProvider, error) {
+// messageTags converts email metadata and request ID into SES message tags.
+// SES only accepts alphanumeric ASCII characters, '_' and '-'
+// in tag names and values, any other char is replaced by '_'.
+// SES also rejects repeated tag names so a numeric suffix is
+// appended to names already taken by another key.
+func messageTags(em *model.Email) []*ses.MessageTag {
+	md := make(map[string]string, len(em.Metadata)+1)
+	for k, v := range em.Metadata {
+		md[k] = v
+	}
+	if em.RequestID != "" {
+		md[requestIDTag] = em.RequestID
+	}
+
+	if len(md) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(md))
+	for k := range md {
+		names = append(names, k)
+	}
+	// Keys that are valid tag names go first
+	// so that they are kept as they are.
+	sort.Slice(names, func(i, j int) bool {
+		vi, vj := tagValue(names[i]) == names[i], tagValue(names[j]) == names[j]
+		if vi != vj {
+			return vi
+		}
+		return names[i] < names[j]
+	})
+
+	tags := make([]*ses.MessageTag, 0, len(md))
+	taken := make(map[string]bool, len(md))
+	for _, k := range names {
+		name := tagValue(k)
+		if name == "" {
+			continue
+		}
+
+		for i := 2; taken[name]; i++ {
+			suffix := fmt.Sprintf("_%d", i)
+			name = tagValue(k)
+			if len(name)+len(suffix) > maxTagLen {
+				name = name[:maxTagLen-len(suffix)]
+			}
+			name += suffix
+		}
+		taken[name] = true
+
+		tags = append(tags, &ses.MessageTag{
+			Name:  aws.String(name),
+			Value: aws.String(tagValue(md[k])),
+		})
+	}
+
+	return tags
+}
+
+func tagValue(s string) string {
+	if len(s) > maxTagLen {
+		s = s[:maxTagLen]
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger, name ...string) (*SESProvider, error) {
 	// Create an AmazonSESS session.
-	p, ok := cfg.Provider(config.ProviderType.AmazonSES)
+	p, ok := cfg.Provider(config.ProviderType.AmazonSES, name...)
 	if !ok {
 		return nil, fmt.Errorf("no provider of type '%s' in config", config.ProviderType.AmazonSES)
 	}
 
-	// Create a new session in the us-west-2 region.
-	// Replace us-west-2 with the AWS Region you're using for Amazon SES.
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region)},
-	)
-
+	sess, err := session.NewSession(awsConfig(p))
 	if err != nil {
 		return nil, err
 	}
 
 	// Create a SES session.
-	clt := ses.New(sess)
+	clt := ses.New(sess, sesConfig(sess, p))
 
 	return &SESProvider{
-		ctx:      ctx,
-		cfg:      cfg,
-		logger:   logger,
-		client:   clt,
-		name:     p.Name,
-		priority: p.Priority,
+		ctx:       ctx,
+		cfg:       cfg,
+		logger:    logger,
+		client:    clt,
+		name:      p.Name,
+		priority:  p.Priority,
+		configSet: p.ConfigurationSet,
 	}, nil
 }
 
+// awsConfig builds the AWS session config from provider config.
+// Region defaults to defRegion, static credentials are only used
+// if both keys are provided, otherwise the default AWS credentials
+// chain (envvars, shared credentials, instance role) applies.
+func awsConfig(p *config.ProviderConfig) *aws.Config {
+	region := p.Region
+	if region == "" {
+		region = defRegion
+	}
+
+	c := &aws.Config{
+		Region: aws.String(region),
+	}
+
+	if p.IDKey != "" && p.APIKey != "" {
+		c.Credentials = credentials.NewStaticCredentials(p.IDKey, p.APIKey, "")
+	}
+
+	return c
+}
+
+// sesConfig builds the SES client config from provider config.
+// Credentials of an assumed role take precedence over the
+// static or ambient ones of the session used to assume it.
+// Endpoint is only set here so that the STS requests
+// made through the session still reach AWS.
+func sesConfig(sess *session.Session, p *config.ProviderConfig) *aws.Config {
+	c := &aws.Config{}
+
+	if p.RoleARN != "" {
+		c.Credentials = stscreds.NewCredentials(sess, p.RoleARN)
+	}
+
+	if p.Endpoint != "" {
+		c.Endpoint = aws.String(p.Endpoint)
+	}
+
+	return c
+}
+
 // Name return the provider name.
 func (p *SESProvider) Name() string {
 	return p.name
 }
 
-// Priority return the provider priorit
+// Priority returns the provider priority.
 func (p *SESProvider) Priority() int {
 	return p.priority
 }
@@ -0,0 +1,221 @@
+package amazon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+const (
+	testIDKey  = "AKIDTEST"
+	testAPIKey = "secret-test"
+)
+
+// TestAWSConfig checks region and credentials selection.
+func TestAWSConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		pc     config.ProviderConfig
+		region string
+		static bool
+	}{
+		{"default region", config.ProviderConfig{}, defRegion, false},
+		{"region", config.ProviderConfig{Region: "us-east-1"}, "us-east-1", false},
+		{"static credentials", config.ProviderConfig{IDKey: testIDKey, APIKey: testAPIKey}, defRegion, true},
+		{"incomplete credentials", config.ProviderConfig{IDKey: testIDKey}, defRegion, false},
+		{"endpoint", config.ProviderConfig{Endpoint: "http://localhost:4579"}, defRegion, false},
+	}
+
+	for _, tc := range tests {
+		c := awsConfig(&tc.pc)
+
+		if aws.StringValue(c.Region) != tc.region {
+			t.Errorf("%s - Expected: '%s' | Received: '%s'", tc.name, tc.region, aws.StringValue(c.Region))
+		}
+
+		if (c.Credentials != nil) != tc.static {
+			t.Errorf("%s - Expected: static credentials %t | Received: %t", tc.name, tc.static, c.Credentials != nil)
+		}
+
+		// Session endpoint would also apply to STS.
+		if c.Endpoint != nil {
+			t.Errorf("%s - Expected: no session endpoint | Received: '%s'", tc.name, aws.StringValue(c.Endpoint))
+		}
+	}
+}
+
+// TestSESConfig checks that endpoint and assumed
+// role only apply to the SES client.
+func TestSESConfig(t *testing.T) {
+	pc := config.ProviderConfig{
+		IDKey:    testIDKey,
+		APIKey:   testAPIKey,
+		RoleARN:  "arn:aws:iam::123456789012:role/poslan",
+		Endpoint: "http://localhost:4579",
+	}
+
+	sess, err := session.NewSession(awsConfig(&pc))
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	c := sesConfig(sess, &pc)
+
+	if aws.StringValue(c.Endpoint) != pc.Endpoint {
+		t.Errorf("Expected: '%s' | Received: '%s'", pc.Endpoint, aws.StringValue(c.Endpoint))
+	}
+
+	if c.Credentials == nil || c.Credentials == sess.Config.Credentials {
+		t.Error("Expected: assumed role credentials | Received: session credentials")
+	}
+
+	if sess.Config.Endpoint != nil {
+		t.Errorf("Expected: no session endpoint | Received: '%s'", aws.StringValue(sess.Config.Endpoint))
+	}
+
+	if c := sesConfig(sess, &config.ProviderConfig{}); c.Credentials != nil || c.Endpoint != nil {
+		t.Error("Expected: session credentials and endpoint | Received: client ones")
+	}
+}
+
+// TestNewProvider checks a provider built from config
+// against a local Amazon SES API stand-in.
+func TestNewProvider(t *testing.T) {
+	var action, configSet string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action = r.PostForm.Get("Action")
+		configSet = r.PostForm.Get("ConfigurationSetName")
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<SendRawEmailResponse xmlns="http://ses.amazonaws.com/doc/2010-12-01/">
+  <SendRawEmailResult><MessageId>1</MessageId></SendRawEmailResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</SendRawEmailResponse>`))
+	}))
+	defer ts.Close()
+
+	p, err := Init(context.Background(), testConfig(config.ProviderConfig{
+		Name:             "ses",
+		Type:             config.ProviderType.AmazonSES.String(),
+		Enabled:          true,
+		Priority:         2,
+		IDKey:            testIDKey,
+		APIKey:           testAPIKey,
+		Endpoint:         ts.URL,
+		ConfigurationSet: "poslan",
+	}), log.NewLogfmtLogger(os.Stdout))
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if p.Name() != "ses" || p.Priority() != 2 {
+		t.Errorf("Expected: 'ses' 2 | Received: '%s' %d", p.Name(), p.Priority())
+	}
+
+	if p.client.Endpoint != ts.URL {
+		t.Errorf("Expected: '%s' | Received: '%s'", ts.URL, p.client.Endpoint)
+	}
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com", Subject: "Subject", Body: "Body"}
+	if _, err := p.Send(em); err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if action != "SendRawEmail" || configSet != "poslan" {
+		t.Errorf("Expected: 'SendRawEmail' 'poslan' | Received: '%s' '%s'", action, configSet)
+	}
+
+	cfg := testConfig(config.ProviderConfig{Name: "sink", Type: config.ProviderType.File.String(), Enabled: true, Priority: 1})
+	if _, err = Init(context.Background(), cfg, log.NewLogfmtLogger(os.Stdout)); err == nil {
+		t.Error("Expected: error | Received: no error")
+	}
+}
+
+// TestMessageTags checks metadata and request ID
+// conversion into valid and unique SES message tags.
+func TestMessageTags(t *testing.T) {
+	long := strings.Repeat("a", maxTagLen+10)
+
+	tests := []struct {
+		name     string
+		email    model.Email
+		expected map[string]string
+	}{
+		{"none", model.Email{}, map[string]string{}},
+		{"request ID", model.Email{RequestID: "req-1"}, map[string]string{requestIDTag: "req-1"}},
+		{
+			"sanitized",
+			model.Email{Metadata: map[string]string{"user.id": "42@example.com", "país": "España"}},
+			map[string]string{"user_id": "42_example_com", "pa_s": "Espa_a"},
+		},
+		{
+			"collision",
+			model.Email{Metadata: map[string]string{"user.id": "1", "user_id": "2", "user id": "3"}},
+			map[string]string{"user_id": "2", "user_id_2": "3", "user_id_3": "1"},
+		},
+		{
+			"truncated collision",
+			model.Email{Metadata: map[string]string{long + "b": "1", long + "c": "2"}},
+			map[string]string{long[:maxTagLen]: "1", long[:maxTagLen-2] + "_2": "2"},
+		},
+		{"empty name", model.Email{Metadata: map[string]string{"": "value"}}, map[string]string{}},
+	}
+
+	for _, tc := range tests {
+		received := make(map[string]string)
+		for _, tag := range messageTags(&tc.email) {
+			name := aws.StringValue(tag.Name)
+			if _, ok := received[name]; ok {
+				t.Errorf("%s - Expected: unique tag names | Received: '%s' repeated", tc.name, name)
+			}
+			if len(name) > maxTagLen {
+				t.Errorf("%s - Expected: up to %d chars | Received: %d", tc.name, maxTagLen, len(name))
+			}
+			received[name] = aws.StringValue(tag.Value)
+		}
+
+		if !reflect.DeepEqual(received, tc.expected) {
+			t.Errorf("%s - Expected: %v | Received: %v", tc.name, tc.expected, received)
+		}
+	}
+}
+
+// TestTagValue checks SES tag chars and length.
+func TestTagValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"Valid_value-1", "Valid_value-1"},
+		{"a.b c/d", "a_b_c_d"},
+		{"ñ", "_"},
+		{strings.Repeat("x", maxTagLen+1), strings.Repeat("x", maxTagLen)},
+	}
+
+	for _, tc := range tests {
+		if received := tagValue(tc.value); received != tc.expected {
+			t.Errorf("Expected: '%s' | Received: '%s'", tc.expected, received)
+		}
+	}
+}
+
+func testConfig(pc config.ProviderConfig) *config.Config {
+	return &config.Config{
+		Mailer: config.MailerConfig{
+			Providers: []config.ProviderConfig{pc},
+		},
+	}
+}
@@ -0,0 +1,505 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package cli implements the poslan command line interface.
+// Commands share config loading with the service and
+// operate its providers and scheduled messages directly.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/auth"
+	"github.com/adrianpk/poslan/pkg/mailer"
+	"github.com/adrianpk/poslan/pkg/model"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const usage = `Usage: poslan <command> [flags]
+
+Commands:
+  serve                       Run the service (default)
+  send                        Send a message through configured providers
+  token issue|inspect         Issue or inspect an auth token
+  config check                Validate and print the effective config
+  providers test              Send a probe through each provider
+  queue list|requeue|purge    Inspect or change scheduled messages
+
+Run 'poslan <command> -h' for command flags.
+`
+
+// secretEnv is the env var 'token issue' reads the client
+// secret from, so that it is not seen in the process list
+// or shell history. Otherwise it is read from stdin.
+const secretEnv = "POSLAN_CLIENT_SECRET"
+
+// errUsage is returned for wrong command lines,
+// command usage has already been printed.
+var errUsage = errors.New("usage")
+
+// cli runs commands reading input from stdin, writing
+// their output to stdout and errors and logs to stderr.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command func(cl *cli, args []string) error
+
+var commands = map[string]command{
+	"serve":     (*cli).serve,
+	"send":      (*cli).send,
+	"token":     (*cli).token,
+	"config":    (*cli).config,
+	"providers": (*cli).providers,
+	"queue":     (*cli).queue,
+}
+
+// Run runs the command in args and returns its exit code:
+// 0 on success, 1 if it fails and 2 on wrong usage.
+// Service is run if there is no command
+// so that 'poslan -config file' keeps working.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cl := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command '%s'\n\n%s", name, usage)
+		return 2
+	}
+
+	err := cmd(cl, args)
+	switch {
+	case err == nil:
+		return 0
+	case err == errUsage:
+		return 2
+	default:
+		fmt.Fprintf(stderr, "error: %s\n", err.Error())
+		return 1
+	}
+}
+
+// flagSet returns a flag set printing its usage to stderr.
+func (cl *cli) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(cl.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(cl.stderr, "Usage: poslan %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args, usage is printed on errors.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// subcommand returns the subcommand in args, if it is one
+// of the names provided, and the rest of them.
+func (cl *cli) subcommand(cmd string, args []string, names ...string) (string, []string, error) {
+	if len(args) > 0 {
+		for _, n := range names {
+			if args[0] == n {
+				return n, args[1:], nil
+			}
+		}
+	}
+	fmt.Fprintf(cl.stderr, "Usage: poslan %s %s\n", cmd, strings.Join(names, "|"))
+	return "", nil, errUsage
+}
+
+// configFlag adds the config file flag.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "config file path (default: $POSLAN_CONFIG_FILE)")
+}
+
+// logger returns a logger writing warnings and errors to stderr.
+func (cl *cli) logger() log.Logger {
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(cl.stderr))
+	return level.NewFilter(logger, level.AllowWarn())
+}
+
+// loadConfig loads config printing every problem found.
+func (cl *cli) loadConfig(path string) (*c.Config, error) {
+	cfg, err := c.Load(cl.logger(), path)
+	if ve, ok := err.(c.ValidationError); ok {
+		for _, p := range ve.Problems {
+			fmt.Fprintf(cl.stderr, "invalid config: %s\n", p)
+		}
+		return nil, fmt.Errorf("%d config problems found", len(ve.Problems))
+	}
+	return cfg, err
+}
+
+func (cl *cli) serve(args []string) error {
+	fs := cl.flagSet("serve", "[flags]")
+	configFile := configFlag(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	mailer.Run(*configFile)
+	return nil
+}
+
+// messageFlags adds the flags composing a message.
+func messageFlags(fs *flag.FlagSet) func() (*model.Email, error) {
+	e := &model.Email{}
+	fs.StringVar(&e.From, "from", "", "sender address (required)")
+	fs.StringVar(&e.Name, "from-name", "", "sender name")
+	fs.StringVar(&e.To, "to", "", "comma separated recipient addresses (required)")
+	fs.StringVar(&e.CC, "cc", "", "comma separated CC addresses")
+	fs.StringVar(&e.BCC, "bcc", "", "comma separated BCC addresses")
+	fs.StringVar(&e.ReplyTo, "reply-to", "", "reply-to address")
+	fs.StringVar(&e.Subject, "subject", "", "message subject")
+	fs.StringVar(&e.Body, "body", "", "message body")
+	bodyFile := fs.String("body-file", "", "file whose content is the message body")
+
+	return func() (*model.Email, error) {
+		if e.From == "" || e.To == "" {
+			return nil, errors.New("-from and -to are required")
+		}
+
+		if *bodyFile != "" {
+			body, err := ioutil.ReadFile(*bodyFile)
+			if err != nil {
+				return nil, err
+			}
+			e.Body = string(body)
+		}
+		return e, nil
+	}
+}
+
+func (cl *cli) send(args []string) error {
+	fs := cl.flagSet("send", "-from address -to addresses [flags]")
+	configFile := configFlag(fs)
+	provider := fs.String("provider", "", "send only through this provider (default: all enabled, by priority)")
+	email := messageFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	e, err := email()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := cl.loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	st, err := mailer.Deliver(context.Background(), cfg, cl.logger(), e, *provider)
+	if st != nil {
+		fmt.Fprintf(cl.stdout, "%s\t%s\t%s\tattempts=%d\n", st.ID, st.Status, st.Provider, st.Attempts)
+	}
+	return err
+}
+
+func (cl *cli) token(args []string) error {
+	sub, args, err := cl.subcommand("token", args, "issue", "inspect")
+	if err != nil {
+		return err
+	}
+
+	if sub == "inspect" {
+		return cl.inspectToken(args)
+	}
+
+	fs := cl.flagSet("token issue", "-client id < secret")
+	clientID := fs.String("client", "", "client ID (required), secret is read from $"+secretEnv+" or stdin")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	secret, err := cl.secret()
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.Server{Logger: cl.logger()}.Authenticate(*clientID, secret)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cl.stdout, token)
+	return nil
+}
+
+// secret returns the client secret from secretEnv
+// or, if not set, from the first line of stdin.
+func (cl *cli) secret() (string, error) {
+	if secret := os.Getenv(secretEnv); secret != "" {
+		return secret, nil
+	}
+
+	line, err := bufio.NewReader(cl.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("client secret is required, set $%s or write it to stdin", secretEnv)
+	}
+	return secret, nil
+}
+
+// inspectToken prints token claims and whether it is valid.
+func (cl *cli) inspectToken(args []string) error {
+	fs := cl.flagSet("token inspect", "token")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	token := fs.Arg(0)
+
+	// Claims of expired tokens are shown too.
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return fmt.Errorf("malformed token: %s", err.Error())
+	}
+
+	w := tabwriter.NewWriter(cl.stdout, 0, 4, 2, ' ', 0)
+	for _, k := range []string{"clientID", "userID", "username", "name", "email"} {
+		fmt.Fprintf(w, "%s:\t%v\n", k, claims[k])
+	}
+	for _, k := range []string{"iat", "exp"} {
+		if ts, ok := claims[k].(float64); ok {
+			fmt.Fprintf(w, "%s:\t%s\n", k, time.Unix(int64(ts), 0).UTC().Format(time.RFC3339))
+		}
+	}
+
+	valid := auth.Server{Logger: cl.logger()}.ValidateToken(token)
+	if valid == nil {
+		fmt.Fprintf(w, "valid:\ttrue\n")
+	} else {
+		fmt.Fprintf(w, "valid:\tfalse (%s)\n", valid.Error())
+	}
+	w.Flush()
+
+	return valid
+}
+
+func (cl *cli) config(args []string) error {
+	_, args, err := cl.subcommand("config", args, "check")
+	if err != nil {
+		return err
+	}
+
+	fs := cl.flagSet("config check", "[flags]")
+	configFile := configFlag(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cl.loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(cfg.Masked())
+	if err != nil {
+		return err
+	}
+
+	cl.stdout.Write(out)
+	fmt.Fprintln(cl.stderr, "Config is valid.")
+	return nil
+}
+
+func (cl *cli) providers(args []string) error {
+	_, args, err := cl.subcommand("providers", args, "test")
+	if err != nil {
+		return err
+	}
+
+	fs := cl.flagSet("providers test", "-from address -to addresses [flags]")
+	configFile := configFlag(fs)
+	provider := fs.String("provider", "", "probe only this provider (default: all enabled)")
+	email := messageFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	e, err := email()
+	if err != nil {
+		return err
+	}
+	if e.Subject == "" {
+		e.Subject = "Poslan provider probe"
+	}
+	if e.Body == "" {
+		e.Body = "This is a probe sent to check the provider delivers messages."
+	}
+
+	cfg, err := cl.loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	rs := mailer.ProbeProviders(context.Background(), cfg, cl.logger(), e, *provider)
+	if len(rs) == 0 {
+		return errors.New("no enabled providers to probe")
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(cl.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tRESULT\tLATENCY")
+	for _, r := range rs {
+		result := "ok"
+		if r.Err != nil {
+			result = "failed: " + r.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Provider, result, r.Latency.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d providers failed", failed, len(rs))
+	}
+	return nil
+}
+
+func (cl *cli) queue(args []string) error {
+	sub, args, err := cl.subcommand("queue", args, "list", "requeue", "purge")
+	if err != nil {
+		return err
+	}
+
+	var fs *flag.FlagSet
+	var at *string
+	switch sub {
+	case "list":
+		fs = cl.flagSet("queue list", "[flags]")
+	case "requeue":
+		fs = cl.flagSet("queue requeue", "[flags] -all | id...")
+		at = fs.String("at", "", "new send time, RFC 3339 (default: now)")
+	case "purge":
+		fs = cl.flagSet("queue purge", "[flags] -all | id...")
+	}
+	configFile := configFlag(fs)
+	all := fs.Bool("all", false, "every scheduled message")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := cl.loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	q, err := mailer.OpenQueue(cfg.App.ScheduleDir)
+	if err != nil {
+		return err
+	}
+
+	if sub == "list" {
+		cl.listQueue(q)
+		return nil
+	}
+
+	ids, err := queueIDs(q, fs.Args(), *all)
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+
+	sendAt := time.Now()
+	if at != nil && *at != "" {
+		if sendAt, err = time.Parse(time.RFC3339, *at); err != nil {
+			return fmt.Errorf("invalid send time '%s'", *at)
+		}
+	}
+
+	for _, id := range ids {
+		var ok bool
+		if sub == "requeue" {
+			ok, err = q.Requeue(id, sendAt)
+		} else {
+			ok, err = q.Purge(id)
+		}
+
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("message '%s' is not scheduled", id)
+		}
+		fmt.Fprintf(cl.stdout, "%s\t%sd\n", id, sub)
+	}
+
+	return nil
+}
+
+// listQueue prints scheduled messages by send time.
+func (cl *cli) listQueue(q *mailer.Queue) {
+	w := tabwriter.NewWriter(cl.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCLIENT\tSEND AT\tTO\tSUBJECT")
+	for _, qm := range q.Messages() {
+		e := qm.Email
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ID, qm.ClientID, e.SendAt.UTC().Format(time.RFC3339), e.To, e.Subject)
+	}
+	w.Flush()
+}
+
+// queueIDs returns every queued message ID
+// if all is set or the IDs provided otherwise.
+func queueIDs(q *mailer.Queue, args []string, all bool) ([]uuid.UUID, error) {
+	if all == (len(args) > 0) {
+		return nil, errors.New("either -all or message IDs are required")
+	}
+
+	var ids []uuid.UUID
+	if all {
+		for _, qm := range q.Messages() {
+			ids = append(ids, qm.Email.ID)
+		}
+		return ids, nil
+	}
+
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message ID '%s'", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const testConfigYAML = `
+app:
+  scheduleDir: "%s"
+mailer:
+  provider:
+    - name: "sink"
+      type: "file"
+      path: "%s"
+      apiKey: "secret-key"
+`
+
+const testScheduledJSON = `{"clientID": "client", "email": {"ID": "%s", "To": "to@example.com", "Subject": "Later", "SendAt": "2030-01-01T00:00:00Z"}}`
+
+// TestRun runs every command against a config
+// with a file provider and a stored scheduled message.
+func TestRun(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "poslan-cli")
+	defer os.RemoveAll(dir)
+
+	schedDir := filepath.Join(dir, "scheduled")
+	outDir := filepath.Join(dir, "out")
+	os.MkdirAll(schedDir, 0700)
+
+	id := uuid.New().String()
+	ioutil.WriteFile(filepath.Join(schedDir, id+".json"), []byte(fmt.Sprintf(testScheduledJSON, id)), 0600)
+
+	cfgPath := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(cfgPath, []byte(fmt.Sprintf(testConfigYAML, schedDir, outDir)), 0600)
+
+	badPath := filepath.Join(dir, "bad.yaml")
+	ioutil.WriteFile(badPath, []byte("app:\n  serverPort: -1\n"), 0600)
+
+	msg := []string{"-config", cfgPath, "-from", "from@example.com", "-to", "to@example.com"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected int
+		output   string
+	}{
+		{"unknown command", []string{"bogus"}, "", 2, ""},
+		{"missing subcommand", []string{"config"}, "", 2, ""},
+		{"config check", []string{"config", "check", "-config", cfgPath}, "", 0, "apiKey: '[REDACTED]'"},
+		{"invalid config", []string{"config", "check", "-config", badPath}, "", 1, ""},
+		{"send", append([]string{"send", "-subject", "Hi"}, msg...), "", 0, "sent\tsink"},
+		{"send unknown provider", append([]string{"send", "-provider", "unknown"}, msg...), "", 1, ""},
+		{"send without recipient", []string{"send", "-config", cfgPath, "-from", "from@example.com"}, "", 1, ""},
+		{"providers test", append([]string{"providers", "test"}, msg...), "", 0, "sink"},
+		{"token issue", []string{"token", "issue", "-client", "dd74cb9cfb5a4f1cac4d"}, "a5ee54c8a21a4c61820f88f14c30fa5b\n", 0, "."},
+		{"wrong credentials", []string{"token", "issue", "-client", "dd74cb9cfb5a4f1cac4d"}, "wrong\n", 1, ""},
+		{"missing secret", []string{"token", "issue", "-client", "dd74cb9cfb5a4f1cac4d"}, "", 1, ""},
+		{"secret flag", []string{"token", "issue", "-client", "dd74cb9cfb5a4f1cac4d", "-secret", "a5ee54c8a21a4c61820f88f14c30fa5b"}, "", 2, ""},
+		{"malformed token", []string{"token", "inspect", "malformed"}, "", 1, ""},
+		{"queue list", []string{"queue", "list", "-config", cfgPath}, "", 0, id},
+		{"queue requeue", []string{"queue", "requeue", "-config", cfgPath, "-at", "2031-01-01T00:00:00Z", id}, "", 0, id + "\trequeued"},
+		{"queue requeued", []string{"queue", "list", "-config", cfgPath}, "", 0, "2031-01-01T00:00:00Z"},
+		{"queue purge without IDs", []string{"queue", "purge", "-config", cfgPath}, "", 1, ""},
+		{"queue purge unknown", []string{"queue", "purge", "-config", cfgPath, uuid.New().String()}, "", 1, ""},
+		{"queue purge", []string{"queue", "purge", "-config", cfgPath, "-all"}, "", 0, id + "\tpurged"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		received := Run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if received != tt.expected || !strings.Contains(stdout.String(), tt.output) {
+			t.Errorf("%s - Expected: %d '%s' | Received: %d '%s' %s", tt.name, tt.expected, tt.output, received, stdout.String(), stderr.String())
+		}
+	}
+
+	if fis, _ := ioutil.ReadDir(outDir); len(fis) != 2 {
+		t.Errorf("Expected: 2 written messages | Received: %d", len(fis))
+	}
+
+	if fs, _ := filepath.Glob(filepath.Join(schedDir, "*.json")); len(fs) != 0 {
+		t.Errorf("Expected: 0 scheduled messages | Received: %d", len(fs))
+	}
+}
+
+// TestInspectToken checks the claims of a token
+// issued with the secret in secretEnv.
+func TestInspectToken(t *testing.T) {
+	os.Setenv(secretEnv, "a5ee54c8a21a4c61820f88f14c30fa5b")
+	defer os.Unsetenv(secretEnv)
+
+	var token, out bytes.Buffer
+	Run([]string{"token", "issue", "-client", "dd74cb9cfb5a4f1cac4d"}, strings.NewReader(""), &token, &out)
+
+	code := Run([]string{"token", "inspect", strings.TrimSpace(token.String())}, strings.NewReader(""), &out, &out)
+	if code != 0 || !strings.Contains(out.String(), "dd74cb9cfb5a4f1cac4d") || !strings.Contains(out.String(), "valid:     true") {
+		t.Errorf("Expected: 0, valid client token | Received: %d %s", code, out.String())
+	}
+}
@@ -10,160 +10,201 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
-	"strconv"
-
-	b64 "encoding/base64"
+	"strings"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"gopkg.in/yaml.v2"
 )
 
-const (
-	defConfigPath = "/config.yml"
-)
-
 var (
 	logger log.Logger
 )
 
-// Load is a generic config loader.
-func Load(l log.Logger) (*Config, error) {
+// Load returns the config layered from defaults, the config file
+// and environment variables, each one overriding the previous.
+// Config file path is the one provided or, if empty, the one in
+// POSLAN_CONFIG_FILE; if there is none only defaults and
+// environment variables are used.
+// Config is validated, every problem found is reported at once.
+func Load(l log.Logger, path string) (*Config, error) {
 	logger = l
-	// return loadDefault()
-	// return loadFromSecretsPath()
-	return loadFromEnvvar()
 
-}
-
-// loadFromEnvvar - Load from envvars.
-// TODO: Default values must be corrected after establishing the appropriate ones.
-func loadFromEnvvar() (*Config, error) {
-	// App
-	appServerPort, _ := strconv.Atoi(GetEnvOrDef("POSLAN_SERVER_PORT", "8080"))
-	appLogLevel := GetEnvOrDef("POSLAN_LOG_LEVEL", "debug")
-	providers := loadProvidersFromEnvars()
+	cfg := loadDefault()
 
-	app := AppConfig{
-		ServerPort: appServerPort,
-		LogLevel:   logLevel(appLogLevel),
+	if path = FilePath(path); path != "" {
+		err := loadFromFile(cfg, path)
+		if err != nil {
+			return nil, err
+		}
+		level.Info(logger).Log("message", "Config file loaded.", "file", path)
 	}
 
-	mailers := MailerConfig{
-		Providers: providers,
-	}
+	env := loadFromEnvvar(cfg)
 
-	cfg := &Config{
-		App:    app,
-		Mailer: mailers,
+	problems := append(env.problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return nil, ValidationError{Problems: problems}
 	}
 
 	return cfg, nil
 }
 
-func loadProvidersFromEnvars() []ProviderConfig {
-
-	// Providers envvar value prefixes
-	pfxs := []string{"PROVIDER_NAME", "PROVIDER_TYPE", "PROVIDER_ENABLED",
-		"PROVIDER_PRIORITY", "PROVIDER_SENDER_NAME", "PROVIDER_SENDER_EMAIL",
-		"PROVIDER_ID_KEY", "PROVIDER_API_KEY"}
-	envall := composeName(pfxs, n) // PROVIDER_NAME_1, PROVIDER_TYPE_1... PROVIDER_SENDER_EMAIL_2
-
-	ps := make([]ProviderConfig, 0)
-
-	for _, s := range envall {
-
-		nm := GetEnvOrDef(s[0], "") // Name
-		tp := GetEnvOrDef(s[1], "") // Type
-
-		if nm != "" && tp != "" {
-
-			en, _ := strconv.ParseBool(GetEnvOrDef(s[2], "true")) // Enabled
-			pr, _ := strconv.Atoi(GetEnvOrDef(s[3], "1"))         // Priority
-			sn := GetEnvOrDef(s[4], "")                           // Sender name
-			se := GetEnvOrDef(s[5], "")                           // Sender email
-			ik := GetEnvOrDef(s[6], "")                           // ID Key (i.e.: AWS Access key)
-			ak := GetEnvOrDef(s[7], "")                           // API Key (i.e.: AWS or SendGrid API key)
-
-			p := ProviderConfig{
-				Name:     nm,
-				Type:     tp,
-				Enabled:  en,
-				Priority: pr,
-				IDKey:    ik,
-				APIKey:   ak,
-				Sender: SenderConfig{
-					Name:  sn,
-					Email: se,
-				},
-			}
-
-			ps = append(ps, p)
-		}
+// FilePath returns the config file path provided
+// or, if empty, the one in POSLAN_CONFIG_FILE.
+func FilePath(path string) string {
+	if path != "" {
+		return path
 	}
-
-	return ps
+	return GetEnvOrDef("POSLAN_CONFIG_FILE")
 }
 
-// loadFromSecretsPath - Load from k8s secrets mount path.
-func loadFromSecretsPath() (*Config, error) {
-	var cfg *Config
-	fileBytes, err := ioutil.ReadFile(defConfigPath)
-	if err != nil {
-		// logger.Log("level", LogLevel.Error, "message", "File open error", "file", defConfigPath)
-		return nil, err
+// loadDefault returns default values.
+func loadDefault() *Config {
+	return &Config{
+		App: AppConfig{
+			ServerPort: 8080,
+			GRPCPort:   8081,
+			AdminPort:  8090,
+			LogLevel:   LogLevel.Info,
+			LogFormat:  LogFormat.Logfmt,
+			LogPrivacy: PrivacyLevel.Hash,
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+			IdempotencyTTL: 86400,
+			Tracing: TracingConfig{
+				SampleRate: 1,
+			},
+		},
 	}
+}
 
-	configYAMLBytes, err := b64.StdEncoding.DecodeString(string(fileBytes))
+// loadFromFile loads a YAML config file over cfg values.
+// Unknown keys are rejected.
+func loadFromFile(cfg *Config, path string) error {
+	fileBytes, err := ioutil.ReadFile(path)
 	if err != nil {
-		// logger.Log("level", LogLevel.Error, "message", "Error decoding config file", "file", defConfigPath)
-		return cfg, err
+		return err
 	}
 
-	err = yaml.Unmarshal(configYAMLBytes, &cfg)
+	err = yaml.UnmarshalStrict(fileBytes, cfg)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("invalid config file '%s': %s", path, err.Error())
 	}
 
-	// logger.Log("level", LogLevel.Debug, "message", "Config", "file", cfg)
-	return cfg, nil
+	return nil
 }
 
-// loadFromFile - Load from standard configuration yaml file.
-func loadFromFile(filePath string) (*Config, error) {
-	var cfg *Config
-	fileBytes, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		// logger.Log("level", LogLevel.Error, "message", "File open error", "file", filePath)
-		return nil, err
-	}
-	err = yaml.Unmarshal(fileBytes, &cfg)
-	if err != nil {
-		return nil, err
+// loadFromEnvvar overrides cfg values with the ones of
+// environment variables, those that are not set are kept.
+// It returns the reader used so that malformed values
+// are reported along with the rest of config problems.
+func loadFromEnvvar(cfg *Config) *envReader {
+	env := &envReader{}
 
-	}
-	// logger.Log("level", LogLevel.Debug, "message", "Config", "file", cfg)
-	return cfg, nil
+	// App
+	app := &cfg.App
+	env.int("POSLAN_SERVER_PORT", &app.ServerPort)
+	env.int("POSLAN_GRPC_PORT", &app.GRPCPort)
+	env.int("POSLAN_ADMIN_PORT", &app.AdminPort)
+	env.string("POSLAN_LOG_LEVEL", (*string)(&app.LogLevel))
+	env.string("POSLAN_LOG_FORMAT", (*string)(&app.LogFormat))
+	env.string("POSLAN_LOG_PRIVACY", (*string)(&app.LogPrivacy))
+	env.bool("POSLAN_DRY_RUN", &app.DryRun)
+	env.list("POSLAN_DRY_RUN_CLIENTS", &app.DryRunClients)
+	env.list("POSLAN_ADMIN_CLIENTS", &app.AdminClients)
+	env.bool("POSLAN_SMTP_ENABLED", &app.SMTP.Enabled)
+	env.int("POSLAN_SMTP_PORT", &app.SMTP.Port)
+	env.string("POSLAN_SMTP_HOSTNAME", &app.SMTP.Hostname)
+	env.string("POSLAN_SMTP_TLS_CERT", &app.SMTP.TLSCert)
+	env.string("POSLAN_SMTP_TLS_KEY", &app.SMTP.TLSKey)
+	env.int64("POSLAN_SMTP_MAX_MESSAGE_BYTES", &app.SMTP.MaxMessageBytes)
+	env.bool("POSLAN_SMTP_ALLOW_INSECURE_AUTH", &app.SMTP.AllowInsecureAuth)
+	env.string("POSLAN_SCHEDULE_DIR", &app.ScheduleDir)
+	env.int("POSLAN_IDEMPOTENCY_TTL", &app.IdempotencyTTL)
+	env.string("POSLAN_TRACING_EXPORTER", &app.Tracing.Exporter)
+	env.string("POSLAN_TRACING_ENDPOINT", &app.Tracing.Endpoint)
+	env.float64("POSLAN_TRACING_SAMPLE_RATE", &app.Tracing.SampleRate)
+
+	// Providers
+	loadProvidersFromEnvars(env, &cfg.Mailer)
+
+	return env
 }
 
-// loadDefault gets efault values
-func loadDefault() (*Config, error) {
-	cfg := Config{}
+// loadProvidersFromEnvars overrides the i-th provider in config
+// with PROVIDER_*_i environment variables. Past the providers
+// in config, a provider is added if its name and type are set.
+func loadProvidersFromEnvars(env *envReader, mc *MailerConfig) {
 
-	// App
-	cfg.App.ServerPort = 8080
-	cfg.App.LogLevel = LogLevel.Debug
+	// Providers envvar value prefixes
+	pfxs := []string{"PROVIDER_NAME", "PROVIDER_TYPE", "PROVIDER_ENABLED",
+		"PROVIDER_PRIORITY", "PROVIDER_SENDER_NAME", "PROVIDER_SENDER_EMAIL",
+		"PROVIDER_ID_KEY", "PROVIDER_API_KEY", "PROVIDER_REGION",
+		"PROVIDER_ENDPOINT", "PROVIDER_ROLE_ARN", "PROVIDER_CONFIG_SET",
+		"PROVIDER_SANDBOX", "PROVIDER_UNSUBSCRIBE_GROUP", "PROVIDER_DOMAIN",
+		"PROVIDER_STREAM", "PROVIDER_FORMAT", "PROVIDER_TIMEOUT",
+		"PROVIDER_PATH", "PROVIDER_TESTONLY"}
+	envall := composeName(pfxs, n) // PROVIDER_NAME_1, PROVIDER_TYPE_1... PROVIDER_SENDER_EMAIL_2
 
-	// Providers
-	// Provider 1
-	amazon := ProviderConfig{Name: "amazon"}
-	// Provider 2
-	sendgrid := ProviderConfig{Name: "sendgrid"}
+	for i, s := range envall {
+
+		var p *ProviderConfig
+		if i < len(mc.Providers) {
+			p = &mc.Providers[i]
+		} else {
+			nm, _ := env.lookup(s[0]) // Name
+			tp, _ := env.lookup(s[1]) // Type
+			if nm == "" || tp == "" {
+				continue
+			}
+			mc.Providers = append(mc.Providers, defaultProvider())
+			p = &mc.Providers[len(mc.Providers)-1]
+		}
 
-	// Mail
-	cfg.Mailer.Providers[0] = amazon
-	cfg.Mailer.Providers[1] = sendgrid
+		env.string(s[0], &p.Name)              // Name
+		env.string(s[1], &p.Type)              // Type
+		env.bool(s[2], &p.Enabled)             // Enabled
+		env.int(s[3], &p.Priority)             // Priority
+		env.string(s[4], &p.Sender.Name)       // Sender name
+		env.string(s[5], &p.Sender.Email)      // Sender email
+		env.string(s[6], &p.IDKey)             // ID Key (i.e.: AWS Access key)
+		env.string(s[7], &p.APIKey)            // API Key (i.e.: AWS or SendGrid API key)
+		env.string(s[8], &p.Region)            // Region (i.e.: AWS region)
+		env.string(s[9], &p.Endpoint)          // Endpoint (i.e.: local SES stand-in URL)
+		env.string(s[10], &p.RoleARN)          // Role ARN (i.e.: AWS role to assume)
+		env.string(s[11], &p.ConfigurationSet) // Configuration set (i.e.: SES configuration set)
+		env.bool(s[12], &p.Sandbox)            // Sandbox (i.e.: SendGrid sandbox mode)
+		env.int(s[13], &p.UnsubscribeGroup)    // Unsubscribe group (i.e.: SendGrid ASM group ID)
+		env.string(s[14], &p.Domain)           // Domain (i.e.: Mailgun sending domain)
+		env.string(s[15], &p.Stream)           // Stream (i.e.: Postmark message stream)
+		env.string(s[16], &p.Format)           // Format (i.e.: webhook json or rfc822)
+		env.int(s[17], &p.Timeout)             // Timeout in seconds
+		env.string(s[18], &p.Path)             // Path (i.e.: file provider directory)
+		env.bool(s[19], &p.TestOnly)           // Test only
+	}
+}
 
-	return &cfg, nil
+// defaultProvider returns provider default values.
+func defaultProvider() ProviderConfig {
+	return ProviderConfig{
+		Enabled:  true,
+		Priority: 1,
+	}
+}
+
+// splitList splits a comma separated list
+// discarding empty values.
+func splitList(list string) []string {
+	vals := make([]string, 0)
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return vals
 }
 
 // composeName compose prefixes with a range of indexes.
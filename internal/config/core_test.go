@@ -0,0 +1,191 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+const testConfigYAML = `
+app:
+  serverPort: 9080
+  logLevel: "info"
+mailer:
+  provider:
+    - name: "sendgrid"
+      type: "sendgrid"
+      priority: 1
+      apiKey: "file-key"
+`
+
+// TestLoad checks that environment variables
+// override config file values and these defaults.
+func TestLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "poslan-config")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte(testConfigYAML), 0600)
+
+	secret := filepath.Join(dir, "api-key")
+	ioutil.WriteFile(secret, []byte("secret-key\n"), 0600)
+
+	defer setEnv(map[string]string{
+		"POSLAN_LOG_LEVEL":        "warn",
+		"PROVIDER_API_KEY_1_FILE": secret,
+		"PROVIDER_NAME_2":         "sink",
+		"PROVIDER_TYPE_2":         "file",
+		"PROVIDER_TESTONLY_2":     "true",
+	})()
+
+	cfg, err := Load(log.NewNopLogger(), path)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		received interface{}
+	}{
+		{"file value", 9080, cfg.App.ServerPort},
+		{"default value", 8081, cfg.App.GRPCPort},
+		{"env value", LogLevel.Warn, cfg.App.LogLevel},
+		{"default log level", LogLevel.Info, loadDefault().App.LogLevel},
+		{"secret file", "secret-key", cfg.Mailer.Providers[0].APIKey},
+		{"provider default", true, cfg.Mailer.Providers[0].Enabled},
+		{"env provider", "sink", cfg.Mailer.Providers[1].Name},
+		{"env provider default", 1, cfg.Mailer.Providers[1].Priority},
+	}
+
+	for _, tt := range tests {
+		if tt.expected != tt.received {
+			t.Errorf("%s - Expected: %v | Received: %v", tt.name, tt.expected, tt.received)
+		}
+	}
+}
+
+// TestLoadConfigFile checks that the sample config file is valid.
+func TestLoadConfigFile(t *testing.T) {
+	cfg, err := Load(log.NewNopLogger(), "../../configs/config.yaml")
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if len(cfg.Mailer.Providers) != 6 {
+		t.Errorf("Expected: 6 providers | Received: %d", len(cfg.Mailer.Providers))
+	}
+}
+
+// TestLoadErrors checks that unknown keys and
+// malformed environment values are rejected.
+func TestLoadErrors(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "poslan-config")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte("mail:\n  provider: []\n"), 0600)
+
+	if _, err := Load(log.NewNopLogger(), path); err == nil {
+		t.Error("Expected: unknown key error | Received: no error")
+	}
+
+	defer setEnv(map[string]string{
+		"POSLAN_SERVER_PORT": "http",
+		"POSLAN_DRY_RUN":     "maybe",
+	})()
+
+	_, err := Load(log.NewNopLogger(), "")
+	ve, ok := err.(ValidationError)
+	if !ok || len(ve.Problems) != 2 {
+		t.Errorf("Expected: 2 problems | Received: %v", err)
+	}
+}
+
+// TestValidate checks that every problem is reported.
+func TestValidate(t *testing.T) {
+	cfg := loadDefault()
+	cfg.App.LogFormat = "xml"
+	cfg.App.SMTP.TLSCert = "tls.crt"
+	cfg.App.Tracing.SampleRate = 2
+	cfg.Mailer.Providers = []ProviderConfig{
+		{Name: "amazon", Type: "amazon-ses", Enabled: true, Priority: 1, IDKey: "id-key"},
+		{Name: "sendgrid", Type: "sendgrid", Enabled: true, Priority: 1},
+		{Name: "sendgrid", Type: "sendgird", Enabled: true, Priority: 2},
+		{Name: "mailgun", Type: "mailgun", Enabled: true, Priority: 3, APIKey: "key", Domain: "mg.example.com", Sender: SenderConfig{Email: "sender"}},
+		{Name: "sink", Type: "file", Enabled: true, Priority: 1, TestOnly: true},
+		{Name: "postmark", Type: "postmark", Priority: 1},
+		{Name: "staging", Type: "amazon-ses", Priority: 2, TestOnly: true},
+		{Name: "hook", Type: "webhook", Priority: 3, Endpoint: "http://localhost:9090/mail", TestOnly: true},
+	}
+
+	err := cfg.Validate()
+	ve, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("Expected: validation error | Received: %v", err)
+	}
+
+	expected := []string{
+		"app.logFormat: unknown log format 'xml'",
+		"app.smtp: tlsCert and tlsKey must be set together",
+		"app.tracing.sampleRate: 2 is not between 0 and 1",
+		"mailer.provider[0] 'amazon': apiKey is required by amazon-ses providers",
+		"mailer.provider[1] 'sendgrid': priority 1 is also used by 'amazon'",
+		"mailer.provider[1] 'sendgrid': apiKey is required by sendgrid providers",
+		"mailer.provider[2] 'sendgrid': duplicate name",
+		"mailer.provider[2] 'sendgrid': unknown type 'sendgird'",
+		"mailer.provider[3] 'mailgun': invalid sender email 'sender'",
+		"mailer.provider[6] 'staging': testOnly is only allowed on file providers",
+		"mailer.provider[7] 'hook': testOnly is only allowed on file providers",
+	}
+
+	if strings.Join(ve.Problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected: %q | Received: %q", expected, ve.Problems)
+	}
+}
+
+// setEnv sets environment variables
+// and returns a function that unsets them.
+func setEnv(vars map[string]string) func() {
+	for k, v := range vars {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range vars {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+// TestMasked checks that secrets are masked
+// in a copy of the config.
+func TestMasked(t *testing.T) {
+	cfg := loadDefault()
+	cfg.Mailer.Providers = []ProviderConfig{
+		{Name: "amazon", IDKey: "id-key", APIKey: "api-key"},
+		{Name: "file"},
+	}
+
+	masked := cfg.Masked()
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		received interface{}
+	}{
+		{"masked ID key", maskedValue, masked.Mailer.Providers[0].IDKey},
+		{"masked API key", maskedValue, masked.Mailer.Providers[0].APIKey},
+		{"unset secret", "", masked.Mailer.Providers[1].APIKey},
+		{"original API key", "api-key", cfg.Mailer.Providers[0].APIKey},
+	}
+
+	for _, tt := range tests {
+		if tt.expected != tt.received {
+			t.Errorf("%s - Expected: %v | Received: %v", tt.name, tt.expected, tt.received)
+		}
+	}
+}
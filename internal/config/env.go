@@ -8,7 +8,18 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// fileEnvSuffix names the variable whose value is
+	// the path of a file holding the value of another one
+	// (i.e.: PROVIDER_API_KEY_1_FILE=/run/secrets/sendgrid).
+	fileEnvSuffix = "_FILE"
 )
 
 // GetEnvOrDef - Return the value of provided environment variable or default
@@ -25,3 +36,105 @@ func GetEnvOrDef(envar string, def ...string) string {
 	}
 	return ""
 }
+
+// envReader sets config values from environment variables.
+// Values of variables that are not set are kept.
+// Malformed values are kept as problems instead of
+// failing so that all of them can be reported at once.
+type envReader struct {
+	problems []string
+}
+
+// lookup returns the value of an environment variable or, if it
+// is empty, the content of the file named by its _FILE variable.
+// Trailing new lines of files are discarded.
+func (r *envReader) lookup(envar string) (string, bool) {
+	if val := os.Getenv(envar); val != "" {
+		return val, true
+	}
+
+	path := os.Getenv(envar + fileEnvSuffix)
+	if path == "" {
+		return "", false
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s%s: %s", envar, fileEnvSuffix, err.Error()))
+		return "", false
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), true
+}
+
+func (r *envReader) string(envar string, v *string) {
+	if val, ok := r.lookup(envar); ok {
+		*v = val
+	}
+}
+
+func (r *envReader) list(envar string, v *[]string) {
+	if val, ok := r.lookup(envar); ok {
+		*v = splitList(val)
+	}
+}
+
+func (r *envReader) int(envar string, v *int) {
+	val, ok := r.lookup(envar)
+	if !ok {
+		return
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		r.malformed(envar, val, "an integer")
+		return
+	}
+	*v = i
+}
+
+func (r *envReader) int64(envar string, v *int64) {
+	val, ok := r.lookup(envar)
+	if !ok {
+		return
+	}
+
+	i, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		r.malformed(envar, val, "an integer")
+		return
+	}
+	*v = i
+}
+
+func (r *envReader) float64(envar string, v *float64) {
+	val, ok := r.lookup(envar)
+	if !ok {
+		return
+	}
+
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		r.malformed(envar, val, "a number")
+		return
+	}
+	*v = f
+}
+
+func (r *envReader) bool(envar string, v *bool) {
+	val, ok := r.lookup(envar)
+	if !ok {
+		return
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		r.malformed(envar, val, "a boolean")
+		return
+	}
+	*v = b
+}
+
+func (r *envReader) malformed(envar, val, kind string) {
+	r.problems = append(r.problems, fmt.Sprintf("%s: '%s' is not %s", envar, val, kind))
+}
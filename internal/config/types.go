@@ -7,13 +7,79 @@ type Config struct {
 	// AppConfig stores app related configuration.
 	App AppConfig `yaml:"app"`
 	// MailerConfig stores mail service provider configuration.
-	Mailer MailerConfig `yaml:"mail"`
+	Mailer MailerConfig `yaml:"mailer"`
 }
 
 // AppConfig stores app related configuration..
 type AppConfig struct {
-	ServerPort int      `yaml:"serverPort"`
-	LogLevel   logLevel `yaml:"logLevel"`
+	ServerPort int `yaml:"serverPort"`
+	// GRPCPort of the gRPC server, zero disables it.
+	GRPCPort int `yaml:"grpcPort"`
+	// AdminPort of the metrics and health server, zero disables it.
+	AdminPort int      `yaml:"adminPort"`
+	LogLevel  logLevel `yaml:"logLevel"`
+	// LogFormat of log output, logfmt or json.
+	LogFormat logFormat `yaml:"logFormat"`
+	// LogPrivacy sets how email addresses and contents are logged,
+	// secrets and tokens are always masked.
+	LogPrivacy privacyLevel `yaml:"logPrivacy"`
+	// DryRun sends are delivered only to test-only providers.
+	DryRun bool `yaml:"dryRun"`
+	// DryRunClients lists the clients whose sends are always dry-run.
+	DryRunClients []string `yaml:"dryRunClients"`
+	// AdminClients lists the clients allowed to
+	// manage providers on the admin port.
+	AdminClients []string `yaml:"adminClients"`
+	// SMTP submission server configuration.
+	SMTP SMTPConfig `yaml:"smtp"`
+	// ScheduleDir is where scheduled messages are stored.
+	// If empty they are only kept in memory and lost on restart.
+	ScheduleDir string `yaml:"scheduleDir"`
+	// IdempotencyTTL is the time in seconds send responses
+	// are replayed for the same idempotency key, zero disables it.
+	IdempotencyTTL int `yaml:"idempotencyTTL"`
+	// Tracing span export configuration.
+	Tracing TracingConfig `yaml:"tracing"`
+}
+
+// SMTPConfig stores SMTP submission server configuration.
+type SMTPConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Port     int    `yaml:"port"`
+	Hostname string `yaml:"hostname"`
+	// TLSCert and TLSKey are PEM file paths.
+	// STARTTLS is offered when both are set.
+	TLSCert string `yaml:"tlsCert"`
+	TLSKey  string `yaml:"tlsKey"`
+	// MaxMessageBytes is the max accepted message size.
+	MaxMessageBytes int64 `yaml:"maxMessageBytes"`
+	// AllowInsecureAuth lets clients authenticate without STARTTLS.
+	// Use it only for local development.
+	AllowInsecureAuth bool `yaml:"allowInsecureAuth"`
+}
+
+// Span exporters.
+const (
+	// TracingZipkin exports spans to a Zipkin collector.
+	TracingZipkin = "zipkin"
+	// TracingOTLP exports spans to an OpenTelemetry (OTLP/HTTP) collector.
+	TracingOTLP = "otlp"
+	// TracingFile writes spans to a file, one JSON span per line.
+	TracingFile = "file"
+	// TracingStdout writes spans to standard output, one JSON span per line.
+	TracingStdout = "stdout"
+)
+
+// TracingConfig stores span export configuration.
+type TracingConfig struct {
+	// Exporter is one of zipkin, otlp, file or stdout,
+	// spans are not recorded if empty.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the collector URL or the file path.
+	Endpoint string `yaml:"endpoint"`
+	// SampleRate is the ratio of traces recorded, from 0 to 1.
+	// Traces sampled by the caller are always recorded.
+	SampleRate float64 `yaml:"sampleRate"`
 }
 
 // MailerConfig stores maile service providers configurations
@@ -21,26 +87,135 @@ type MailerConfig struct {
 	Providers []ProviderConfig `yaml:"provider"`
 }
 
+// IsDryRun is true if sends of the client must be dry-run.
+func (ac *AppConfig) IsDryRun(clientID string) bool {
+	if ac.DryRun {
+		return true
+	}
+	for _, c := range ac.DryRunClients {
+		if c == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin is true if the client can manage providers.
+func (ac *AppConfig) IsAdmin(clientID string) bool {
+	for _, c := range ac.AdminClients {
+		if c == clientID {
+			return true
+		}
+	}
+	return false
+}
+
 // ServerPortFmt returns a formattes server port.
 func (ac *AppConfig) ServerPortFmt() string {
 	return fmt.Sprintf(":%d", ac.ServerPort)
 }
 
+// PortFmt returns a formatted SMTP server port.
+func (sc *SMTPConfig) PortFmt() string {
+	return fmt.Sprintf(":%d", sc.Port)
+}
+
+// HasTLS is true if a certificate and key are configured.
+func (sc *SMTPConfig) HasTLS() bool {
+	return sc.TLSCert != "" && sc.TLSKey != ""
+}
+
+// AdminPortFmt returns a formatted admin server port.
+func (ac *AppConfig) AdminPortFmt() string {
+	return fmt.Sprintf(":%d", ac.AdminPort)
+}
+
+// GRPCPortFmt returns a formatted gRPC server port.
+func (ac *AppConfig) GRPCPortFmt() string {
+	return fmt.Sprintf(":%d", ac.GRPCPort)
+}
+
 // SenderConfig stores email sender config.
 type SenderConfig struct {
-	Name  string `yaml:"name"`
-	Email string `yaml:"email"`
+	Name  string `yaml:"name" json:"name"`
+	Email string `yaml:"email" json:"email"`
 }
 
 // ProviderConfig stores mail service provider configurations
 type ProviderConfig struct {
-	Name     string       `yaml:"name"`
-	Type     string       `yaml:"type"`
-	Enabled  bool         `yaml:"enabled"`
-	Priority int          `yaml:"priority"`
-	IDKey    string       `yaml:"idKey"`
-	APIKey   string       `yaml:"apiKey"`
-	Sender   SenderConfig `yaml:"sender"`
+	Name     string       `yaml:"name" json:"name"`
+	Type     string       `yaml:"type" json:"type"`
+	Enabled  bool         `yaml:"enabled" json:"enabled"`
+	Priority int          `yaml:"priority" json:"priority"`
+	IDKey    string       `yaml:"idKey" json:"idKey"`
+	APIKey   string       `yaml:"apiKey" json:"apiKey"`
+	Sender   SenderConfig `yaml:"sender" json:"sender"`
+	// Region where the provider API is hosted (i.e.: AWS region).
+	Region string `yaml:"region" json:"region"`
+	// Endpoint overrides the provider API base URL.
+	// Useful to target a local stand-in of the provider.
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+	// RoleARN is an optional role to assume before sending (Amazon SES).
+	RoleARN string `yaml:"roleARN" json:"roleARN"`
+	// ConfigurationSet applied to each sent message (Amazon SES).
+	ConfigurationSet string `yaml:"configurationSet" json:"configurationSet"`
+	// Sandbox validates messages without delivering them (SendGrid).
+	Sandbox bool `yaml:"sandbox" json:"sandbox"`
+	// UnsubscribeGroup is the ASM group ID applied to each sent message (SendGrid).
+	UnsubscribeGroup int `yaml:"unsubscribeGroup" json:"unsubscribeGroup"`
+	// Domain used to send messages (Mailgun).
+	Domain string `yaml:"domain" json:"domain"`
+	// Stream is the message stream used to send messages (Postmark).
+	Stream string `yaml:"stream" json:"stream"`
+	// Format of delivered messages (i.e.: webhook json or rfc822).
+	Format string `yaml:"format" json:"format"`
+	// Timeout of provider requests in seconds.
+	Timeout int `yaml:"timeout" json:"timeout"`
+	// Path where messages are written (i.e.: file provider directory).
+	Path string `yaml:"path" json:"path"`
+	// TestOnly providers are not used to deliver messages
+	// unless the send is a dry-run.
+	TestOnly bool `yaml:"testOnly" json:"testOnly"`
+}
+
+// Masked returns a copy of the config whose secrets are masked.
+func (c Config) Masked() Config {
+	ps := make([]ProviderConfig, len(c.Mailer.Providers))
+	for i, pc := range c.Mailer.Providers {
+		ps[i] = pc.Masked()
+	}
+	c.Mailer.Providers = ps
+	return c
+}
+
+// Masked returns a copy of the provider config whose secrets are masked.
+func (pc ProviderConfig) Masked() ProviderConfig {
+	pc.IDKey = mask(pc.IDKey)
+	pc.APIKey = mask(pc.APIKey)
+	return pc
+}
+
+// maskedValue replaces masked secrets.
+const maskedValue = "[REDACTED]"
+
+// mask masks a secret, if set.
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+// UnmarshalYAML sets provider default values
+// for the keys missing in YAML.
+func (pc *ProviderConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain ProviderConfig
+	p := plain(defaultProvider())
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	*pc = ProviderConfig(p)
+	return nil
 }
 
 type logLevel string
@@ -60,6 +235,30 @@ type LogLevels struct {
 	Fatal logLevel
 }
 
+type logFormat string
+
+// LogFormats let store
+// all valid log output formats.
+type LogFormats struct {
+	// Logfmt output format.
+	Logfmt logFormat
+	// JSON output format.
+	JSON logFormat
+}
+
+type privacyLevel string
+
+// PrivacyLevels let store
+// all valid log privacy levels.
+type PrivacyLevels struct {
+	// None privacy level.
+	None privacyLevel
+	// Truncate privacy level.
+	Truncate privacyLevel
+	// Hash privacy level.
+	Hash privacyLevel
+}
+
 type providerType string
 
 func (pt providerType) String() string {
@@ -73,6 +272,14 @@ type ProviderTypes struct {
 	AmazonSES providerType
 	// SendGrid provider type.
 	SendGrid providerType
+	// Mailgun provider type.
+	Mailgun providerType
+	// Postmark provider type.
+	Postmark providerType
+	// Webhook provider type.
+	Webhook providerType
+	// File provider type.
+	File providerType
 }
 
 // HasProviderType is true if there is configuration for the type of the argument.
@@ -101,6 +308,16 @@ func (c *Config) Provider(pType providerType, name ...string) (pc *ProviderConfi
 	return nil, false
 }
 
+// ProviderByName returns a provider config by its name.
+func (mc *MailerConfig) ProviderByName(name string) (pc *ProviderConfig, ok bool) {
+	for _, pc := range mc.Providers {
+		if pc.Name == name {
+			return &pc, true
+		}
+	}
+	return nil, false
+}
+
 // providerByTypeAndName returns a provider by its type and name
 // Currently two, of different types, ses, sendgrid.
 func (mc *MailerConfig) providerByTypeAndName(pType, name string) (pc *ProviderConfig, ok bool) {
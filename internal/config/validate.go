@@ -0,0 +1,221 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+const (
+	maxPort = 65535
+)
+
+// ValidationError lists every problem found in config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the whole config.
+// It returns a ValidationError listing every problem found.
+func (c *Config) Validate() error {
+	if ps := c.problems(); len(ps) > 0 {
+		return ValidationError{Problems: ps}
+	}
+	return nil
+}
+
+func (c *Config) problems() []string {
+	return append(c.App.problems(), c.Mailer.problems()...)
+}
+
+func (ac *AppConfig) problems() []string {
+	var ps []string
+	add := func(format string, a ...interface{}) {
+		ps = append(ps, fmt.Sprintf(format, a...))
+	}
+
+	if ac.ServerPort < 1 || ac.ServerPort > maxPort {
+		add("app.serverPort: %d is not a valid port", ac.ServerPort)
+	}
+	// gRPC and admin servers are disabled with port zero.
+	if ac.GRPCPort < 0 || ac.GRPCPort > maxPort {
+		add("app.grpcPort: %d is not a valid port", ac.GRPCPort)
+	}
+	if ac.AdminPort < 0 || ac.AdminPort > maxPort {
+		add("app.adminPort: %d is not a valid port", ac.AdminPort)
+	}
+
+	switch ac.LogLevel {
+	case LogLevel.Debug, LogLevel.Info, LogLevel.Warn, LogLevel.Error, LogLevel.Fatal:
+	default:
+		add("app.logLevel: unknown log level '%s'", ac.LogLevel)
+	}
+
+	switch ac.LogFormat {
+	case LogFormat.Logfmt, LogFormat.JSON:
+	default:
+		add("app.logFormat: unknown log format '%s'", ac.LogFormat)
+	}
+
+	switch ac.LogPrivacy {
+	case PrivacyLevel.None, PrivacyLevel.Truncate, PrivacyLevel.Hash:
+	default:
+		add("app.logPrivacy: unknown log privacy level '%s'", ac.LogPrivacy)
+	}
+
+	if ac.SMTP.Enabled && (ac.SMTP.Port < 1 || ac.SMTP.Port > maxPort) {
+		add("app.smtp.port: %d is not a valid port", ac.SMTP.Port)
+	}
+	if (ac.SMTP.TLSCert == "") != (ac.SMTP.TLSKey == "") {
+		add("app.smtp: tlsCert and tlsKey must be set together")
+	}
+	if ac.SMTP.MaxMessageBytes < 0 {
+		add("app.smtp.maxMessageBytes: %d is negative", ac.SMTP.MaxMessageBytes)
+	}
+
+	if ac.IdempotencyTTL < 0 {
+		add("app.idempotencyTTL: %d is negative", ac.IdempotencyTTL)
+	}
+
+	switch ac.Tracing.Exporter {
+	case "", TracingZipkin, TracingOTLP, TracingStdout:
+	case TracingFile:
+		if ac.Tracing.Endpoint == "" {
+			add("app.tracing.endpoint: file path is required by file exporter")
+		}
+	default:
+		add("app.tracing.exporter: unknown exporter '%s'", ac.Tracing.Exporter)
+	}
+	if ac.Tracing.SampleRate < 0 || ac.Tracing.SampleRate > 1 {
+		add("app.tracing.sampleRate: %g is not between 0 and 1", ac.Tracing.SampleRate)
+	}
+
+	return ps
+}
+
+func (mc *MailerConfig) problems() []string {
+	var ps []string
+
+	names := make(map[string]bool)
+	// Test-only providers are used apart from
+	// the rest so their priorities do not collide.
+	priorities := make(map[bool]map[int]string)
+
+	for i, pc := range mc.Providers {
+		id := fmt.Sprintf("mailer.provider[%d]", i)
+		if pc.Name != "" {
+			id += fmt.Sprintf(" '%s'", pc.Name)
+		}
+		add := func(format string, a ...interface{}) {
+			ps = append(ps, id+": "+fmt.Sprintf(format, a...))
+		}
+
+		if pc.Name == "" {
+			add("name is required")
+		} else if names[pc.Name] {
+			add("duplicate name")
+		}
+		names[pc.Name] = true
+
+		if !isProviderType(pc.Type) {
+			add("unknown type '%s'", pc.Type)
+		}
+
+		if pc.Priority < 1 {
+			add("priority %d is not positive", pc.Priority)
+		}
+
+		if pc.Sender.Email != "" {
+			if _, err := mail.ParseAddress(pc.Sender.Email); err != nil {
+				add("invalid sender email '%s'", pc.Sender.Email)
+			}
+		}
+
+		if pc.Endpoint != "" {
+			if u, err := url.Parse(pc.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				add("invalid endpoint '%s'", pc.Endpoint)
+			}
+		}
+
+		if pc.Timeout < 0 {
+			add("timeout %d is negative", pc.Timeout)
+		}
+
+		// Dry-run sends go to test-only providers,
+		// only sinks can be so that they never deliver real mail.
+		if pc.TestOnly && pc.Type != ProviderType.File.String() {
+			add("testOnly is only allowed on %s providers", ProviderType.File)
+		}
+
+		// Disabled providers are not started.
+		if !pc.Enabled {
+			continue
+		}
+
+		if priorities[pc.TestOnly] == nil {
+			priorities[pc.TestOnly] = make(map[int]string)
+		}
+		if other, ok := priorities[pc.TestOnly][pc.Priority]; ok {
+			add("priority %d is also used by '%s'", pc.Priority, other)
+		}
+		priorities[pc.TestOnly][pc.Priority] = pc.Name
+
+		for _, field := range pc.missing() {
+			add("%s is required by %s providers", field, pc.Type)
+		}
+	}
+
+	return ps
+}
+
+// isProviderType is true for known provider types.
+func isProviderType(t string) bool {
+	for _, pt := range []providerType{ProviderType.AmazonSES, ProviderType.SendGrid,
+		ProviderType.Mailgun, ProviderType.Postmark, ProviderType.Webhook, ProviderType.File} {
+		if t == pt.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the credentials and settings
+// required by provider type that are not set.
+func (pc *ProviderConfig) missing() []string {
+	var ms []string
+	required := func(field, value string) {
+		if value == "" {
+			ms = append(ms, field)
+		}
+	}
+
+	switch pc.Type {
+	case ProviderType.AmazonSES.String():
+		// Without keys the default AWS credential chain is used.
+		if pc.IDKey != "" || pc.APIKey != "" {
+			required("idKey", pc.IDKey)
+			required("apiKey", pc.APIKey)
+		}
+	case ProviderType.SendGrid.String(), ProviderType.Postmark.String():
+		required("apiKey", pc.APIKey)
+	case ProviderType.Mailgun.String():
+		required("apiKey", pc.APIKey)
+		required("domain", pc.Domain)
+	case ProviderType.Webhook.String():
+		required("endpoint", pc.Endpoint)
+	}
+
+	return ms
+}
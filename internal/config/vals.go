@@ -9,7 +9,7 @@ package config
 
 const (
 	// Nº max providers
-	n = 2
+	n = 8
 )
 
 var (
@@ -28,6 +28,26 @@ var (
 		Fatal: "fatal",
 	}
 
+	// LogFormat stores all
+	// valid log output formats.
+	LogFormat = LogFormats{
+		// Logfmt - Logfmt key=value output.
+		Logfmt: "logfmt",
+		// JSON - JSON object per line output.
+		JSON: "json",
+	}
+
+	// PrivacyLevel stores all
+	// valid log privacy levels.
+	PrivacyLevel = PrivacyLevels{
+		// None - Addresses and contents are logged as is.
+		None: "none",
+		// Truncate - Addresses and contents are truncated.
+		Truncate: "truncate",
+		// Hash - Addresses are hashed and contents omitted.
+		Hash: "hash",
+	}
+
 	// ProviderType stores all
 	// valid mail Provider types
 	ProviderType = ProviderTypes{
@@ -35,5 +55,13 @@ var (
 		AmazonSES: "amazon-ses",
 		// SendGrid provider type.
 		SendGrid: "sendgrid",
+		// Mailgun provider type.
+		Mailgun: "mailgun",
+		// Postmark provider type.
+		Postmark: "postmark",
+		// Webhook provider type.
+		Webhook: "webhook",
+		// File provider type.
+		File: "file",
 	}
 )
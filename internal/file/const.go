@@ -0,0 +1,18 @@
+package file
+
+const (
+	// FormatEML writes each message as an .eml file.
+	FormatEML = "eml"
+	// FormatMaildir writes each message into a Maildir.
+	FormatMaildir = "maildir"
+	// FormatMbox appends each message to an mbox file.
+	FormatMbox = "mbox"
+	// mboxName is the mbox file name inside provider path.
+	mboxName = "poslan.mbox"
+	// bccHeader keeps Bcc recipients,
+	// otherwise not included in written messages.
+	bccHeader = "X-Poslan-Bcc"
+	// defPath is used when no path is
+	// provided in the provider config.
+	defPath = "mail"
+)
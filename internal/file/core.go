@@ -0,0 +1,237 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package file implements a delivery provider that
+// writes messages to the local filesystem instead of sending them.
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/rfc822"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// FileProvider writes messages as .eml files, into a Maildir
+// or appends them to an mbox file.
+type FileProvider struct {
+	mux      sync.Mutex
+	ctx      context.Context
+	cfg      *config.Config
+	logger   log.Logger
+	name     string
+	priority int
+	path     string
+	format   string
+	hostname string
+}
+
+// Init file mail server handler.
+// If name is provided the provider is built from the
+// file provider config that matches it,
+// otherwise the first one of its type is used.
+func Init(ctx context.Context, cfg *config.Config, log log.Logger, name ...string) (*FileProvider, error) {
+	ok := cfg.HasProviderType(config.ProviderType.File)
+	if !ok {
+		return nil, errors.New("no config associated to a file provider")
+	}
+	return newProvider(ctx, cfg, log, name...)
+}
+
+// Send an email.
+// A message is written for each personalization.
+func (p *FileProvider) Send(em *model.Email) (resend bool, err error) {
+	var sent []int
+	for i, e := range em.Expand() {
+		resend, err = p.send(e)
+		if err != nil {
+			// Report the sent ones so that only the rest is resent.
+			return resend, model.Partial(sent, err)
+		}
+		sent = append(sent, i)
+	}
+	return false, nil
+}
+
+func (p *FileProvider) send(em *model.Email) (resend bool, err error) {
+	e := *em
+	if e.BCC != "" {
+		e.Headers = make(map[string]string)
+		for k, v := range em.Headers {
+			e.Headers[k] = v
+		}
+		e.Headers[bccHeader] = e.BCC
+	}
+
+	data, err := rfc822.Compose(&e)
+	if err != nil {
+		// Malformed message, resending it will not help.
+		return false, fmt.Errorf("cannot compose the email: %s", err.Error())
+	}
+
+	var file string
+	switch p.format {
+	case FormatMaildir:
+		file, err = p.writeMaildir(em, data)
+	case FormatMbox:
+		file, err = p.writeMbox(em, data)
+	default:
+		file, err = p.writeEML(em, data)
+	}
+
+	if err != nil {
+		// Filesystem errors: try another provider.
+		return true, fmt.Errorf("cannot write the email: %s", err.Error())
+	}
+
+	level.Info(p.logger).Log(
+		"package", "file",
+		"method", "send",
+		"file", file,
+	)
+
+	return false, nil
+}
+
+func (p *FileProvider) writeEML(em *model.Email, data []byte) (string, error) {
+	file := filepath.Join(p.path, em.ID.String()+".eml")
+	return file, ioutil.WriteFile(file, data, 0644)
+}
+
+// writeMaildir delivers the message as described in
+// https://cr.yp.to/proto/maildir.html: it is written
+// into 'tmp' and then moved into 'new'.
+func (p *FileProvider) writeMaildir(em *model.Email, data []byte) (string, error) {
+	name := fmt.Sprintf("%d.%s.%s", time.Now().Unix(), em.ID.String(), p.hostname)
+	tmp := filepath.Join(p.path, "tmp", name)
+	file := filepath.Join(p.path, "new", name)
+
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+
+	return file, os.Rename(tmp, file)
+}
+
+// writeMbox appends the message to an mbox file (mboxrd format).
+func (p *FileProvider) writeMbox(em *model.Email, data []byte) (string, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	file := filepath.Join(p.path, mboxName)
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From %s %s\n", em.From, time.Now().UTC().Format(time.ANSIC))
+	for _, l := range bytes.Split(bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1), []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(l, ">"), []byte("From ")) {
+			b.WriteByte('>')
+		}
+		b.Write(l)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	_, err = f.Write(b.Bytes())
+	return file, err
+}
+
+func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger, name ...string) (*FileProvider, error) {
+	p, ok := cfg.Provider(config.ProviderType.File, name...)
+	if !ok {
+		return nil, fmt.Errorf("no provider of type '%s' in config", config.ProviderType.File)
+	}
+
+	path := p.Path
+	if path == "" {
+		path = defPath
+	}
+
+	format := p.Format
+	if format == "" {
+		format = FormatEML
+	}
+
+	dirs := []string{path}
+	switch format {
+	case FormatEML, FormatMbox:
+	case FormatMaildir:
+		dirs = append(dirs,
+			filepath.Join(path, "tmp"),
+			filepath.Join(path, "new"),
+			filepath.Join(path, "cur"))
+	default:
+		return nil, fmt.Errorf("invalid format '%s' for provider '%s'", p.Format, p.Name)
+	}
+
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "poslan"
+	}
+
+	return &FileProvider{
+		ctx:      ctx,
+		cfg:      cfg,
+		logger:   logger,
+		name:     p.Name,
+		priority: p.Priority,
+		path:     path,
+		format:   format,
+		hostname: hostname,
+	}, nil
+}
+
+// Name return the provider name.
+func (p *FileProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority.
+func (p *FileProvider) Priority() int {
+	return p.priority
+}
+
+// Start the mailer.
+func (p *FileProvider) Start() error {
+	return nil
+}
+
+// Stop the mailer.
+func (p *FileProvider) Stop() error {
+	return nil
+}
+
+// IsReady return true if mailer is ready.
+func (p *FileProvider) IsReady() bool {
+	return true
+}
+
+// Client return the provider client.
+func (p *FileProvider) Client() interface{} {
+	return nil
+}
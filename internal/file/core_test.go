@@ -0,0 +1,104 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+// TestSend checks that messages are written in each format.
+func TestSend(t *testing.T) {
+	tests := []struct {
+		format string
+		glob   string
+	}{
+		{FormatEML, "*.eml"},
+		{FormatMaildir, filepath.Join("new", "*")},
+		{FormatMbox, mboxName},
+	}
+
+	for _, tc := range tests {
+		dir, err := ioutil.TempDir("", "poslan-file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		p := newTestProvider(t, dir, tc.format)
+
+		em := &model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com", BCC: "bcc@example.com", Subject: "Subject", Body: "From here"}
+
+		if _, err := p.Send(em); err != nil {
+			t.Fatalf("Format %s - Expected: no error | Received: %s", tc.format, err.Error())
+		}
+
+		files, _ := filepath.Glob(filepath.Join(dir, tc.glob))
+		if len(files) != 1 {
+			t.Fatalf("Format %s - Expected: 1 file | Received: %d", tc.format, len(files))
+		}
+
+		data, _ := ioutil.ReadFile(files[0])
+		if !strings.Contains(string(data), bccHeader+": bcc@example.com") {
+			t.Errorf("Format %s - Expected: '%s' header | Received: '%s'", tc.format, bccHeader, data)
+		}
+	}
+}
+
+// TestSendPartial checks that personalizations written
+// before a failure are reported so that they are not resent.
+func TestSendPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poslan-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com"}
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		em.Personalizations = append(em.Personalizations, model.Personalization{ID: uuid.New(), To: to})
+	}
+
+	// A dir in place of the second message makes its write fail.
+	os.Mkdir(filepath.Join(dir, em.Personalizations[1].ID.String()+".eml"), 0755)
+
+	p := newTestProvider(t, dir, FormatEML)
+	resend, err := p.Send(em)
+
+	pe, ok := err.(*model.PartialError)
+	if !ok || len(pe.Sent) != 1 || pe.Sent[0] != 0 || !resend {
+		t.Errorf("Expected: first sent, resend the rest | Received: %v, resend %t", err, resend)
+	}
+}
+
+func newTestProvider(t *testing.T, path, format string) *FileProvider {
+	cfg := &config.Config{
+		Mailer: config.MailerConfig{
+			Providers: []config.ProviderConfig{
+				{
+					Name:     "sink",
+					Type:     config.ProviderType.File.String(),
+					Enabled:  true,
+					Priority: 1,
+					TestOnly: true,
+					Path:     path,
+					Format:   format,
+				},
+			},
+		},
+	}
+
+	p, err := Init(context.Background(), cfg, log.NewLogfmtLogger(os.Stdout))
+	if err != nil {
+		t.Fatalf("Cannot initialize provider: %s", err.Error())
+	}
+
+	return p
+}
@@ -0,0 +1,310 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package jsonschema validates JSON values against the subset
+// of OpenAPI 3 schema objects used to describe Poslan API.
+// Supported keywords: type, format, required, properties,
+// additionalProperties, items, enum, minLength, maxLength,
+// minItems, maxItems, minimum and maximum.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Schema types
+const (
+	Object  = "object"
+	Array   = "array"
+	String  = "string"
+	Integer = "integer"
+	Number  = "number"
+	Boolean = "boolean"
+)
+
+// Schema formats
+const (
+	// Email is a single RFC 5322 address.
+	Email = "email"
+	// EmailList is a comma separated list of RFC 5322 addresses.
+	EmailList = "email-list"
+	// UUID is an RFC 4122 UUID.
+	UUID = "uuid"
+	// DateTime is an RFC 3339 date-time.
+	DateTime = "date-time"
+	// Duration is a positive Go duration (i.e.: '1h30m').
+	Duration = "duration"
+)
+
+// Schema is an OpenAPI 3 schema object.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is the schema of properties not in Properties.
+	// If nil they are not allowed in objects with properties.
+	AdditionalProperties *Schema     `json:"additionalProperties,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	MinLength            *int        `json:"minLength,omitempty"`
+	MaxLength            *int        `json:"maxLength,omitempty"`
+	MinItems             *int        `json:"minItems,omitempty"`
+	MaxItems             *int        `json:"maxItems,omitempty"`
+	Minimum              *float64    `json:"minimum,omitempty"`
+	Maximum              *float64    `json:"maximum,omitempty"`
+	Example              interface{} `json:"example,omitempty"`
+}
+
+// FieldError is a validation error of a field.
+type FieldError struct {
+	// Field is the dot separated path to the field (i.e.: 'personalizations.0.to').
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (fe FieldError) Error() string {
+	if fe.Field == "" {
+		return fe.Message
+	}
+	return fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+}
+
+// MarshalJSON encodes the schema making explicit
+// that objects do not allow unknown properties.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type schema Schema
+	v := struct {
+		*schema
+		AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	}{
+		schema: (*schema)(s),
+	}
+
+	switch {
+	case s.AdditionalProperties != nil:
+		v.AdditionalProperties = s.AdditionalProperties
+	case s.Type == Object:
+		v.AdditionalProperties = false
+	}
+
+	return json.Marshal(v)
+}
+
+// Int returns a pointer to an int,
+// useful to set length and item limits.
+func Int(i int) *int {
+	return &i
+}
+
+// Float returns a pointer to a float64,
+// useful to set minimum and maximum.
+func Float(f float64) *float64 {
+	return &f
+}
+
+// ValidateJSON decodes and validates a JSON document.
+func (s *Schema) ValidateJSON(data []byte) []FieldError {
+	var v interface{}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return []FieldError{{Message: fmt.Sprintf("invalid JSON: %s", err.Error())}}
+	}
+
+	return s.Validate(v)
+}
+
+// Validate validates a decoded JSON value.
+// Numbers are expected as json.Number or float64.
+func (s *Schema) Validate(v interface{}) []FieldError {
+	var errs []FieldError
+	s.validate("", v, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]FieldError) {
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case Object:
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			add("must be an object")
+			return
+		}
+		s.validateObject(path, o, errs)
+
+	case Array:
+		a, ok := v.([]interface{})
+		if !ok {
+			add("must be an array")
+			return
+		}
+		if s.MinItems != nil && len(a) < *s.MinItems {
+			add("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(a) > *s.MaxItems {
+			add("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range a {
+				s.Items.validate(join(path, fmt.Sprintf("%d", i)), item, errs)
+			}
+		}
+
+	case String:
+		str, ok := v.(string)
+		if !ok {
+			add("must be a string")
+			return
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				add("must not be empty")
+			} else {
+				add("must be at least %d characters long", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add("must be at most %d characters long", *s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			add("must be one of: %s", strings.Join(s.Enum, ", "))
+		}
+		if msg := checkFormat(s.Format, str); msg != "" {
+			add(msg)
+		}
+
+	case Integer, Number:
+		f, ok := number(v)
+		if !ok {
+			add("must be a number")
+			return
+		}
+		if s.Type == Integer && f != float64(int64(f)) {
+			add("must be an integer")
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			add("must be greater than or equal to %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			add("must be less than or equal to %v", *s.Maximum)
+		}
+
+	case Boolean:
+		if _, ok := v.(bool); !ok {
+			add("must be a boolean")
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, o map[string]interface{}, errs *[]FieldError) {
+	for _, r := range s.Required {
+		if _, ok := o[r]; !ok {
+			*errs = append(*errs, FieldError{Field: join(path, r), Message: "is required"})
+		}
+	}
+
+	// Sorted for stable error messages.
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ps, ok := s.Properties[k]
+		if !ok {
+			ps = s.AdditionalProperties
+		}
+
+		if ps == nil {
+			*errs = append(*errs, FieldError{Field: join(path, k), Message: "unknown field"})
+			continue
+		}
+
+		// JSON null is accepted as an absent optional field.
+		if o[k] == nil && !contains(s.Required, k) {
+			continue
+		}
+
+		ps.validate(join(path, k), o[k], errs)
+	}
+}
+
+func checkFormat(format, str string) (msg string) {
+	if str == "" {
+		return ""
+	}
+
+	switch format {
+	case Email:
+		if _, err := mail.ParseAddress(str); err != nil {
+			return "must be a valid email address"
+		}
+	case EmailList:
+		if _, err := mail.ParseAddressList(str); err != nil {
+			return "must be a comma separated list of valid email addresses"
+		}
+	case UUID:
+		if _, err := uuid.Parse(str); err != nil {
+			return "must be a valid UUID"
+		}
+	case DateTime:
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	case Duration:
+		if d, err := time.ParseDuration(str); err != nil || d <= 0 {
+			return "must be a positive duration (i.e.: '1h30m')"
+		}
+	}
+	return ""
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
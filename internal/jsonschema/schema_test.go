@@ -0,0 +1,87 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testSchema = &Schema{
+	Type:     Object,
+	Required: []string{"to"},
+	Properties: map[string]*Schema{
+		"to":      {Type: String, Format: EmailList, MinLength: Int(1)},
+		"subject": {Type: String, MaxLength: Int(5)},
+		"count":   {Type: Integer, Minimum: Float(1)},
+		"sendAt":  {Type: String, Format: DateTime},
+		"delay":   {Type: String, Format: Duration},
+		"tags":    {Type: Array, MaxItems: Int(1), Items: &Schema{Type: String}},
+		"headers": {Type: Object, AdditionalProperties: &Schema{Type: String}},
+		"items": {
+			Type: Array,
+			Items: &Schema{
+				Type:     Object,
+				Required: []string{"id"},
+				Properties: map[string]*Schema{
+					"id": {Type: String, Format: UUID},
+				},
+			},
+		},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		errs []string
+	}{
+		{"valid", `{"to": "Jane <jane@example.com>, john@example.com", "count": 2, "headers": {"X-A": "a"}}`, nil},
+		{"null optional", `{"to": "jane@example.com", "subject": null}`, nil},
+		{"required", `{}`, []string{"to: is required"}},
+		{"empty", `{"to": ""}`, []string{"to: must not be empty"}},
+		{"format", `{"to": "jane"}`, []string{"to: must be a comma separated list of valid email addresses"}},
+		{"type", `{"to": 1}`, []string{"to: must be a string"}},
+		{"max length", `{"to": "jane@example.com", "subject": "Subject"}`, []string{"subject: must be at most 5 characters long"}},
+		{"integer", `{"to": "jane@example.com", "count": 1.5}`, []string{"count: must be an integer"}},
+		{"minimum", `{"to": "jane@example.com", "count": 0}`, []string{"count: must be greater than or equal to 1"}},
+		{"date-time", `{"to": "jane@example.com", "sendAt": "2019-06-01 10:00"}`, []string{"sendAt: must be an RFC 3339 date-time"}},
+		{"duration", `{"to": "jane@example.com", "delay": "-1h"}`, []string{"delay: must be a positive duration (i.e.: '1h30m')"}},
+		{"max items", `{"to": "jane@example.com", "tags": ["a", "b"]}`, []string{"tags: must have at most 1 items"}},
+		{"additional properties", `{"to": "jane@example.com", "headers": {"X-A": 1}}`, []string{"headers.X-A: must be a string"}},
+		{"unknown field", `{"to": "jane@example.com", "username": "jane"}`, []string{"username: unknown field"}},
+		{"nested", `{"to": "jane@example.com", "items": [{"id": "invalid"}, {}]}`, []string{"items.0.id: must be a valid UUID", "items.1.id: is required"}},
+	}
+
+	for _, tt := range tests {
+		errs := testSchema.ValidateJSON([]byte(tt.json))
+
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+
+		if strings.Join(msgs, "|") != strings.Join(tt.errs, "|") {
+			t.Errorf("%s - Expected: %v | Received: %v", tt.name, tt.errs, msgs)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(testSchema)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+
+	if m["additionalProperties"] != false {
+		t.Errorf("Expected: additionalProperties false | Received: %v", m["additionalProperties"])
+	}
+
+	headers := m["properties"].(map[string]interface{})["headers"].(map[string]interface{})
+	if ap, ok := headers["additionalProperties"].(map[string]interface{}); !ok || ap["type"] != "string" {
+		t.Errorf("Expected: additionalProperties string schema | Received: %v", headers["additionalProperties"])
+	}
+}
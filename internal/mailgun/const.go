@@ -0,0 +1,21 @@
+package mailgun
+
+const (
+	// usBaseURL is Mailgun API base URL for US region.
+	usBaseURL = "https://api.mailgun.net"
+	// euBaseURL is Mailgun API base URL for EU region.
+	euBaseURL = "https://api.eu.mailgun.net"
+	// euRegion is the provider config region value
+	// that selects Mailgun EU API, any other targets US.
+	euRegion = "eu"
+	// apiUser is the basic auth user of Mailgun API.
+	apiUser = "api"
+	// messageIDVar is the custom variable that
+	// carries poslan message ID.
+	messageIDVar = "poslan_message_id"
+	// requestIDVar is the custom variable that
+	// carries poslan request ID.
+	requestIDVar = "poslan_request_id"
+	// timeout of Mailgun API requests in seconds.
+	timeout = 30
+)
@@ -0,0 +1,270 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/rfc822"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// MGProvider is a Mailgun delivery provider.
+type MGProvider struct {
+	ctx      context.Context
+	cfg      *config.Config
+	logger   log.Logger
+	client   *http.Client
+	name     string
+	priority int
+	baseURL  string
+	domain   string
+	apiKey   string
+}
+
+// Init Mailgun mail server handler.
+// If name is provided the provider is built from the
+// Mailgun provider config that matches it,
+// otherwise the first one of its type is used.
+func Init(ctx context.Context, cfg *config.Config, log log.Logger, name ...string) (*MGProvider, error) {
+	ok := cfg.HasProviderType(config.ProviderType.Mailgun)
+	if !ok {
+		return nil, errors.New("no config associated to a Mailgun provider")
+	}
+	return newProvider(ctx, cfg, log, name...)
+}
+
+// Send an email.
+// An individual message is sent for each personalization.
+func (p *MGProvider) Send(em *model.Email) (resend bool, err error) {
+	var sent []int
+	for i, e := range em.Expand() {
+		resend, err = p.send(e)
+		if err != nil {
+			// Report the sent ones so that only the rest is resent.
+			return resend, model.Partial(sent, err)
+		}
+		sent = append(sent, i)
+	}
+	return false, nil
+}
+
+func (p *MGProvider) send(em *model.Email) (resend bool, err error) {
+	body, ct, err := newMGEmail(em)
+	if err != nil {
+		// Malformed message, resending it will not help.
+		return false, fmt.Errorf("cannot compose the email: %s", err.Error())
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.messagesURL(), body)
+	if err != nil {
+		return true, fmt.Errorf("cannot send the email: %s", err.Error())
+	}
+	req = req.WithContext(p.ctx)
+	req.Header.Set("Content-Type", ct)
+	req.SetBasicAuth(apiUser, p.apiKey)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		// Network errors: try again.
+		return true, fmt.Errorf("cannot send the email: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+
+	resend, err = statusError(res.StatusCode, msg)
+	if err != nil {
+		return resend, err
+	}
+
+	level.Info(p.logger).Log(
+		"package", "mailgun",
+		"method", "send",
+		"result", strings.TrimSpace(string(msg)),
+	)
+
+	return false, nil
+}
+
+// statusError maps Mailgun API response status codes
+// onto the provider resend contract.
+// Ref.: https://documentation.mailgun.com/en/latest/api-intro.html#errors
+func statusError(status int, msg []byte) (resend bool, err error) {
+	switch {
+	case status == http.StatusOK:
+		return false, nil
+
+	case status == http.StatusBadRequest:
+		// Malformed request (i.e.: missing parameter, invalid address)
+		// another provider will not accept it either.
+		return false, fmt.Errorf("bad request: %s", msg)
+
+	case status == http.StatusRequestEntityTooLarge:
+		// Message too large.
+		return false, fmt.Errorf("message too large: %s", msg)
+
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		// Invalid API key, try another provider.
+		return true, fmt.Errorf("unauthorized: %s", msg)
+
+	case status == http.StatusNotFound:
+		// Domain not found, configuration error: try another provider.
+		return true, fmt.Errorf("domain not found: %s", msg)
+
+	case status == http.StatusTooManyRequests:
+		// Rate limited.
+		return true, fmt.Errorf("too many requests: %s", msg)
+
+	default:
+		// 402 Request failed, 5xx Server errors
+		// and any other unexpected status.
+		return true, fmt.Errorf("cannot send email - status code: '%d'", status)
+	}
+}
+
+// newMGEmail assembles a Mailgun multipart form message.
+// Custom headers are sent as 'h:' fields, tags as 'o:tag'
+// and metadata as 'v:' custom variables so that they are included
+// in Mailgun events along with poslan message ID.
+func newMGEmail(em *model.Email) (body *bytes.Buffer, contentType string, err error) {
+	body = &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	from := em.From
+	if em.Name != "" {
+		from = fmt.Sprintf("%s <%s>", em.Name, em.From)
+	}
+
+	fields := [][2]string{
+		{"from", from},
+		{"to", em.To},
+		{"cc", em.CC},
+		{"bcc", em.BCC},
+		{"subject", em.Subject},
+		{"text", em.Body},
+		{"html", htmlBody(em.Body)},
+		{"h:Reply-To", em.ReplyTo},
+		{"h:Message-Id", rfc822.MessageID(em)},
+		{"v:" + messageIDVar, em.ID.String()},
+		{"v:" + requestIDVar, em.RequestID},
+	}
+
+	for k, v := range em.Headers {
+		fields = append(fields, [2]string{"h:" + k, v})
+	}
+
+	for _, t := range em.Tags {
+		fields = append(fields, [2]string{"o:tag", t})
+	}
+
+	for k, v := range em.Metadata {
+		fields = append(fields, [2]string{"v:" + k, v})
+	}
+
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body, w.FormDataContentType(), nil
+}
+
+func htmlBody(body string) string {
+	return fmt.Sprintf("<html><body><div>%s</div></body></html>", html.EscapeString(body))
+}
+
+func (p *MGProvider) messagesURL() string {
+	return fmt.Sprintf("%s/v3/%s/messages", p.baseURL, p.domain)
+}
+
+func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger, name ...string) (*MGProvider, error) {
+	p, ok := cfg.Provider(config.ProviderType.Mailgun, name...)
+	if !ok {
+		return nil, fmt.Errorf("no provider of type '%s' in config", config.ProviderType.Mailgun)
+	}
+
+	if p.Domain == "" {
+		return nil, fmt.Errorf("no domain configured for provider '%s'", p.Name)
+	}
+
+	return &MGProvider{
+		ctx:      ctx,
+		cfg:      cfg,
+		logger:   logger,
+		client:   &http.Client{Timeout: timeout * time.Second},
+		name:     p.Name,
+		priority: p.Priority,
+		baseURL:  baseURL(p),
+		domain:   p.Domain,
+		apiKey:   p.APIKey,
+	}, nil
+}
+
+// baseURL returns provider config endpoint if provided,
+// otherwise the Mailgun API base URL of its region.
+func baseURL(p *config.ProviderConfig) string {
+	if p.Endpoint != "" {
+		return strings.TrimSuffix(p.Endpoint, "/")
+	}
+	if strings.ToLower(p.Region) == euRegion {
+		return euBaseURL
+	}
+	return usBaseURL
+}
+
+// Name return the provider name.
+func (p *MGProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority.
+func (p *MGProvider) Priority() int {
+	return p.priority
+}
+
+// Start the mailer.
+func (p *MGProvider) Start() error {
+	return nil
+}
+
+// Stop the mailer.
+func (p *MGProvider) Stop() error {
+	return nil
+}
+
+// IsReady return true if mailer is ready.
+func (p *MGProvider) IsReady() bool {
+	return true
+}
+
+// Client return the provider client.
+func (p *MGProvider) Client() interface{} {
+	return p.client
+}
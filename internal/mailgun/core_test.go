@@ -0,0 +1,194 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+const (
+	testDomain = "mg.example.com"
+	testAPIKey = "key-test"
+)
+
+// TestSend checks the message sent to a local Mailgun API stand-in.
+func TestSend(t *testing.T) {
+	var received *http.Request
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		received = r
+		w.Write([]byte(`{"id":"<1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.URL)
+
+	em := &model.Email{
+		ID:        uuid.New(),
+		Name:      "Sender",
+		From:      "sender@example.com",
+		To:        "to@example.com",
+		CC:        "cc@example.com",
+		ReplyTo:   "reply@example.com",
+		Subject:   "Subject",
+		Body:      "Body",
+		Headers:   map[string]string{"X-Campaign": "welcome"},
+		Metadata:  map[string]string{"campaign": "welcome"},
+		Tags:      []string{"welcome"},
+		RequestID: "req-1",
+	}
+
+	resend, err := p.Send(em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if resend {
+		t.Error("Expected: no resend | Received: resend")
+	}
+
+	if received.URL.Path != "/v3/"+testDomain+"/messages" {
+		t.Errorf("Expected: '/v3/%s/messages' | Received: '%s'", testDomain, received.URL.Path)
+	}
+
+	user, key, _ := received.BasicAuth()
+	if user != apiUser || key != testAPIKey {
+		t.Errorf("Expected: '%s:%s' | Received: '%s:%s'", apiUser, testAPIKey, user, key)
+	}
+
+	fields := map[string]string{
+		"from":              "Sender <sender@example.com>",
+		"to":                "to@example.com",
+		"cc":                "cc@example.com",
+		"subject":           "Subject",
+		"text":              "Body",
+		"h:Reply-To":        "reply@example.com",
+		"h:X-Campaign":      "welcome",
+		"o:tag":             "welcome",
+		"v:campaign":        "welcome",
+		"v:" + messageIDVar: em.ID.String(),
+		"v:" + requestIDVar: "req-1",
+	}
+
+	for k, v := range fields {
+		if received.FormValue(k) != v {
+			t.Errorf("Expected: %s='%s' | Received: %s='%s'", k, v, k, received.FormValue(k))
+		}
+	}
+}
+
+// TestSendErrors checks Mailgun errors mapping onto resend contract.
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		resend bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, true},
+		{http.StatusPaymentRequired, true},
+		{http.StatusNotFound, true},
+		{http.StatusRequestEntityTooLarge, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+	}
+
+	for _, tc := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			w.Write([]byte(`{"message":"error"}`))
+		}))
+
+		p := newTestProvider(t, ts.URL)
+		resend, err := p.Send(&model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com"})
+
+		if err == nil {
+			t.Errorf("Status %d - Expected: error | Received: no error", tc.status)
+		}
+
+		if resend != tc.resend {
+			t.Errorf("Status %d - Expected: resend %t | Received: resend %t", tc.status, tc.resend, resend)
+		}
+
+		ts.Close()
+	}
+}
+
+// TestSendPartial checks that personalizations sent
+// before a failure are reported so that they are not resent.
+func TestSendPartial(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"id":"<1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer ts.Close()
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com"}
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		em.Personalizations = append(em.Personalizations, model.Personalization{ID: uuid.New(), To: to})
+	}
+
+	p := newTestProvider(t, ts.URL)
+	resend, err := p.Send(em)
+
+	pe, ok := err.(*model.PartialError)
+	if !ok || len(pe.Sent) != 1 || pe.Sent[0] != 0 || !resend {
+		t.Errorf("Expected: first sent, resend the rest | Received: %v, resend %t", err, resend)
+	}
+}
+
+// TestBaseURL checks region and endpoint selection.
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		pc  config.ProviderConfig
+		url string
+	}{
+		{config.ProviderConfig{}, usBaseURL},
+		{config.ProviderConfig{Region: "us"}, usBaseURL},
+		{config.ProviderConfig{Region: "EU"}, euBaseURL},
+		{config.ProviderConfig{Region: "eu", Endpoint: "http://localhost:8025/"}, "http://localhost:8025"},
+	}
+
+	for _, tc := range tests {
+		if u := baseURL(&tc.pc); u != tc.url {
+			t.Errorf("Expected: '%s' | Received: '%s'", tc.url, u)
+		}
+	}
+}
+
+func newTestProvider(t *testing.T, endpoint string) *MGProvider {
+	cfg := &config.Config{
+		Mailer: config.MailerConfig{
+			Providers: []config.ProviderConfig{
+				{
+					Name:     "mailgun",
+					Type:     config.ProviderType.Mailgun.String(),
+					Enabled:  true,
+					Priority: 1,
+					APIKey:   testAPIKey,
+					Domain:   testDomain,
+					Endpoint: endpoint,
+				},
+			},
+		},
+	}
+
+	p, err := Init(context.Background(), cfg, log.NewLogfmtLogger(os.Stdout))
+	if err != nil {
+		t.Fatalf("Cannot initialize provider: %s", err.Error())
+	}
+
+	return p
+}
@@ -0,0 +1,174 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package otlp encodes Zipkin spans as OpenTelemetry (OTLP/HTTP JSON)
+// trace export requests so that they can be sent to any OTLP collector
+// using the Zipkin HTTP reporter.
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// OTLP span kinds.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+	spanKindProducer = 4
+	spanKindConsumer = 5
+)
+
+// OTLP status codes.
+const (
+	statusCodeUnset = 0
+	statusCodeError = 2
+)
+
+// errorTag is set by Zipkin spans that failed.
+const errorTag = "error"
+
+// Serializer encodes spans as an OTLP export request.
+// It implements Zipkin reporter.SpanSerializer.
+type Serializer struct {
+	// ServiceName is set as the service.name resource attribute.
+	ServiceName string
+}
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Serialize encodes spans as an OTLP export request.
+func (s Serializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
+	ss := make([]span, 0, len(spans))
+	for _, sm := range spans {
+		ss = append(ss, convert(sm))
+	}
+
+	req := exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{
+				Attributes: []keyValue{attribute("service.name", s.ServiceName)},
+			},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: s.ServiceName},
+				Spans: ss,
+			}},
+		}},
+	}
+
+	return json.Marshal(req)
+}
+
+// ContentType returns the content type of serialized spans.
+func (Serializer) ContentType() string {
+	return "application/json"
+}
+
+func convert(sm *model.SpanModel) span {
+	start := sm.Timestamp.UnixNano()
+
+	sp := span{
+		// OTLP trace IDs are always 128 bits long.
+		TraceID:           fmt.Sprintf("%016x%016x", sm.TraceID.High, sm.TraceID.Low),
+		SpanID:            sm.ID.String(),
+		Name:              sm.Name,
+		Kind:              kind(sm.Kind),
+		StartTimeUnixNano: strconv.FormatInt(start, 10),
+		EndTimeUnixNano:   strconv.FormatInt(start+sm.Duration.Nanoseconds(), 10),
+		Status:            status{Code: statusCodeUnset},
+	}
+
+	if sm.ParentID != nil {
+		sp.ParentSpanID = sm.ParentID.String()
+	}
+
+	keys := make([]string, 0, len(sm.Tags))
+	for k := range sm.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == errorTag {
+			sp.Status = status{Code: statusCodeError, Message: sm.Tags[k]}
+			continue
+		}
+		sp.Attributes = append(sp.Attributes, attribute(k, sm.Tags[k]))
+	}
+
+	return sp
+}
+
+func kind(k model.Kind) int {
+	switch k {
+	case model.Server:
+		return spanKindServer
+	case model.Client:
+		return spanKindClient
+	case model.Producer:
+		return spanKindProducer
+	case model.Consumer:
+		return spanKindConsumer
+	default:
+		return spanKindInternal
+	}
+}
+
+func attribute(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: value}}
+}
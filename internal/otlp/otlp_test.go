@@ -0,0 +1,66 @@
+package otlp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// TestSerialize checks that spans are encoded as an OTLP export request.
+func TestSerialize(t *testing.T) {
+	parent := model.ID(0x0102030405060708)
+	start := time.Unix(1560000000, 0)
+
+	spans := []*model.SpanModel{{
+		SpanContext: model.SpanContext{
+			TraceID:  model.TraceID{High: 0x0af7651916cd43dd, Low: 0x8448eb211c80319c},
+			ID:       model.ID(0xb7ad6b7169203331),
+			ParentID: &parent,
+		},
+		Name:      "send amazon",
+		Kind:      model.Client,
+		Timestamp: start,
+		Duration:  time.Second,
+		Tags:      map[string]string{"provider": "amazon", "error": "rejected"},
+	}}
+
+	b, err := Serializer{ServiceName: "poslan"}.Serialize(spans)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	var req exportRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	rs := req.ResourceSpans[0]
+	if rs.Resource.Attributes[0] != attribute("service.name", "poslan") {
+		t.Errorf("Expected: service.name 'poslan' | Received: %+v", rs.Resource.Attributes)
+	}
+
+	s := rs.ScopeSpans[0].Spans[0]
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		received interface{}
+	}{
+		{"trace ID", "0af7651916cd43dd8448eb211c80319c", s.TraceID},
+		{"span ID", "b7ad6b7169203331", s.SpanID},
+		{"parent ID", "0102030405060708", s.ParentSpanID},
+		{"kind", spanKindClient, s.Kind},
+		{"start", "1560000000000000000", s.StartTimeUnixNano},
+		{"end", "1560000001000000000", s.EndTimeUnixNano},
+		{"status", status{Code: statusCodeError, Message: "rejected"}, s.Status},
+		{"attributes", 1, len(s.Attributes)},
+	}
+
+	for _, tt := range tests {
+		if tt.expected != tt.received {
+			t.Errorf("%s - Expected: %v | Received: %v", tt.name, tt.expected, tt.received)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package postmark
+
+const (
+	// baseURL is Postmark API base URL.
+	baseURL = "https://api.postmarkapp.com"
+	// tokenHeader is the request header
+	// that carries Postmark server token.
+	tokenHeader = "X-Postmark-Server-Token"
+	// defStream is used when no message stream
+	// is provided in the provider config.
+	defStream = "outbound"
+	// maxBatch is the max number of messages
+	// accepted by Postmark batch API.
+	maxBatch = 500
+	// messageIDKey is the metadata key that
+	// carries poslan message ID.
+	messageIDKey = "poslan_message_id"
+	// requestIDKey is the metadata key that
+	// carries poslan request ID.
+	requestIDKey = "poslan_request_id"
+	// timeout of Postmark API requests in seconds.
+	timeout = 30
+)
+
+// Postmark API error codes.
+// Ref.: https://postmarkapp.com/developer/api/overview#error-codes
+const (
+	errBadToken             = 10
+	errInvalidRequest       = 300
+	errSenderNotFound       = 400
+	errSenderNotConfirmed   = 401
+	errInvalidJSON          = 402
+	errNotAllowedToSend     = 405
+	errInactiveRecipient    = 406
+	errJSONRequired         = 409
+	errTooManyBatchMessages = 410
+)
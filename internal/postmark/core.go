@@ -0,0 +1,345 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package postmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// PMProvider is a Postmark delivery provider.
+type PMProvider struct {
+	ctx      context.Context
+	cfg      *config.Config
+	logger   log.Logger
+	client   *http.Client
+	name     string
+	priority int
+	baseURL  string
+	token    string
+	stream   string
+}
+
+// message is a Postmark API email.
+type message struct {
+	From          string            `json:"From"`
+	To            string            `json:"To,omitempty"`
+	Cc            string            `json:"Cc,omitempty"`
+	Bcc           string            `json:"Bcc,omitempty"`
+	ReplyTo       string            `json:"ReplyTo,omitempty"`
+	Subject       string            `json:"Subject"`
+	TextBody      string            `json:"TextBody,omitempty"`
+	HTMLBody      string            `json:"HtmlBody,omitempty"`
+	Tag           string            `json:"Tag,omitempty"`
+	Headers       []header          `json:"Headers,omitempty"`
+	Metadata      map[string]string `json:"Metadata,omitempty"`
+	MessageStream string            `json:"MessageStream,omitempty"`
+}
+
+type header struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// result is a Postmark API send response.
+type result struct {
+	To        string `json:"To"`
+	MessageID string `json:"MessageID"`
+	ErrorCode int    `json:"ErrorCode"`
+	Message   string `json:"Message"`
+}
+
+// Init Postmark mail server handler.
+// If name is provided the provider is built from the
+// Postmark provider config that matches it,
+// otherwise the first one of its type is used.
+func Init(ctx context.Context, cfg *config.Config, log log.Logger, name ...string) (*PMProvider, error) {
+	ok := cfg.HasProviderType(config.ProviderType.Postmark)
+	if !ok {
+		return nil, errors.New("no config associated to a Postmark provider")
+	}
+	return newProvider(ctx, cfg, log, name...)
+}
+
+// Send an email.
+// Emails with personalizations are sent using batch API.
+func (p *PMProvider) Send(em *model.Email) (resend bool, err error) {
+	es := em.Expand()
+	if len(es) == 1 {
+		return p.send(es[0])
+	}
+	return p.SendBatch(es)
+}
+
+func (p *PMProvider) send(em *model.Email) (resend bool, err error) {
+	var r result
+	resend, err = p.post("/email", p.newPMEmail(em), &r)
+	if err != nil {
+		return resend, err
+	}
+
+	if r.ErrorCode != 0 {
+		return codeError(r.ErrorCode, r.Message)
+	}
+
+	level.Info(p.logger).Log(
+		"package", "postmark",
+		"method", "send",
+		"result", r.MessageID,
+	)
+
+	return false, nil
+}
+
+// SendBatch sends emails using Postmark batch API.
+// Messages are split in batches of up to maxBatch messages.
+// Error reports the first rejected message, resend is only
+// true if all rejected messages can be resent.
+// If some messages were accepted the error is a partial one
+// so that only the rejected and unsent ones are resent.
+func (p *PMProvider) SendBatch(ems []*model.Email) (resend bool, err error) {
+	var sent []int
+	resend = true
+	for i := 0; i < len(ems); i += maxBatch {
+		end := i + maxBatch
+		if end > len(ems) {
+			end = len(ems)
+		}
+
+		ms := make([]*message, 0, end-i)
+		for _, em := range ems[i:end] {
+			ms = append(ms, p.newPMEmail(em))
+		}
+
+		var rs []result
+		rsnd, perr := p.post("/email/batch", ms, &rs)
+		if perr != nil {
+			if err == nil {
+				err = perr
+			}
+			return resend && rsnd, model.Partial(sent, err)
+		}
+
+		// Results are in the same order as messages.
+		for k, r := range rs {
+			if r.ErrorCode == 0 {
+				sent = append(sent, i+k)
+				continue
+			}
+
+			rsnd, rerr := codeError(r.ErrorCode, r.Message)
+			if err == nil {
+				err = fmt.Errorf("'%s': %s", r.To, rerr.Error())
+			}
+			resend = resend && rsnd
+		}
+	}
+
+	if err != nil {
+		return resend, model.Partial(sent, err)
+	}
+
+	return false, nil
+}
+
+// post sends a request to Postmark API and
+// decodes its response into res.
+func (p *PMProvider) post(path string, body, res interface{}) (resend bool, err error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		// Malformed message, resending it will not help.
+		return false, fmt.Errorf("cannot compose the email: %s", err.Error())
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return true, fmt.Errorf("cannot send the email: %s", err.Error())
+	}
+	req = req.WithContext(p.ctx)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(tokenHeader, p.token)
+
+	r, err := p.client.Do(req)
+	if err != nil {
+		// Network errors: try again.
+		return true, fmt.Errorf("cannot send the email: %s", err.Error())
+	}
+	defer r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(r.Body).Decode(res); err != nil {
+			// Sent but response cannot be read,
+			// resending could duplicate the message.
+			return false, fmt.Errorf("cannot read response: %s", err.Error())
+		}
+		return false, nil
+
+	case http.StatusUnauthorized, http.StatusUnprocessableEntity:
+		// Postmark error code details the reason.
+		var e result
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			return true, fmt.Errorf("cannot send email - status code: '%d'", r.StatusCode)
+		}
+		return codeError(e.ErrorCode, e.Message)
+
+	default:
+		// 429 Rate limit exceeded, 5xx Server errors
+		// and any other unexpected status.
+		return true, fmt.Errorf("cannot send email - status code: '%d'", r.StatusCode)
+	}
+}
+
+// codeError maps Postmark API error codes
+// onto the provider resend contract.
+func codeError(code int, msg string) (resend bool, err error) {
+	err = fmt.Errorf("error code %d: %s", code, msg)
+
+	switch code {
+	case errInactiveRecipient:
+		// Recipient hard bounced or marked as spam,
+		// permanent error: no other provider should try.
+		return false, err
+
+	case errInvalidRequest, errInvalidJSON, errJSONRequired, errTooManyBatchMessages:
+		// Malformed request.
+		return false, err
+
+	case errBadToken, errSenderNotFound, errSenderNotConfirmed, errNotAllowedToSend:
+		// Provider account or config error: try another provider.
+		return true, err
+
+	default:
+		return true, err
+	}
+}
+
+// newPMEmail assembles a Postmark API email.
+// Postmark only accepts a single tag per message so only
+// the first one is sent, metadata is sent along with
+// poslan message ID.
+func (p *PMProvider) newPMEmail(em *model.Email) *message {
+	from := em.From
+	if em.Name != "" {
+		from = fmt.Sprintf("%s <%s>", em.Name, em.From)
+	}
+
+	m := &message{
+		From:          from,
+		To:            em.To,
+		Cc:            em.CC,
+		Bcc:           em.BCC,
+		ReplyTo:       em.ReplyTo,
+		Subject:       em.Subject,
+		TextBody:      em.Body,
+		HTMLBody:      htmlBody(em.Body),
+		MessageStream: p.stream,
+		Metadata:      map[string]string{messageIDKey: em.ID.String()},
+	}
+
+	if len(em.Tags) > 0 {
+		m.Tag = em.Tags[0]
+	}
+
+	for k, v := range em.Metadata {
+		m.Metadata[k] = v
+	}
+
+	if em.RequestID != "" {
+		m.Metadata[requestIDKey] = em.RequestID
+	}
+
+	names := make([]string, 0, len(em.Headers))
+	for k := range em.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		m.Headers = append(m.Headers, header{Name: k, Value: em.Headers[k]})
+	}
+
+	return m
+}
+
+func htmlBody(body string) string {
+	return fmt.Sprintf("<html><body><div>%s</div></body></html>", html.EscapeString(body))
+}
+
+func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger, name ...string) (*PMProvider, error) {
+	p, ok := cfg.Provider(config.ProviderType.Postmark, name...)
+	if !ok {
+		return nil, fmt.Errorf("no provider of type '%s' in config", config.ProviderType.Postmark)
+	}
+
+	url := baseURL
+	if p.Endpoint != "" {
+		url = strings.TrimSuffix(p.Endpoint, "/")
+	}
+
+	stream := p.Stream
+	if stream == "" {
+		stream = defStream
+	}
+
+	return &PMProvider{
+		ctx:      ctx,
+		cfg:      cfg,
+		logger:   logger,
+		client:   &http.Client{Timeout: timeout * time.Second},
+		name:     p.Name,
+		priority: p.Priority,
+		baseURL:  url,
+		token:    p.APIKey,
+		stream:   stream,
+	}, nil
+}
+
+// Name return the provider name.
+func (p *PMProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority.
+func (p *PMProvider) Priority() int {
+	return p.priority
+}
+
+// Start the mailer.
+func (p *PMProvider) Start() error {
+	return nil
+}
+
+// Stop the mailer.
+func (p *PMProvider) Stop() error {
+	return nil
+}
+
+// IsReady return true if mailer is ready.
+func (p *PMProvider) IsReady() bool {
+	return true
+}
+
+// Client return the provider client.
+func (p *PMProvider) Client() interface{} {
+	return p.client
+}
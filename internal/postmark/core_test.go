@@ -0,0 +1,231 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+const (
+	testToken  = "server-token"
+	testStream = "auth"
+)
+
+// TestSend checks the message sent to a local Postmark API stand-in.
+func TestSend(t *testing.T) {
+	var token, path string
+	var received message
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get(tokenHeader)
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"To":"to@example.com","MessageID":"b7bc2f4a","ErrorCode":0,"Message":"OK"}`))
+	}))
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.URL)
+
+	em := &model.Email{
+		ID:        uuid.New(),
+		Name:      "Sender",
+		From:      "sender@example.com",
+		To:        "to@example.com",
+		ReplyTo:   "reply@example.com",
+		Subject:   "Subject",
+		Body:      "Body",
+		Headers:   map[string]string{"X-Campaign": "welcome"},
+		Metadata:  map[string]string{"campaign": "welcome"},
+		Tags:      []string{"welcome", "ignored"},
+		RequestID: "req-1",
+	}
+
+	resend, err := p.Send(em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if resend {
+		t.Error("Expected: no resend | Received: resend")
+	}
+
+	if path != "/email" {
+		t.Errorf("Expected: '/email' | Received: '%s'", path)
+	}
+
+	if token != testToken {
+		t.Errorf("Expected: '%s' | Received: '%s'", testToken, token)
+	}
+
+	if received.From != "Sender <sender@example.com>" {
+		t.Errorf("Expected: 'Sender <sender@example.com>' | Received: '%s'", received.From)
+	}
+
+	if received.MessageStream != testStream {
+		t.Errorf("Expected: '%s' | Received: '%s'", testStream, received.MessageStream)
+	}
+
+	if received.Tag != "welcome" {
+		t.Errorf("Expected: 'welcome' | Received: '%s'", received.Tag)
+	}
+
+	if received.Metadata[messageIDKey] != em.ID.String() {
+		t.Errorf("Expected: '%s' | Received: '%s'", em.ID.String(), received.Metadata[messageIDKey])
+	}
+
+	if received.Metadata[requestIDKey] != "req-1" {
+		t.Errorf("Expected: 'req-1' | Received: '%s'", received.Metadata[requestIDKey])
+	}
+
+	if len(received.Headers) != 1 || received.Headers[0].Name != "X-Campaign" {
+		t.Errorf("Expected: 'X-Campaign' header | Received: %+v", received.Headers)
+	}
+}
+
+// TestSendBatch checks that personalizations are sent using batch API.
+func TestSendBatch(t *testing.T) {
+	var path string
+	var received []message
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+
+		rs := make([]result, len(received))
+		for i, m := range received {
+			rs[i] = result{To: m.To, MessageID: fmt.Sprintf("%d", i)}
+		}
+		json.NewEncoder(w).Encode(rs)
+	}))
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.URL)
+
+	em := &model.Email{
+		ID:      uuid.New(),
+		From:    "sender@example.com",
+		Subject: "Hi -name-",
+		Body:    "Body",
+		Personalizations: []model.Personalization{
+			{To: "one@example.com", Substitutions: map[string]string{"-name-": "One"}},
+			{To: "two@example.com", Substitutions: map[string]string{"-name-": "Two"}},
+		},
+	}
+
+	_, err := p.Send(em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if path != "/email/batch" {
+		t.Errorf("Expected: '/email/batch' | Received: '%s'", path)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Expected: 2 messages | Received: %d", len(received))
+	}
+
+	if received[1].To != "two@example.com" || received[1].Subject != "Hi Two" {
+		t.Errorf("Expected: 'two@example.com' 'Hi Two' | Received: '%s' '%s'", received[1].To, received[1].Subject)
+	}
+}
+
+// TestSendBatchPartial checks that accepted messages of a batch
+// are reported so that only the rejected ones are resent.
+func TestSendBatchPartial(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var received []message
+		json.NewDecoder(r.Body).Decode(&received)
+
+		rs := make([]result, len(received))
+		for i, m := range received {
+			rs[i] = result{To: m.To, MessageID: fmt.Sprintf("%d", i)}
+		}
+		rs[1] = result{To: received[1].To, ErrorCode: errSenderNotConfirmed, Message: "error"}
+		json.NewEncoder(w).Encode(rs)
+	}))
+	defer ts.Close()
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com"}
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		em.Personalizations = append(em.Personalizations, model.Personalization{ID: uuid.New(), To: to})
+	}
+
+	p := newTestProvider(t, ts.URL)
+	resend, err := p.Send(em)
+
+	pe, ok := err.(*model.PartialError)
+	if !ok || len(pe.Sent) != 2 || pe.Sent[0] != 0 || pe.Sent[1] != 2 || !resend {
+		t.Errorf("Expected: first and third sent, resend the second | Received: %v, resend %t", err, resend)
+	}
+}
+
+// TestSendErrors checks Postmark errors mapping onto resend contract.
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		code   int
+		resend bool
+	}{
+		{http.StatusUnprocessableEntity, errInactiveRecipient, false},
+		{http.StatusUnprocessableEntity, errInvalidRequest, false},
+		{http.StatusUnprocessableEntity, errSenderNotConfirmed, true},
+		{http.StatusUnauthorized, errBadToken, true},
+		{http.StatusTooManyRequests, 0, true},
+		{http.StatusInternalServerError, 0, true},
+	}
+
+	for _, tc := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			fmt.Fprintf(w, `{"ErrorCode":%d,"Message":"error"}`, tc.code)
+		}))
+
+		p := newTestProvider(t, ts.URL)
+		resend, err := p.Send(&model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com"})
+
+		if err == nil {
+			t.Errorf("Status %d, code %d - Expected: error | Received: no error", tc.status, tc.code)
+		}
+
+		if resend != tc.resend {
+			t.Errorf("Status %d, code %d - Expected: resend %t | Received: resend %t", tc.status, tc.code, tc.resend, resend)
+		}
+
+		ts.Close()
+	}
+}
+
+func newTestProvider(t *testing.T, endpoint string) *PMProvider {
+	cfg := &config.Config{
+		Mailer: config.MailerConfig{
+			Providers: []config.ProviderConfig{
+				{
+					Name:     "postmark",
+					Type:     config.ProviderType.Postmark.String(),
+					Enabled:  true,
+					Priority: 1,
+					APIKey:   testToken,
+					Stream:   testStream,
+					Endpoint: endpoint,
+				},
+			},
+		},
+	}
+
+	p, err := Init(context.Background(), cfg, log.NewLogfmtLogger(os.Stdout))
+	if err != nil {
+		t.Fatalf("Cannot initialize provider: %s", err.Error())
+	}
+
+	return p
+}
@@ -0,0 +1,168 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package rfc822 composes raw internet messages from email models.
+package rfc822
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+)
+
+const (
+	defCharset    = "UTF-8"
+	messageIDHost = "poslan"
+)
+
+// reserved headers are composed from email fields
+// and cannot be overwritten by custom ones.
+var reserved = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// Compose returns the raw message for an email.
+func Compose(em *model.Email) ([]byte, error) {
+	charset := em.Charset
+	if charset == "" {
+		charset = defCharset
+	}
+
+	from := mail.Address{Name: em.Name, Address: em.From}
+
+	to, err := AddressList(em.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'to' address: %s", err.Error())
+	}
+
+	cc, err := AddressList(em.CC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'cc' address: %s", err.Error())
+	}
+
+	replyTo, err := AddressList(em.ReplyTo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'reply-to' address: %s", err.Error())
+	}
+
+	var b bytes.Buffer
+
+	writeHeader(&b, "From", from.String())
+	if len(to) > 0 {
+		writeHeader(&b, "To", joinAddresses(to))
+	}
+	if len(cc) > 0 {
+		writeHeader(&b, "Cc", joinAddresses(cc))
+	}
+	if len(replyTo) > 0 {
+		writeHeader(&b, "Reply-To", joinAddresses(replyTo))
+	}
+	writeHeader(&b, "Subject", mime.QEncoding.Encode(charset, em.Subject))
+	writeHeader(&b, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&b, "Message-ID", MessageID(em))
+
+	// Custom headers are sorted to get a stable output.
+	names := make([]string, 0, len(em.Headers))
+	for k := range em.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		ck := textproto.CanonicalMIMEHeaderKey(k)
+		if reserved[ck] {
+			return nil, fmt.Errorf("header '%s' cannot be overwritten", k)
+		}
+		if !validHeader(k, em.Headers[k]) {
+			return nil, fmt.Errorf("invalid header '%s'", k)
+		}
+		// Non ASCII values are encoded, ASCII ones are kept as is.
+		writeHeader(&b, ck, mime.QEncoding.Encode(charset, em.Headers[k]))
+	}
+
+	writeHeader(&b, "MIME-Version", "1.0")
+	writeHeader(&b, "Content-Type", fmt.Sprintf("text/plain; charset=%s", charset))
+	writeHeader(&b, "Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(em.Body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Recipients returns the addresses of all recipients (to, cc and bcc).
+func Recipients(em *model.Email) ([]string, error) {
+	rs := make([]string, 0)
+	for _, l := range []string{em.To, em.CC, em.BCC} {
+		as, err := AddressList(l)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range as {
+			rs = append(rs, a.Address)
+		}
+	}
+	return rs, nil
+}
+
+// AddressList parses a comma separated list of addresses.
+// An empty list is not an error.
+func AddressList(list string) ([]*mail.Address, error) {
+	if strings.TrimSpace(list) == "" {
+		return []*mail.Address{}, nil
+	}
+	return mail.ParseAddressList(list)
+}
+
+// MessageID returns the Message-ID header value for an email.
+func MessageID(em *model.Email) string {
+	return fmt.Sprintf("<%s@%s>", em.ID.String(), messageIDHost)
+}
+
+func writeHeader(b *bytes.Buffer, name, value string) {
+	fmt.Fprintf(b, "%s: %s\r\n", name, value)
+}
+
+func joinAddresses(as []*mail.Address) string {
+	ss := make([]string, len(as))
+	for i, a := range as {
+		ss[i] = a.String()
+	}
+	return strings.Join(ss, ", ")
+}
+
+// validHeader prevents header injection.
+func validHeader(name, value string) bool {
+	if name == "" || strings.ContainsAny(name, ": \t\r\n") {
+		return false
+	}
+	return !strings.ContainsAny(value, "\r\n")
+}
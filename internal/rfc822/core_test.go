@@ -0,0 +1,259 @@
+package rfc822
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/google/uuid"
+)
+
+// TestCompose checks composed message headers and body.
+func TestCompose(t *testing.T) {
+	dec := new(mime.WordDecoder)
+
+	tests := []struct {
+		name    string
+		email   model.Email
+		headers map[string]string
+		body    string
+	}{
+		{
+			name:  "plain",
+			email: model.Email{From: "sender@example.com", To: "to@example.com", Subject: "Subject", Body: "Body"},
+			headers: map[string]string{
+				"From":    "<sender@example.com>",
+				"To":      "<to@example.com>",
+				"Subject": "Subject",
+			},
+			body: "Body",
+		},
+		{
+			name: "recipients",
+			email: model.Email{
+				Name:    "Sender",
+				From:    "sender@example.com",
+				To:      "One <one@example.com>, two@example.com",
+				CC:      "cc@example.com",
+				BCC:     "bcc@example.com",
+				ReplyTo: "reply@example.com",
+			},
+			headers: map[string]string{
+				"From":     "\"Sender\" <sender@example.com>",
+				"To":       "\"One\" <one@example.com>, <two@example.com>",
+				"Cc":       "<cc@example.com>",
+				"Bcc":      "",
+				"Reply-To": "<reply@example.com>",
+			},
+		},
+		{
+			name: "non-ASCII",
+			email: model.Email{
+				Name:    "José Ñandú",
+				From:    "sender@example.com",
+				To:      "Zoë <zoe@example.com>",
+				Subject: "¡Olá, mundo!",
+				Body:    "Ça va? 日本語",
+				Headers: map[string]string{"X-Campaign": "Été"},
+			},
+			headers: map[string]string{
+				"From":       "José Ñandú <sender@example.com>",
+				"To":         "Zoë <zoe@example.com>",
+				"Subject":    "¡Olá, mundo!",
+				"X-Campaign": "Été",
+			},
+			body: "Ça va? 日本語",
+		},
+		{
+			name: "custom headers",
+			email: model.Email{
+				From:    "sender@example.com",
+				To:      "to@example.com",
+				Headers: map[string]string{"list-unsubscribe": "<mailto:unsubscribe@example.com>"},
+			},
+			headers: map[string]string{
+				"List-Unsubscribe": "<mailto:unsubscribe@example.com>",
+			},
+		},
+		{
+			name:  "long body",
+			email: model.Email{From: "sender@example.com", To: "to@example.com", Body: strings.Repeat("0123456789", 20) + "\r\nend"},
+			body:  strings.Repeat("0123456789", 20) + "\r\nend",
+		},
+	}
+
+	for _, tc := range tests {
+		tc.email.ID = uuid.New()
+
+		data, err := Compose(&tc.email)
+		if err != nil {
+			t.Errorf("%s - Expected: no error | Received: %s", tc.name, err.Error())
+			continue
+		}
+
+		// Raw headers must be 7 bit ASCII.
+		raw := data[:bytes.Index(data, []byte("\r\n\r\n"))]
+		for _, c := range raw {
+			if c > 127 {
+				t.Errorf("%s - Expected: ASCII headers | Received: '%s'", tc.name, raw)
+				break
+			}
+		}
+
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s - Expected: valid message | Received: %s", tc.name, err.Error())
+			continue
+		}
+
+		for k, expected := range tc.headers {
+			received, _ := dec.DecodeHeader(msg.Header.Get(k))
+			if received != expected {
+				t.Errorf("%s - Expected: %s '%s' | Received: '%s'", tc.name, k, expected, received)
+			}
+		}
+
+		if msg.Header.Get("Message-Id") != MessageID(&tc.email) {
+			t.Errorf("%s - Expected: '%s' | Received: '%s'", tc.name, MessageID(&tc.email), msg.Header.Get("Message-Id"))
+		}
+
+		// Single part text message.
+		mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil || mt != "text/plain" || params["charset"] != defCharset {
+			t.Errorf("%s - Expected: 'text/plain; charset=%s' | Received: '%s'", tc.name, defCharset, msg.Header.Get("Content-Type"))
+		}
+
+		if msg.Header.Get("Mime-Version") != "1.0" || msg.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+			t.Errorf("%s - Expected: MIME 1.0 quoted-printable | Received: '%s' '%s'", tc.name, msg.Header.Get("Mime-Version"), msg.Header.Get("Content-Transfer-Encoding"))
+		}
+
+		body, _ := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+		if string(body) != tc.body {
+			t.Errorf("%s - Expected: '%s' | Received: '%s'", tc.name, tc.body, body)
+		}
+	}
+}
+
+// TestComposeErrors checks rejected addresses and headers.
+func TestComposeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		email model.Email
+	}{
+		{"invalid to", model.Email{To: "not an address"}},
+		{"invalid cc", model.Email{To: "to@example.com", CC: "cc@"}},
+		{"invalid reply-to", model.Email{To: "to@example.com", ReplyTo: "<reply"}},
+		{"reserved header", model.Email{To: "to@example.com", Headers: map[string]string{"subject": "Overwritten"}}},
+		{"reserved message ID", model.Email{To: "to@example.com", Headers: map[string]string{"Message-ID": "<1@example.com>"}}},
+		{"injected value", model.Email{To: "to@example.com", Headers: map[string]string{"X-Campaign": "a\r\nBcc: victim@example.com"}}},
+		{"injected name", model.Email{To: "to@example.com", Headers: map[string]string{"X-Campaign: a\r\nBcc": "victim@example.com"}}},
+	}
+
+	for _, tc := range tests {
+		tc.email.ID = uuid.New()
+		tc.email.From = "sender@example.com"
+
+		if _, err := Compose(&tc.email); err == nil {
+			t.Errorf("%s - Expected: error | Received: no error", tc.name)
+		}
+	}
+}
+
+// TestAddressList checks address list parsing.
+func TestAddressList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		expected []string
+		valid    bool
+	}{
+		{"empty", "", []string{}, true},
+		{"blank", "  ", []string{}, true},
+		{"single", "to@example.com", []string{"to@example.com"}, true},
+		{"named", "To <to@example.com>", []string{"to@example.com"}, true},
+		{"many", "one@example.com, Two <two@example.com>", []string{"one@example.com", "two@example.com"}, true},
+		{"non-ASCII name", "Zoë <zoe@example.com>", []string{"zoe@example.com"}, true},
+		{"encoded name", "=?UTF-8?q?Zo=C3=AB?= <zoe@example.com>", []string{"zoe@example.com"}, true},
+		{"missing domain", "to@", nil, false},
+		{"unclosed bracket", "To <to@example.com", nil, false},
+	}
+
+	for _, tc := range tests {
+		as, err := AddressList(tc.list)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s - Expected: valid %t | Received: %v", tc.name, tc.valid, err)
+			continue
+		}
+
+		received := make([]string, len(as))
+		for i, a := range as {
+			received[i] = a.Address
+		}
+
+		if tc.valid && strings.Join(received, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%s - Expected: %v | Received: %v", tc.name, tc.expected, received)
+		}
+	}
+}
+
+// TestRecipients checks that bcc recipients are included.
+func TestRecipients(t *testing.T) {
+	em := &model.Email{To: "to@example.com", CC: "cc@example.com", BCC: "One <bcc1@example.com>, bcc2@example.com"}
+
+	rs, err := Recipients(em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	expected := "to@example.com,cc@example.com,bcc1@example.com,bcc2@example.com"
+	if strings.Join(rs, ",") != expected {
+		t.Errorf("Expected: '%s' | Received: '%s'", expected, strings.Join(rs, ","))
+	}
+}
+
+// TestMessageID checks Message-ID format.
+func TestMessageID(t *testing.T) {
+	em := &model.Email{ID: uuid.MustParse("8c6a1c6e-5d3c-4d7b-9a3c-2f1e2d3c4b5a")}
+	expected := "<8c6a1c6e-5d3c-4d7b-9a3c-2f1e2d3c4b5a@poslan>"
+
+	if received := MessageID(em); received != expected {
+		t.Errorf("Expected: '%s' | Received: '%s'", expected, received)
+	}
+
+	if _, err := mail.ParseAddress(MessageID(em)); err != nil {
+		t.Errorf("Expected: msg-id syntax | Received: %s", err.Error())
+	}
+}
+
+// TestValidHeader checks the header injection guard.
+func TestValidHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected bool
+	}{
+		{"valid", "X-Campaign", "spring", true},
+		{"empty value", "X-Campaign", "", true},
+		{"non-ASCII value", "X-Campaign", "Été", true},
+		{"empty name", "", "value", false},
+		{"colon in name", "X-Campaign:", "value", false},
+		{"space in name", "X Campaign", "value", false},
+		{"tab in name", "X-Campaign\t", "value", false},
+		{"newline in name", "X-Campaign\nBcc", "value", false},
+		{"CRLF in value", "X-Campaign", "a\r\nBcc: victim@example.com", false},
+		{"LF in value", "X-Campaign", "a\nBcc: victim@example.com", false},
+		{"CR in value", "X-Campaign", "a\rBcc: victim@example.com", false},
+	}
+
+	for _, tc := range tests {
+		if received := validHeader(tc.header, tc.value); received != tc.expected {
+			t.Errorf("%s - Expected: %t | Received: %t", tc.name, tc.expected, received)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package sendgrid
+
+const (
+	// sendPath is the SendGrid v3 mail send endpoint path.
+	sendPath = "/v3/mail/send"
+	// messageIDArg is the custom arg that
+	// carries poslan message ID.
+	messageIDArg = "poslan_message_id"
+	// requestIDArg is the custom arg that
+	// carries poslan request ID.
+	requestIDArg = "poslan_request_id"
+)
@@ -11,44 +11,62 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
 
 	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/rfc822"
 	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
 	sg "github.com/sendgrid/sendgrid-go"
 	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
 // SGProvider is a mail delivery provider.
 type SGProvider struct {
-	ctx      context.Context
-	cfg      *config.Config
-	logger   log.Logger
-	client   *sg.Client
-	name     string
-	priority int
+	ctx              context.Context
+	cfg              *config.Config
+	logger           log.Logger
+	client           *sg.Client
+	name             string
+	priority         int
+	sandbox          bool
+	unsubscribeGroup int
 }
 
 // Init amazon SendGrid mail server handler.
-func Init(ctx context.Context, cfg *config.Config, log log.Logger) (*SGProvider, error) {
+// If name is provided the provider is built from the
+// SendGrid provider config that matches it,
+// otherwise the first one of its type is used.
+func Init(ctx context.Context, cfg *config.Config, log log.Logger, name ...string) (*SGProvider, error) {
 	ok := cfg.HasProviderType(config.ProviderType.SendGrid)
 	if !ok {
 		return nil, errors.New("no config associated to an SesGrid provider")
 	}
-	return newProvider(ctx, cfg, log)
+	return newProvider(ctx, cfg, log, name...)
 }
 
 // Send an mail.
 func (p *SGProvider) Send(em *model.Email) (resend bool, err error) {
-	email := newSGEmail(em.From, em.To, em.CC, em.BCC, em.Subject, em.Body, em.Charset)
+	email, err := p.newSGEmail(em)
+	if err != nil {
+		// Malformed message, resending it will not help.
+		return false, fmt.Errorf("cannot compose the email: %s", err.Error())
+	}
+
+	// Client request is copied so that
+	// concurrent sends do not share its body.
+	req := p.client.Request
+	req.Body = sgmail.GetRequestBody(email)
 
-	res, err := p.client.Send(email)
+	res, err := sg.API(req)
 
 	if err != nil {
 		return true, err
 	}
-	// If no errores but response status code != accepted (202)
-	if res.StatusCode != 202 {
+	// SendGrid answers accepted (202) when delivering
+	// and OK (200) in sandbox mode, both are a success.
+	if res.StatusCode < 200 || res.StatusCode > 299 {
 		msg := fmt.Sprintf("cannot send email - status code: '%d'", res.StatusCode)
 		return true, errors.New(msg)
 	}
@@ -56,33 +74,155 @@ func (p *SGProvider) Send(em *model.Email) (resend bool, err error) {
 	return false, nil
 }
 
-func newSGEmail(from, to, cc, bcc, subject, body, charset string) *sgmail.SGMailV3 {
-	// Assemble the mail.
-	f := sgmail.NewEmail(from, from)
-	s := subject
-	t := sgmail.NewEmail(to, to)
-	tb := body
-	hb := fmt.Sprintf("<html><html><div>%s</div></body></html>", body)
-	e := sgmail.NewSingleEmail(f, s, t, tb, hb)
-	return e
+// newSGEmail assembles a SendGrid v3 mail.
+// Email personalizations are mapped to SendGrid ones,
+// tags to categories and metadata to custom args,
+// poslan message ID is always included as a custom arg
+// so that SendGrid events can be traced back to it.
+func (p *SGProvider) newSGEmail(em *model.Email) (*sgmail.SGMailV3, error) {
+	e := sgmail.NewV3Mail()
+	e.SetFrom(sgmail.NewEmail(em.Name, em.From))
+	e.Subject = em.Subject
+
+	ps, err := personalizations(em)
+	if err != nil {
+		return nil, err
+	}
+	e.AddPersonalizations(ps...)
+
+	replyTo, err := rfc822.AddressList(em.ReplyTo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'reply-to' address: %s", err.Error())
+	}
+	if len(replyTo) > 0 {
+		e.SetReplyTo(sgmail.NewEmail(replyTo[0].Name, replyTo[0].Address))
+	}
+
+	e.AddContent(
+		sgmail.NewContent("text/plain", em.Body),
+		sgmail.NewContent("text/html", htmlBody(em.Body)),
+	)
+
+	for k, v := range em.Headers {
+		e.SetHeader(k, v)
+	}
+
+	if len(em.Tags) > 0 {
+		e.AddCategories(em.Tags...)
+	}
+
+	for k, v := range em.Metadata {
+		e.SetCustomArg(k, v)
+	}
+	e.SetCustomArg(messageIDArg, em.ID.String())
+	if em.RequestID != "" {
+		e.SetCustomArg(requestIDArg, em.RequestID)
+	}
+
+	if p.unsubscribeGroup > 0 {
+		e.SetASM(&sgmail.Asm{GroupID: p.unsubscribeGroup})
+	}
+
+	if p.sandbox {
+		ms := sgmail.NewMailSettings()
+		ms.SetSandboxMode(sgmail.NewSetting(true))
+		e.SetMailSettings(ms)
+	}
+
+	return e, nil
+}
+
+// personalizations returns one SendGrid personalization
+// per email personalization or, if there are none,
+// a single one including all recipients.
+// Cc and Bcc recipients are only included in the first one.
+// Substitutions are left to SendGrid.
+// Personalization IDs are included as message ID custom args.
+func personalizations(em *model.Email) ([]*sgmail.Personalization, error) {
+	pzs := em.Personalizations
+	if len(pzs) == 0 {
+		pzs = []model.Personalization{{To: em.To}}
+	}
+
+	ps := make([]*sgmail.Personalization, 0, len(pzs))
+
+	for i, pz := range pzs {
+		p := sgmail.NewPersonalization()
+
+		to, err := emails(pz.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'to' address: %s", err.Error())
+		}
+		p.AddTos(to...)
+
+		if i == 0 {
+			cc, err := emails(em.CC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'cc' address: %s", err.Error())
+			}
+			p.AddCCs(cc...)
+
+			bcc, err := emails(em.BCC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'bcc' address: %s", err.Error())
+			}
+			p.AddBCCs(bcc...)
+		}
+
+		for k, v := range pz.Substitutions {
+			p.SetSubstitution(k, v)
+		}
+
+		// Personalization custom args override message ones.
+		if pz.ID != uuid.Nil {
+			p.SetCustomArg(messageIDArg, pz.ID.String())
+		}
+
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+func emails(list string) ([]*sgmail.Email, error) {
+	as, err := rfc822.AddressList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	es := make([]*sgmail.Email, len(as))
+	for i, a := range as {
+		es[i] = sgmail.NewEmail(a.Name, a.Address)
+	}
+
+	return es, nil
+}
+
+func htmlBody(body string) string {
+	return fmt.Sprintf("<html><body><div>%s</div></body></html>", html.EscapeString(body))
 }
 
-func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger) (*SGProvider, error) {
+func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger, name ...string) (*SGProvider, error) {
 	// Create a SendGrid session.
-	p, ok := cfg.Provider(config.ProviderType.SendGrid)
+	p, ok := cfg.Provider(config.ProviderType.SendGrid, name...)
 	if !ok {
 		return nil, fmt.Errorf("no provider of type '%s' in config", config.ProviderType.SendGrid)
 	}
 
-	clt := sg.NewSendClient(p.APIKey)
+	// An empty endpoint defaults to SendGrid API host.
+	req := sg.GetRequest(p.APIKey, sendPath, p.Endpoint)
+	req.Method = "POST"
+	clt := &sg.Client{Request: req}
 
 	return &SGProvider{
-		ctx:      ctx,
-		cfg:      cfg,
-		logger:   logger,
-		client:   clt,
-		name:     p.Name,
-		priority: p.Priority,
+		ctx:              ctx,
+		cfg:              cfg,
+		logger:           logger,
+		client:           clt,
+		name:             p.Name,
+		priority:         p.Priority,
+		sandbox:          p.Sandbox,
+		unsubscribeGroup: p.UnsubscribeGroup,
 	}, nil
 }
 
@@ -91,7 +231,7 @@ func (p *SGProvider) Name() string {
 	return p.name
 }
 
-// Priority return the provider priorit
+// Priority returns the provider priority.
 func (p *SGProvider) Priority() int {
 	return p.priority
 }
@@ -0,0 +1,237 @@
+package sendgrid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+const (
+	testAPIKey = "sg-test"
+	testGroup  = 42
+)
+
+// TestSend checks the message sent to a local SendGrid API stand-in
+// that, as SendGrid does in sandbox mode, answers 200 OK.
+func TestSend(t *testing.T) {
+	var received sgmail.SGMailV3
+	var auth, path string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.URL, true)
+
+	p1, p2 := uuid.New(), uuid.New()
+	em := &model.Email{
+		ID:        uuid.New(),
+		Name:      "Sender",
+		From:      "sender@example.com",
+		CC:        "cc@example.com",
+		BCC:       "bcc@example.com",
+		ReplyTo:   "Reply <reply@example.com>",
+		Subject:   "Hello -name-",
+		Body:      "Body",
+		Metadata:  map[string]string{"campaign": "welcome"},
+		Tags:      []string{"welcome", "onboarding"},
+		RequestID: "req-1",
+		Personalizations: []model.Personalization{
+			{ID: p1, To: "one@example.com", Substitutions: map[string]string{"-name-": "One"}},
+			{ID: p2, To: "two@example.com", Substitutions: map[string]string{"-name-": "Two"}},
+		},
+	}
+
+	resend, err := p.Send(em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if resend {
+		t.Error("Expected: no resend | Received: resend")
+	}
+
+	if path != sendPath || auth != "Bearer "+testAPIKey {
+		t.Errorf("Expected: '%s' 'Bearer %s' | Received: '%s' '%s'", sendPath, testAPIKey, path, auth)
+	}
+
+	if received.From == nil || received.From.Name != "Sender" || received.From.Address != "sender@example.com" {
+		t.Errorf("Expected: 'Sender <sender@example.com>' | Received: %+v", received.From)
+	}
+
+	if received.ReplyTo == nil || received.ReplyTo.Name != "Reply" || received.ReplyTo.Address != "reply@example.com" {
+		t.Errorf("Expected: 'Reply <reply@example.com>' | Received: %+v", received.ReplyTo)
+	}
+
+	if !reflect.DeepEqual(received.Categories, em.Tags) {
+		t.Errorf("Expected: %v | Received: %v", em.Tags, received.Categories)
+	}
+
+	args := map[string]string{"campaign": "welcome", messageIDArg: em.ID.String(), requestIDArg: "req-1"}
+	if !reflect.DeepEqual(received.CustomArgs, args) {
+		t.Errorf("Expected: %v | Received: %v", args, received.CustomArgs)
+	}
+
+	if received.Asm == nil || received.Asm.GroupID != testGroup {
+		t.Errorf("Expected: ASM group %d | Received: %+v", testGroup, received.Asm)
+	}
+
+	ms := received.MailSettings
+	if ms == nil || ms.SandboxMode == nil || ms.SandboxMode.Enable == nil || !*ms.SandboxMode.Enable {
+		t.Error("Expected: sandbox mode | Received: no sandbox mode")
+	}
+
+	ps := received.Personalizations
+	if len(ps) != 2 {
+		t.Fatalf("Expected: 2 personalizations | Received: %d", len(ps))
+	}
+
+	tests := []struct {
+		to    string
+		id    uuid.UUID
+		name  string
+		cc    int
+		bcc   int
+		index int
+	}{
+		{"one@example.com", p1, "One", 1, 1, 0},
+		{"two@example.com", p2, "Two", 0, 0, 1},
+	}
+
+	for _, tc := range tests {
+		p := ps[tc.index]
+
+		if len(p.To) != 1 || p.To[0].Address != tc.to {
+			t.Errorf("Personalization %d - Expected: '%s' | Received: %+v", tc.index, tc.to, p.To)
+		}
+
+		// Cc and Bcc recipients only get the first one.
+		if len(p.CC) != tc.cc || len(p.BCC) != tc.bcc {
+			t.Errorf("Personalization %d - Expected: %d cc %d bcc | Received: %d cc %d bcc", tc.index, tc.cc, tc.bcc, len(p.CC), len(p.BCC))
+		}
+
+		if p.Substitutions["-name-"] != tc.name {
+			t.Errorf("Personalization %d - Expected: '%s' | Received: '%s'", tc.index, tc.name, p.Substitutions["-name-"])
+		}
+
+		if p.CustomArgs[messageIDArg] != tc.id.String() {
+			t.Errorf("Personalization %d - Expected: '%s' | Received: '%s'", tc.index, tc.id, p.CustomArgs[messageIDArg])
+		}
+	}
+}
+
+// TestSendSettings checks that ASM group and sandbox
+// mode are left out when not configured and that
+// messages without personalizations get a single one.
+func TestSendSettings(t *testing.T) {
+	var received sgmail.SGMailV3
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.URL, false)
+	p.unsubscribeGroup = 0
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com", To: "one@example.com, two@example.com", CC: "cc@example.com"}
+
+	if _, err := p.Send(em); err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if received.Asm != nil || received.MailSettings != nil {
+		t.Errorf("Expected: no ASM group nor mail settings | Received: %+v %+v", received.Asm, received.MailSettings)
+	}
+
+	if _, ok := received.CustomArgs[requestIDArg]; ok {
+		t.Error("Expected: no request ID custom arg | Received: request ID custom arg")
+	}
+
+	ps := received.Personalizations
+	if len(ps) != 1 || len(ps[0].To) != 2 || len(ps[0].CC) != 1 {
+		t.Errorf("Expected: 1 personalization with 2 to and 1 cc | Received: %+v", ps)
+	}
+}
+
+// TestSendErrors checks response status mapping onto resend contract.
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		err    bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusAccepted, false},
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tc := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+
+		p := newTestProvider(t, ts.URL, false)
+		resend, err := p.Send(&model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com"})
+
+		if (err != nil) != tc.err {
+			t.Errorf("Status %d - Expected: error %t | Received: %v", tc.status, tc.err, err)
+		}
+
+		if resend != tc.err {
+			t.Errorf("Status %d - Expected: resend %t | Received: %t", tc.status, tc.err, resend)
+		}
+
+		ts.Close()
+	}
+
+	// Malformed messages are not resent.
+	p := newTestProvider(t, "http://localhost", false)
+	resend, err := p.Send(&model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@"})
+
+	if err == nil || resend {
+		t.Errorf("Expected: error and no resend | Received: %v %t", err, resend)
+	}
+}
+
+func newTestProvider(t *testing.T, endpoint string, sandbox bool) *SGProvider {
+	cfg := &config.Config{
+		Mailer: config.MailerConfig{
+			Providers: []config.ProviderConfig{
+				{
+					Name:             "sendgrid",
+					Type:             config.ProviderType.SendGrid.String(),
+					Enabled:          true,
+					Priority:         1,
+					APIKey:           testAPIKey,
+					Endpoint:         endpoint,
+					Sandbox:          sandbox,
+					UnsubscribeGroup: testGroup,
+				},
+			},
+		},
+	}
+
+	p, err := Init(context.Background(), cfg, log.NewLogfmtLogger(os.Stdout))
+	if err != nil {
+		t.Fatalf("Cannot initialize provider: %s", err.Error())
+	}
+
+	return p
+}
@@ -0,0 +1,12 @@
+package smtpd
+
+import "time"
+
+const (
+	defHostname        = "localhost"
+	defMaxMessageBytes = 10 << 20
+	defMaxRecipients   = 100
+	defTimeout         = 5 * time.Minute
+	// maxLineBytes is the max length of command lines.
+	maxLineBytes = 2048
+)
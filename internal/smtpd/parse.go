@@ -0,0 +1,221 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package smtpd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"github.com/adrianpk/poslan/pkg/model"
+)
+
+// passHeaders are non X- headers relayed to providers.
+var passHeaders = []string{
+	"List-Unsubscribe",
+	"List-Unsubscribe-Post",
+}
+
+var wordDecoder = &mime.WordDecoder{}
+
+// Parse reads a message into an email.
+// Envelope recipients not present in To or Cc
+// headers are delivered as BCC.
+// Only the text/plain body is kept.
+func Parse(r io.Reader, rcpt []string) (*model.Email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := msg.Header
+
+	to, err := addresses(h, "To")
+	if err != nil {
+		return nil, err
+	}
+
+	cc, err := addresses(h, "Cc")
+	if err != nil {
+		return nil, err
+	}
+
+	replyTo, err := addresses(h, "Reply-To")
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := wordDecoder.DecodeHeader(h.Get("Subject"))
+	if err != nil {
+		subject = h.Get("Subject")
+	}
+
+	body, charset, err := textBody(msg.Header, msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	em := &model.Email{
+		To:      strings.Join(to, ", "),
+		CC:      strings.Join(cc, ", "),
+		BCC:     strings.Join(bcc(rcpt, to, cc), ", "),
+		ReplyTo: strings.Join(replyTo, ", "),
+		Subject: subject,
+		Body:    body,
+		Charset: charset,
+		Headers: customHeaders(h),
+	}
+
+	// Recipients only in the envelope.
+	if em.To == "" && em.CC == "" {
+		em.To, em.BCC = em.BCC, ""
+	}
+
+	if em.To == "" {
+		return nil, errors.New("no recipients")
+	}
+
+	return em, nil
+}
+
+func addresses(h mail.Header, key string) ([]string, error) {
+	if h.Get(key) == "" {
+		return nil, nil
+	}
+
+	list, err := h.AddressList(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' header: %s", key, err.Error())
+	}
+
+	as := make([]string, 0, len(list))
+	for _, a := range list {
+		as = append(as, a.Address)
+	}
+	return as, nil
+}
+
+// bcc returns envelope recipients not in to or cc.
+func bcc(rcpt, to, cc []string) []string {
+	seen := make(map[string]bool)
+	for _, a := range append(to, cc...) {
+		seen[strings.ToLower(a)] = true
+	}
+
+	var list []string
+	for _, a := range rcpt {
+		if !seen[strings.ToLower(a)] {
+			seen[strings.ToLower(a)] = true
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+func customHeaders(h mail.Header) map[string]string {
+	hs := make(map[string]string)
+	for k, v := range h {
+		if len(v) == 0 {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(k), "X-") {
+			hs[k] = v[0]
+		}
+	}
+	for _, k := range passHeaders {
+		if v := h.Get(k); v != "" {
+			hs[k] = v
+		}
+	}
+	if len(hs) == 0 {
+		return nil
+	}
+	return hs
+}
+
+// textBody returns the decoded text/plain body and its charset.
+func textBody(h mail.Header, r io.Reader) (body, charset string, err error) {
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = "text/plain"
+	}
+
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid content type: %s", err.Error())
+	}
+
+	if strings.HasPrefix(mt, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return "", "", errors.New("no text/plain part")
+			}
+			if err != nil {
+				return "", "", err
+			}
+
+			body, charset, err := textBody(mail.Header(p.Header), p)
+			if err == nil {
+				return body, charset, nil
+			}
+		}
+	}
+
+	if mt != "text/plain" {
+		return "", "", fmt.Errorf("unsupported content type '%s'", mt)
+	}
+
+	b, err := ioutil.ReadAll(decode(h.Get("Content-Transfer-Encoding"), r))
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)), params["charset"], nil
+}
+
+func decode(cte string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, newlineStripper{r})
+	default:
+		return r
+	}
+}
+
+// newlineStripper removes line breaks from base64 encoded content.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	for {
+		c, err := n.r.Read(p)
+		j := 0
+		for _, b := range p[:c] {
+			if b != '\r' && b != '\n' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package smtpd implements an SMTP submission server (RFC 6409)
+// that hands incoming messages to a backend.
+// Only the subset needed by mail submission clients is supported:
+// EHLO/HELO, STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+package smtpd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+)
+
+// Backend authenticates clients and delivers their messages.
+type Backend interface {
+	// Authenticate validates client credentials and returns
+	// the context used to deliver its messages.
+	Authenticate(ctx context.Context, username, password string) (context.Context, error)
+	// Send delivers a message.
+	Send(ctx context.Context, email *model.Email) (*model.Email, error)
+}
+
+// Server is an SMTP submission server.
+type Server struct {
+	// Addr to listen on (i.e.: ':587').
+	Addr string
+	// Hostname announced in greetings.
+	Hostname string
+	// TLSConfig enables STARTTLS if not nil.
+	TLSConfig *tls.Config
+	// AllowInsecureAuth lets clients authenticate
+	// over non encrypted connections.
+	AllowInsecureAuth bool
+	// MaxMessageBytes is the max accepted message size.
+	MaxMessageBytes int64
+	// MaxRecipients is the max number of recipients per message.
+	MaxRecipients int
+	// Timeout applied to each client command.
+	Timeout time.Duration
+	// Backend used to authenticate and deliver.
+	Backend Backend
+	// Logger
+	Logger log.Logger
+
+	mux      sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("smtpd: server closed")
+
+// ListenAndServe listens on server address and serves clients.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts clients on the listener.
+func (s *Server) Serve(l net.Listener) error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.conns = make(map[net.Conn]struct{})
+	s.mux.Unlock()
+
+	s.setDefaults()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mux.Lock()
+			closed := s.closed
+			s.mux.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mux.Lock()
+		s.conns[conn] = struct{}{}
+		s.mux.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+// Close stops listening and closes client connections.
+func (s *Server) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mux.Lock()
+		delete(s.conns, conn)
+		s.mux.Unlock()
+	}()
+
+	newSession(s, conn).serve()
+}
+
+func (s *Server) setDefaults() {
+	if s.Hostname == "" {
+		s.Hostname = defHostname
+	}
+	if s.MaxMessageBytes <= 0 {
+		s.MaxMessageBytes = defMaxMessageBytes
+	}
+	if s.MaxRecipients <= 0 {
+		s.MaxRecipients = defMaxRecipients
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = defTimeout
+	}
+	if s.Logger == nil {
+		s.Logger = log.NewNopLogger()
+	}
+}
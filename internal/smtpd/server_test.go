@@ -0,0 +1,156 @@
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/google/uuid"
+)
+
+type testBackend struct {
+	mux    sync.Mutex
+	emails []*model.Email
+}
+
+func (b *testBackend) Authenticate(ctx context.Context, username, password string) (context.Context, error) {
+	if username != "client" || password != "secret" {
+		return nil, errors.New("invalid credentials")
+	}
+	return ctx, nil
+}
+
+func (b *testBackend) Send(ctx context.Context, email *model.Email) (*model.Email, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	email.ID = uuid.New()
+	b.emails = append(b.emails, email)
+	return email, nil
+}
+
+func startServer(t *testing.T, b Backend) (addr string, srv *Server) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %s", err.Error())
+	}
+
+	srv = &Server{
+		AllowInsecureAuth: true,
+		Backend:           b,
+	}
+	go srv.Serve(l)
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return net.JoinHostPort("localhost", port), srv
+}
+
+func TestSendMail(t *testing.T) {
+	b := &testBackend{}
+	addr, srv := startServer(t, b)
+	defer srv.Close()
+
+	msg := "From: sender@example.com\r\n" +
+		"To: Jane <to@example.com>\r\n" +
+		"Cc: cc@example.com\r\n" +
+		"Subject: =?UTF-8?Q?Caf=C3=A9?=\r\n" +
+		"X-Campaign: welcome\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Body =C3=A9\r\n"
+
+	auth := smtp.PlainAuth("", "client", "secret", "localhost")
+	rcpt := []string{"to@example.com", "cc@example.com", "bcc@example.com"}
+
+	err := smtp.SendMail(addr, auth, "sender@example.com", rcpt, []byte(msg))
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if len(b.emails) != 1 {
+		t.Fatalf("Expected: 1 email | Received: %d", len(b.emails))
+	}
+
+	em := b.emails[0]
+	if em.To != "to@example.com" || em.CC != "cc@example.com" || em.BCC != "bcc@example.com" {
+		t.Errorf("Expected: to, cc, bcc recipients | Received: '%s', '%s', '%s'", em.To, em.CC, em.BCC)
+	}
+
+	if em.Subject != "Café" {
+		t.Errorf("Expected: 'Café' | Received: '%s'", em.Subject)
+	}
+
+	if strings.TrimSpace(em.Body) != "Body é" {
+		t.Errorf("Expected: 'Body é' | Received: '%s'", em.Body)
+	}
+
+	if em.Headers["X-Campaign"] != "welcome" {
+		t.Errorf("Expected: 'welcome' | Received: '%s'", em.Headers["X-Campaign"])
+	}
+}
+
+func TestSendMailInvalidAuth(t *testing.T) {
+	b := &testBackend{}
+	addr, srv := startServer(t, b)
+	defer srv.Close()
+
+	auth := smtp.PlainAuth("", "client", "wrong", "localhost")
+	msg := "To: to@example.com\r\nSubject: Subject\r\n\r\nBody\r\n"
+
+	err := smtp.SendMail(addr, auth, "sender@example.com", []string{"to@example.com"}, []byte(msg))
+	if err == nil || !strings.HasPrefix(err.Error(), "535") {
+		t.Errorf("Expected: 535 error | Received: %v", err)
+	}
+
+	if len(b.emails) != 0 {
+		t.Errorf("Expected: 0 emails | Received: %d", len(b.emails))
+	}
+}
+
+func TestSendMailRequiresAuth(t *testing.T) {
+	b := &testBackend{}
+	addr, srv := startServer(t, b)
+	defer srv.Close()
+
+	msg := "To: to@example.com\r\nSubject: Subject\r\n\r\nBody\r\n"
+
+	err := smtp.SendMail(addr, nil, "sender@example.com", []string{"to@example.com"}, []byte(msg))
+	if err == nil || !strings.HasPrefix(err.Error(), "530") {
+		t.Errorf("Expected: 530 error | Received: %v", err)
+	}
+}
+
+func TestParseMultipart(t *testing.T) {
+	msg := "To: to@example.com\r\n" +
+		"Subject: Subject\r\n" +
+		"Content-Type: multipart/alternative; boundary=b1\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/html\r\n" +
+		"\r\n" +
+		"<p>Body</p>\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"Qm9keQ==\r\n" +
+		"--b1--\r\n"
+
+	em, err := Parse(strings.NewReader(msg), []string{"to@example.com"})
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if em.Body != "Body" {
+		t.Errorf("Expected: 'Body' | Received: '%s'", em.Body)
+	}
+
+	if em.Charset != "UTF-8" {
+		t.Errorf("Expected: 'UTF-8' | Received: '%s'", em.Charset)
+	}
+}
@@ -0,0 +1,411 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package smtpd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// session is an SMTP client session.
+type session struct {
+	srv    *Server
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	helo   string
+	tls    bool
+	// ctx is set after a successful authentication.
+	ctx context.Context
+	// envelope
+	from string
+	rcpt []string
+}
+
+func newSession(srv *Server, conn net.Conn) *session {
+	s := &session{srv: srv}
+	s.setConn(conn)
+	_, s.tls = conn.(*tls.Conn)
+	return s
+}
+
+func (s *session) setConn(conn net.Conn) {
+	s.conn = conn
+	s.reader = bufio.NewReaderSize(conn, maxLineBytes)
+	s.writer = bufio.NewWriter(conn)
+}
+
+func (s *session) serve() {
+	s.reply(220, fmt.Sprintf("%s ESMTP poslan", s.srv.Hostname))
+
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				s.reply(500, "5.5.2 Line too long")
+				continue
+			}
+			return
+		}
+
+		cmd, arg := parseCommand(line)
+
+		switch cmd {
+		case "HELO", "EHLO":
+			s.handleHelo(cmd, arg)
+		case "STARTTLS":
+			if !s.handleStartTLS() {
+				return
+			}
+		case "AUTH":
+			s.handleAuth(arg)
+		case "MAIL":
+			s.handleMail(arg)
+		case "RCPT":
+			s.handleRcpt(arg)
+		case "DATA":
+			if !s.handleData() {
+				return
+			}
+		case "RSET":
+			s.reset()
+			s.reply(250, "2.0.0 OK")
+		case "NOOP":
+			s.reply(250, "2.0.0 OK")
+		case "VRFY":
+			s.reply(252, "2.5.0 Cannot VRFY user")
+		case "QUIT":
+			s.reply(221, "2.0.0 Bye")
+			return
+		default:
+			s.reply(502, "5.5.1 Command not implemented")
+		}
+	}
+}
+
+func (s *session) handleHelo(cmd, arg string) {
+	if arg == "" {
+		s.reply(501, "5.5.4 Domain required")
+		return
+	}
+
+	s.helo = arg
+	s.reset()
+
+	if cmd == "HELO" {
+		s.reply(250, s.srv.Hostname)
+		return
+	}
+
+	exts := []string{
+		s.srv.Hostname,
+		fmt.Sprintf("SIZE %d", s.srv.MaxMessageBytes),
+		"8BITMIME",
+	}
+	if s.srv.TLSConfig != nil && !s.tls {
+		exts = append(exts, "STARTTLS")
+	}
+	if s.authAllowed() {
+		exts = append(exts, "AUTH PLAIN")
+	}
+	s.reply(250, exts...)
+}
+
+func (s *session) handleStartTLS() (ok bool) {
+	if s.srv.TLSConfig == nil || s.tls {
+		s.reply(502, "5.5.1 TLS not available")
+		return true
+	}
+
+	s.reply(220, "2.0.0 Ready to start TLS")
+
+	tc := tls.Server(s.conn, s.srv.TLSConfig)
+	tc.SetDeadline(time.Now().Add(s.srv.Timeout))
+	if err := tc.Handshake(); err != nil {
+		s.log("STARTTLS", err)
+		return false
+	}
+
+	// Session state is discarded after TLS negotiation (RFC 3207).
+	s.setConn(tc)
+	s.tls = true
+	s.helo = ""
+	s.ctx = nil
+	s.reset()
+	return true
+}
+
+func (s *session) handleAuth(arg string) {
+	if s.helo == "" {
+		s.reply(503, "5.5.1 Send EHLO first")
+		return
+	}
+
+	if !s.authAllowed() {
+		s.reply(538, "5.7.11 Encryption required for requested authentication mechanism")
+		return
+	}
+
+	if s.ctx != nil {
+		s.reply(503, "5.5.1 Already authenticated")
+		return
+	}
+
+	parts := strings.Fields(arg)
+	if len(parts) < 1 || strings.ToUpper(parts[0]) != "PLAIN" {
+		s.reply(504, "5.5.4 Unrecognized authentication type")
+		return
+	}
+
+	resp := ""
+	if len(parts) > 1 {
+		resp = parts[1]
+	} else {
+		s.reply(334, "")
+		line, err := s.readLine()
+		if err != nil {
+			return
+		}
+		resp = line
+	}
+
+	if resp == "*" {
+		s.reply(501, "5.0.0 Authentication cancelled")
+		return
+	}
+
+	username, password, err := decodePlain(resp)
+	if err != nil {
+		s.reply(501, "5.5.2 Invalid authentication response")
+		return
+	}
+
+	ctx, err := s.srv.Backend.Authenticate(context.Background(), username, password)
+	if err != nil {
+		s.log("AUTH", err)
+		s.reply(535, "5.7.8 Authentication credentials invalid")
+		return
+	}
+
+	s.ctx = ctx
+	s.reply(235, "2.7.0 Authentication successful")
+}
+
+func (s *session) handleMail(arg string) {
+	if s.ctx == nil {
+		s.reply(530, "5.7.0 Authentication required")
+		return
+	}
+
+	if s.from != "" {
+		s.reply(503, "5.5.1 Nested MAIL command")
+		return
+	}
+
+	addr, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	for _, p := range params {
+		kv := strings.SplitN(p, "=", 2)
+		if strings.ToUpper(kv[0]) == "SIZE" && len(kv) == 2 {
+			var size int64
+			fmt.Sscanf(kv[1], "%d", &size)
+			if size > s.srv.MaxMessageBytes {
+				s.reply(552, "5.3.4 Message size exceeds fixed limit")
+				return
+			}
+		}
+	}
+
+	// Null reverse-path is not allowed for submission.
+	if addr == "" {
+		s.reply(501, "5.1.7 Invalid sender address")
+		return
+	}
+
+	s.from = addr
+	s.reply(250, "2.1.0 OK")
+}
+
+func (s *session) handleRcpt(arg string) {
+	if s.from == "" {
+		s.reply(503, "5.5.1 Send MAIL first")
+		return
+	}
+
+	if len(s.rcpt) >= s.srv.MaxRecipients {
+		s.reply(452, "4.5.3 Too many recipients")
+		return
+	}
+
+	addr, _, ok := parsePath(arg, "TO:")
+	if !ok || addr == "" {
+		s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+
+	s.rcpt = append(s.rcpt, addr)
+	s.reply(250, "2.1.5 OK")
+}
+
+func (s *session) handleData() (ok bool) {
+	if len(s.rcpt) == 0 {
+		s.reply(503, "5.5.1 Send RCPT first")
+		return true
+	}
+
+	s.reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+
+	s.conn.SetReadDeadline(time.Now().Add(s.srv.Timeout))
+	dr := textproto.NewReader(s.reader).DotReader()
+	data, err := ioutil.ReadAll(io.LimitReader(dr, s.srv.MaxMessageBytes+1))
+	if err != nil {
+		return false
+	}
+
+	if int64(len(data)) > s.srv.MaxMessageBytes {
+		// Discard the rest of the message.
+		io.Copy(ioutil.Discard, dr)
+		s.reset()
+		s.reply(552, "5.3.4 Message size exceeds fixed limit")
+		return true
+	}
+
+	defer s.reset()
+
+	em, err := Parse(bytes.NewReader(data), s.rcpt)
+	if err != nil {
+		s.log("DATA", err)
+		s.reply(554, fmt.Sprintf("5.6.0 Malformed message: %s", err.Error()))
+		return true
+	}
+
+	sent, err := s.srv.Backend.Send(s.ctx, em)
+	if err != nil {
+		s.log("DATA", err)
+		s.reply(451, "4.3.0 Message not delivered, try again later")
+		return true
+	}
+
+	s.reply(250, fmt.Sprintf("2.0.0 OK queued as %s", sent.ID.String()))
+	return true
+}
+
+func (s *session) authAllowed() bool {
+	return s.tls || s.srv.AllowInsecureAuth
+}
+
+func (s *session) reset() {
+	s.from = ""
+	s.rcpt = nil
+}
+
+func (s *session) readLine() (string, error) {
+	s.conn.SetReadDeadline(time.Now().Add(s.srv.Timeout))
+	line, err := s.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Discard the rest of the line.
+		for err == bufio.ErrBufferFull {
+			_, err = s.reader.ReadSlice('\n')
+		}
+		if err != nil {
+			return "", err
+		}
+		return "", bufio.ErrBufferFull
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// reply writes a, possibly multiline, reply.
+func (s *session) reply(code int, lines ...string) {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.srv.Timeout))
+	for i, l := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(s.writer, "%d%s%s\r\n", code, sep, l)
+	}
+	s.writer.Flush()
+}
+
+func (s *session) log(cmd string, err error) {
+	level.Warn(s.srv.Logger).Log(
+		"package", "smtpd",
+		"method", cmd,
+		"remote", s.conn.RemoteAddr().String(),
+		"error", err.Error(),
+	)
+}
+
+// parseCommand splits a command line into
+// upper cased command and its argument.
+func parseCommand(line string) (cmd, arg string) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	cmd = strings.ToUpper(parts[0])
+	if len(parts) > 1 {
+		arg = strings.TrimSpace(parts[1])
+	}
+	return cmd, arg
+}
+
+// parsePath parses 'FROM:<address> params' and 'TO:<address> params'.
+func parsePath(arg, prefix string) (addr string, params []string, ok bool) {
+	if len(arg) < len(prefix) || strings.ToUpper(arg[:len(prefix)]) != prefix {
+		return "", nil, false
+	}
+
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+
+	end := strings.Index(rest, ">")
+	if end < 0 {
+		return "", nil, false
+	}
+
+	return rest[1:end], strings.Fields(rest[end+1:]), true
+}
+
+// decodePlain decodes a SASL PLAIN response (RFC 4616):
+// base64 of 'authzid NUL authcid NUL passwd'.
+func decodePlain(resp string) (username, password string, err error) {
+	b, err := base64.StdEncoding.DecodeString(resp)
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := bytes.Split(b, []byte{0})
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("invalid PLAIN response")
+	}
+
+	return string(parts[1]), string(parts[2]), nil
+}
@@ -31,5 +31,9 @@ type Provider interface {
 	// Stop provider.
 	Stop() error
 	// Send and email.
+	// If only some personalizations were sent the error
+	// is a model.PartialError and resend applies to the rest.
 	Send(*model.Email) (resend bool, err error)
+	// IsReady return true if the provider can deliver messages.
+	IsReady() bool
 }
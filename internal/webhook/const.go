@@ -0,0 +1,18 @@
+package webhook
+
+const (
+	// FormatJSON delivers messages as JSON documents.
+	FormatJSON = "json"
+	// FormatRFC822 delivers messages as raw internet messages.
+	FormatRFC822 = "rfc822"
+	// Signature headers.
+	signatureHeader = "X-Poslan-Signature"
+	timestampHeader = "X-Poslan-Timestamp"
+	messageIDHeader = "X-Poslan-Message-Id"
+	// requestIDHeader carries poslan request ID.
+	requestIDHeader = "X-Request-ID"
+	// signatureScheme prefixes the signature header value.
+	signatureScheme = "sha256="
+	// defTimeout of webhook requests in seconds.
+	defTimeout = 10
+)
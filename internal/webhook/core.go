@@ -0,0 +1,261 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/rfc822"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// WHProvider delivers messages as signed HTTP POST requests
+// to a configured URL.
+type WHProvider struct {
+	ctx      context.Context
+	cfg      *config.Config
+	logger   log.Logger
+	client   *http.Client
+	name     string
+	priority int
+	url      string
+	secret   []byte
+	format   string
+}
+
+// message is the JSON document delivered to the webhook.
+type message struct {
+	ID       string            `json:"id"`
+	From     address           `json:"from"`
+	To       string            `json:"to,omitempty"`
+	CC       string            `json:"cc,omitempty"`
+	BCC      string            `json:"bcc,omitempty"`
+	ReplyTo  string            `json:"replyTo,omitempty"`
+	Subject  string            `json:"subject"`
+	Body     string            `json:"body"`
+	Charset  string            `json:"charset,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+}
+
+type address struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+// Init webhook mail server handler.
+// If name is provided the provider is built from the
+// webhook provider config that matches it,
+// otherwise the first one of its type is used.
+func Init(ctx context.Context, cfg *config.Config, log log.Logger, name ...string) (*WHProvider, error) {
+	ok := cfg.HasProviderType(config.ProviderType.Webhook)
+	if !ok {
+		return nil, errors.New("no config associated to a webhook provider")
+	}
+	return newProvider(ctx, cfg, log, name...)
+}
+
+// Send an email.
+// An individual request is sent for each personalization.
+func (p *WHProvider) Send(em *model.Email) (resend bool, err error) {
+	var sent []int
+	for i, e := range em.Expand() {
+		resend, err = p.send(e)
+		if err != nil {
+			// Report the sent ones so that only the rest is resent.
+			return resend, model.Partial(sent, err)
+		}
+		sent = append(sent, i)
+	}
+	return false, nil
+}
+
+func (p *WHProvider) send(em *model.Email) (resend bool, err error) {
+	body, ct, err := p.encode(em)
+	if err != nil {
+		// Malformed message, resending it will not help.
+		return false, fmt.Errorf("cannot compose the email: %s", err.Error())
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return true, fmt.Errorf("cannot send the email: %s", err.Error())
+	}
+	req = req.WithContext(p.ctx)
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", ct)
+	req.Header.Set(messageIDHeader, em.ID.String())
+	if em.RequestID != "" {
+		req.Header.Set(requestIDHeader, em.RequestID)
+	}
+	req.Header.Set(timestampHeader, ts)
+	if len(p.secret) > 0 {
+		req.Header.Set(signatureHeader, signatureScheme+Sign(p.secret, ts, body))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		// Network errors and timeouts: try again.
+		return true, fmt.Errorf("cannot send the email: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	resend, err = statusError(res.StatusCode)
+	if err != nil {
+		return resend, err
+	}
+
+	level.Info(p.logger).Log(
+		"package", "webhook",
+		"method", "send",
+		"result", res.Status,
+	)
+
+	return false, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of timestamp and body
+// joined by a dot. Receivers should compute it using the shared
+// secret and compare it to the signature header value.
+// Timestamp is included to let them reject replayed requests.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// statusError maps webhook response status codes
+// onto the provider resend contract.
+func statusError(status int) (resend bool, err error) {
+	switch {
+	case status >= 200 && status < 300:
+		return false, nil
+
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		// Receiver is busy: try again.
+		return true, fmt.Errorf("webhook not available - status code: '%d'", status)
+
+	case status >= 400 && status < 500:
+		// Receiver rejected the message.
+		return false, fmt.Errorf("webhook rejected the email - status code: '%d'", status)
+
+	default:
+		// 5xx Server errors, unexpected redirects.
+		return true, fmt.Errorf("cannot send email - status code: '%d'", status)
+	}
+}
+
+// encode returns request body and content type
+// according to provider format.
+func (p *WHProvider) encode(em *model.Email) (body []byte, contentType string, err error) {
+	if p.format == FormatRFC822 {
+		body, err = rfc822.Compose(em)
+		return body, "message/rfc822", err
+	}
+
+	m := message{
+		ID:       em.ID.String(),
+		From:     address{Name: em.Name, Email: em.From},
+		To:       em.To,
+		CC:       em.CC,
+		BCC:      em.BCC,
+		ReplyTo:  em.ReplyTo,
+		Subject:  em.Subject,
+		Body:     em.Body,
+		Charset:  em.Charset,
+		Headers:  em.Headers,
+		Metadata: em.Metadata,
+		Tags:     em.Tags,
+	}
+
+	body, err = json.Marshal(m)
+	return body, "application/json", err
+}
+
+func newProvider(ctx context.Context, cfg *config.Config, logger log.Logger, name ...string) (*WHProvider, error) {
+	p, ok := cfg.Provider(config.ProviderType.Webhook, name...)
+	if !ok {
+		return nil, fmt.Errorf("no provider of type '%s' in config", config.ProviderType.Webhook)
+	}
+
+	if p.Endpoint == "" {
+		return nil, fmt.Errorf("no endpoint configured for provider '%s'", p.Name)
+	}
+
+	format := p.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatRFC822 {
+		return nil, fmt.Errorf("invalid format '%s' for provider '%s'", p.Format, p.Name)
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defTimeout
+	}
+
+	return &WHProvider{
+		ctx:      ctx,
+		cfg:      cfg,
+		logger:   logger,
+		client:   &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		name:     p.Name,
+		priority: p.Priority,
+		url:      p.Endpoint,
+		secret:   []byte(p.APIKey),
+		format:   format,
+	}, nil
+}
+
+// Name return the provider name.
+func (p *WHProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority.
+func (p *WHProvider) Priority() int {
+	return p.priority
+}
+
+// Start the mailer.
+func (p *WHProvider) Start() error {
+	return nil
+}
+
+// Stop the mailer.
+func (p *WHProvider) Stop() error {
+	return nil
+}
+
+// IsReady return true if mailer is ready.
+func (p *WHProvider) IsReady() bool {
+	return true
+}
+
+// Client return the provider client.
+func (p *WHProvider) Client() interface{} {
+	return p.client
+}
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+const (
+	testSecret = "webhook-secret"
+)
+
+// TestSend checks that delivered messages are signed.
+func TestSend(t *testing.T) {
+	var received message
+	var valid bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sig := signatureScheme + Sign([]byte(testSecret), r.Header.Get(timestampHeader), body)
+		valid = sig == r.Header.Get(signatureHeader)
+		json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	p := newTestProvider(t, ts.URL, FormatJSON)
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com", Subject: "Subject", Body: "Body"}
+
+	resend, err := p.Send(em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if resend {
+		t.Error("Expected: no resend | Received: resend")
+	}
+
+	if !valid {
+		t.Error("Expected: valid signature | Received: invalid signature")
+	}
+
+	if received.ID != em.ID.String() || received.To != em.To {
+		t.Errorf("Expected: '%s' '%s' | Received: '%s' '%s'", em.ID.String(), em.To, received.ID, received.To)
+	}
+}
+
+// TestSendErrors checks response status mapping onto resend contract.
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		resend bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tc := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+
+		p := newTestProvider(t, ts.URL, FormatRFC822)
+		resend, err := p.Send(&model.Email{ID: uuid.New(), From: "sender@example.com", To: "to@example.com"})
+
+		if err == nil {
+			t.Errorf("Status %d - Expected: error | Received: no error", tc.status)
+		}
+
+		if resend != tc.resend {
+			t.Errorf("Status %d - Expected: resend %t | Received: resend %t", tc.status, tc.resend, resend)
+		}
+
+		ts.Close()
+	}
+}
+
+// TestSendPartial checks that personalizations sent
+// before a failure are reported so that they are not resent.
+func TestSendPartial(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		if n == 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	em := &model.Email{ID: uuid.New(), From: "sender@example.com"}
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		em.Personalizations = append(em.Personalizations, model.Personalization{ID: uuid.New(), To: to})
+	}
+
+	p := newTestProvider(t, ts.URL, FormatJSON)
+	resend, err := p.Send(em)
+
+	pe, ok := err.(*model.PartialError)
+	if !ok || len(pe.Sent) != 2 || !resend {
+		t.Errorf("Expected: two sent, resend the rest | Received: %v, resend %t", err, resend)
+	}
+}
+
+func newTestProvider(t *testing.T, endpoint, format string) *WHProvider {
+	cfg := &config.Config{
+		Mailer: config.MailerConfig{
+			Providers: []config.ProviderConfig{
+				{
+					Name:     "webhook",
+					Type:     config.ProviderType.Webhook.String(),
+					Enabled:  true,
+					Priority: 1,
+					APIKey:   testSecret,
+					Endpoint: endpoint,
+					Format:   format,
+				},
+			},
+		},
+	}
+
+	p, err := Init(context.Background(), cfg, log.NewLogfmtLogger(os.Stdout))
+	if err != nil {
+		t.Fatalf("Cannot initialize provider: %s", err.Error())
+	}
+
+	return p
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
-	mailer "github.com/adrianpk/poslan/pkg/mailer"
+	"os"
+
+	"github.com/adrianpk/poslan/internal/cli"
 )
 
 func main() {
-	mailer.Run()
-}
\ No newline at end of file
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -16,6 +16,9 @@ const (
 	expSeconds = 240
 )
 
+// ErrWrongCredentials is returned when client ID and secret do not match.
+var ErrWrongCredentials = errors.New("wrong credentials")
+
 // SecServer is an authentication service.
 type SecServer interface {
 	// Authenticate generate the bearer token.
@@ -71,7 +74,7 @@ func (s Server) Authenticate(clientID string, clientSecret string) (string, erro
 		}
 		return signed, nil
 	}
-	return "", errors.New("wrong credentials")
+	return "", ErrWrongCredentials
 }
 
 // ValidateToken validate if token is valid.
@@ -79,7 +82,7 @@ func (s Server) ValidateToken(token string) error {
 
 	t, err := jwt.Parse(token, s.Keys())
 
-	if t.Valid {
+	if t != nil && t.Valid {
 		return nil
 	}
 
@@ -195,7 +198,10 @@ func UserData(tokenString string) (userData map[string]string, err error) {
 		if key == "userID" {
 			userData["userID"] = val.(string)
 		}
-		if key == "Name" {
+		if key == "username" {
+			userData["username"] = val.(string)
+		}
+		if key == "name" {
 			userData["name"] = val.(string)
 		}
 		if key == "email" {
@@ -0,0 +1,61 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MakeAdminHandler returns the admin HTTP handler
+// serving Prometheus metrics, liveness and readiness and,
+// to admin clients, provider administration and the log
+// level if the service logger is leveled.
+func (svc *service) MakeAdminHandler() http.Handler {
+	providers := providersHandler(svc)
+
+	m := http.NewServeMux()
+	m.Handle("/metrics", promhttp.Handler())
+	m.HandleFunc("/live", svc.health.LiveEndpoint)
+	m.HandleFunc("/ready", svc.health.ReadyEndpoint)
+	m.Handle("/providers", providers)
+	m.Handle("/providers/", providers)
+	if l, ok := svc.logger.(*leveledLogger); ok {
+		m.Handle("/log/level", adminAuth(svc, logLevelHandler(l)))
+	}
+	return m
+}
+
+// runAdmin serves the admin handler until the context is done.
+func runAdmin(ctx context.Context, cfg *c.Config, svc *service, logger log.Logger) error {
+	l, err := net.Listen("tcp", cfg.App.AdminPortFmt())
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: svc.MakeAdminHandler()}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	level.Info(logger).Log("package", "mailer", "method", "runAdmin", "addr", l.Addr().String())
+
+	err = srv.Serve(l)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
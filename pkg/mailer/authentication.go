@@ -9,17 +9,19 @@ package mailer
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	"github.com/adrianpk/poslan/internal/config"
 	"github.com/adrianpk/poslan/pkg/auth"
+	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
+	zipkin "github.com/openzipkin/zipkin-go"
 )
 
 type authenticationMiddleware struct {
 	ctx    context.Context
-	cfg    *config.Config
 	logger log.Logger
 	auth   auth.SecServer
 	next   Service
@@ -32,11 +34,7 @@ func (mw authenticationMiddleware) SignIn(ctx context.Context, clientID, secret
 
 // SignOut is a logging middleware wrapper over another interface implementation of SignOut.
 func (mw authenticationMiddleware) SignOut(ctx context.Context, id uuid.UUID) (err error) {
-	token, ok := AuthToken(ctx)
-	if !ok {
-		return errors.New("invalid token")
-	}
-	err = mw.auth.ValidateToken(token)
+	err = mw.authenticate(ctx)
 	if err != nil {
 		return err
 	}
@@ -45,17 +43,48 @@ func (mw authenticationMiddleware) SignOut(ctx context.Context, id uuid.UUID) (e
 }
 
 // Send is a logging middleware wrapper over another interface implementation of Send.
-func (mw authenticationMiddleware) Send(ctx context.Context, to, cc, bcc, subject, body string) (err error) {
-	token, ok := AuthToken(ctx)
-	if !ok {
-		return errors.New("invalid token")
+func (mw authenticationMiddleware) Send(ctx context.Context, email *model.Email) (output *model.Email, err error) {
+	err = mw.authenticate(ctx)
+	if err != nil {
+		return nil, err
 	}
-	err = mw.auth.ValidateToken(token)
+	return mw.next.Send(ctx, email)
+}
+
+// SendBatch is an authentication middleware wrapper over another interface implementation of SendBatch.
+func (mw authenticationMiddleware) SendBatch(ctx context.Context, email *model.Email) (output *model.Email, err error) {
+	err = mw.authenticate(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = mw.next.Send(ctx, to, cc, bcc, subject, body)
-	return
+	return mw.next.SendBatch(ctx, email)
+}
+
+// Message is an authentication middleware wrapper over another interface implementation of Message.
+func (mw authenticationMiddleware) Message(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	err = mw.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.Message(ctx, id)
+}
+
+// Cancel is an authentication middleware wrapper over another interface implementation of Cancel.
+func (mw authenticationMiddleware) Cancel(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	err = mw.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.Cancel(ctx, id)
+}
+
+// Reschedule is an authentication middleware wrapper over another interface implementation of Reschedule.
+func (mw authenticationMiddleware) Reschedule(ctx context.Context, id uuid.UUID, sendAt time.Time) (output *model.MessageStatus, err error) {
+	err = mw.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.Reschedule(ctx, id, sendAt)
 }
 
 // Config returns service context.
@@ -65,7 +94,7 @@ func (mw authenticationMiddleware) Context() context.Context {
 
 // Config returns service config.
 func (mw authenticationMiddleware) Config() *config.Config {
-	return mw.cfg
+	return mw.next.Config()
 }
 
 // Config returns service logger.
@@ -73,8 +102,39 @@ func (mw authenticationMiddleware) Logger() log.Logger {
 	return mw.logger
 }
 
+// authenticate validates the auth token in context.
+func (mw authenticationMiddleware) authenticate(ctx context.Context) error {
+	token, ok := AuthToken(ctx)
+	if !ok {
+		return errInvalidToken
+	}
+
+	err := mw.auth.ValidateToken(token)
+	if err != nil {
+		level.Warn(ctxLogger(ctx, mw.logger)).Log(
+			"package", "mailer",
+			"method", "authenticate",
+			"error", err.Error(),
+		)
+		return errInvalidToken
+	}
+
+	// Tokens must identify the client.
+	ud, _ := ctx.Value(userDataCtxKey).(map[string]string)
+	if ud["clientID"] == "" {
+		return errForbidden
+	}
+
+	return nil
+}
+
 // AuthToken gets the auth token from the context.
 func AuthToken(ctx context.Context) (token string, ok bool) {
 	token, ok = ctx.Value(authTokenCtxKey).(string)
 	return token, ok
 }
+
+// Tracer returns service tracer.
+func (mw authenticationMiddleware) Tracer() *zipkin.Tracer {
+	return mw.next.Tracer()
+}
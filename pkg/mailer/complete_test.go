@@ -1,15 +1,34 @@
 package mailer
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httptest"
+	"net/smtp"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/mailer/mailertest"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/adrianpk/poslan/pkg/pb"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/google/uuid"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // Unit tests only: go test -v -short
@@ -19,18 +38,37 @@ var (
 	protocol       = "http"
 	host           = "localhost"
 	port           = 8080
-	serverInstance *httptest.Server
+	smtpPort       = 2525
+	grpcPort       = 8081
+	adminPort      = 8090
+	baseURL        string
 	signinURL      string
 	signoutURL     string
 	sendURL        string
+	batchURL       string
 	user1          = "5958b185-8150-4aae-b53f-0c44771ddec5"
 	user2          = "3c05e701-b495-4443-b454-2c37e2ecccdf"
+	clientID       = "dd74cb9cfb5a4f1cac4d"
+	clientSecret   = "a5ee54c8a21a4c61820f88f14c30fa5b"
+	client2ID      = "984fd4bdcb374aa7836a"
+	client2Secret  = "98d28599e5554a9ea4ada53feae924ff"
+	sesServer      *mailertest.SESServer
+	sendgridServer *mailertest.SendGridServer
+	fallback       *mailertest.Provider
+	scheduleDir    string
+	spansFile      string
 )
 
 func init() {
-	signinURL = fmt.Sprintf("%s://%s:%d/signin", protocol, host, port)
-	signoutURL = fmt.Sprintf("%s://%s:%d/signout", protocol, host, port)
-	sendURL = fmt.Sprintf("%s://%s:%d/send", protocol, host, port)
+	port = freePort()
+	smtpPort = freePort()
+	grpcPort = freePort()
+	adminPort = freePort()
+	baseURL = fmt.Sprintf("%s://%s:%d", protocol, host, port)
+	signinURL = baseURL + "/v1/signin"
+	signoutURL = baseURL + "/v1/signout"
+	sendURL = baseURL + "/v1/send"
+	batchURL = baseURL + "/v1/send/batch"
 }
 
 func TestMain(m *testing.M) {
@@ -46,43 +84,1145 @@ func TestSomething(t *testing.T) {
 	t.Skip("Skiping unit test at the moment.")
 }
 
-// TestSendIntegration is a base unit test reference.
+// TestSendIntegration sends a mail through the first provider.
 func TestSendIntegration(t *testing.T) {
-	// handlers := &MyHandler{}
-	// server := httptest.NewServer(handlers)
-	// defer server.Close()
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+
+	send(t)
+
+	ms := sesServer.Messages()
+	if len(ms) != 1 {
+		t.Fatalf("Expected: 1 SES message | Received: %d", len(ms))
+	}
+
+	if ms[0].Tags["campaign"] != "welcome" {
+		t.Errorf("Expected: 'welcome' campaign tag | Received: '%s'", ms[0].Tags["campaign"])
+	}
+
+	if len(sendgridServer.Messages()) != 0 {
+		t.Errorf("Expected: 0 SendGrid messages | Received: %d", len(sendgridServer.Messages()))
+	}
+}
 
+// TestFailoverIntegration sends a mail through the second provider
+// when the first one rejects it.
+func TestFailoverIntegration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skiping integration test.")
 	}
 
-	t.Log("TestSignup started.")
-	emailJSON := `
-	{
-		"data": {
-			"to": "sendmailtest@sharklasers.com",
-			"cc": "sendmailtest@sharklasers.com",
-			"bcc": "sendmailtest@sharklasers.com",
-			"subject": "Subject",
-			"body": "Body text."
+	reset()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+
+	res := send(t)
+
+	ms := sendgridServer.Messages()
+	if len(ms) != 1 {
+		t.Fatalf("Expected: 1 SendGrid message | Received: %d", len(ms))
+	}
+
+	if ms[0].CustomArgs["poslan_message_id"] != res.ID.String() {
+		t.Errorf("Expected: '%s' | Received: '%s'", res.ID.String(), ms[0].CustomArgs["poslan_message_id"])
+	}
+}
+
+// TestFallbackIntegration sends a mail through the in-memory provider
+// when all the others fail.
+func TestFallbackIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+	sendgridServer.FailNext(http.StatusInternalServerError)
+
+	send(t)
+
+	if len(fallback.Messages()) != 1 {
+		t.Errorf("Expected: 1 in-memory message | Received: %d", len(fallback.Messages()))
+	}
+}
+
+// TestSMTPIntegration submits a mail over SMTP
+// and checks it is sent through the first provider.
+func TestSMTPIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+
+	msg := "To: sendmailtest@sharklasers.com\r\n" +
+		"Subject: Subject\r\n" +
+		"X-Campaign: smtp\r\n" +
+		"\r\n" +
+		"Body text.\r\n"
+
+	addr := net.JoinHostPort(host, fmt.Sprintf("%d", smtpPort))
+	auth := smtp.PlainAuth("", clientID, clientSecret, host)
+	rcpt := []string{"sendmailtest@sharklasers.com", "bcc@sharklasers.com"}
+
+	err := smtp.SendMail(addr, auth, "sender@example.com", rcpt, []byte(msg))
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	ms := sesServer.Messages()
+	if len(ms) != 1 {
+		t.Fatalf("Expected: 1 SES message | Received: %d", len(ms))
+	}
+
+	if len(ms[0].Destinations) != 2 {
+		t.Errorf("Expected: 2 destinations | Received: %d", len(ms[0].Destinations))
+	}
+
+	if !bytes.Contains(ms[0].Raw, []byte("X-Campaign: smtp")) {
+		t.Error("Expected: 'X-Campaign' header | Received: no header")
+	}
+
+	// Invalid credentials.
+	auth = smtp.PlainAuth("", clientID, "invalid", host)
+	err = smtp.SendMail(addr, auth, "sender@example.com", rcpt, []byte(msg))
+	if err == nil {
+		t.Error("Expected: error | Received: no error")
+	}
+}
+
+// TestGRPCIntegration signs in, sends a mail
+// and gets its status over gRPC.
+func TestGRPCIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+
+	conn, err := grpc.Dial(net.JoinHostPort(host, fmt.Sprintf("%d", grpcPort)), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("[ERROR] Dial error: %s", err.Error())
+	}
+	defer conn.Close()
+
+	client := pb.NewPoslanClient(conn)
+	ctx := context.Background()
+
+	req := &pb.SendRequest{
+		To:       "sendmailtest@sharklasers.com",
+		Subject:  "Subject",
+		Body:     "Body text.",
+		Metadata: map[string]string{"campaign": "grpc"},
+	}
+
+	// Without token.
+	_, err = client.Send(ctx, req)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected: Unauthenticated | Received: %v", err)
+	}
+
+	si, err := client.SignIn(ctx, &pb.SignInRequest{ClientId: clientID, Secret: clientSecret})
+	if err != nil {
+		t.Fatalf("[ERROR] Sign in error: %s", err.Error())
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+si.Token)
+
+	var header metadata.MD
+	res, err := client.Send(metadata.AppendToOutgoingContext(ctx, "x-request-id", "grpc-1"), req, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if ids := header.Get("x-request-id"); len(ids) != 1 || ids[0] != "grpc-1" {
+		t.Errorf("Expected: 'grpc-1' request ID | Received: %v", ids)
+	}
+
+	ms := sesServer.Messages()
+	if len(ms) != 1 || ms[0].Tags["campaign"] != "grpc" || ms[0].Tags["poslan_request_id"] != "grpc-1" {
+		t.Errorf("Expected: 1 SES message tagged 'grpc' | Received: %+v", ms)
+	}
+
+	st, err := client.GetMessage(ctx, &pb.GetMessageRequest{Id: res.Email.Id})
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if st.Status != model.StatusSent || st.Provider != "amazon" || st.Attempts != 1 {
+		t.Errorf("Expected: sent by amazon after 1 attempt | Received: %s by %s after %d attempts", st.Status, st.Provider, st.Attempts)
+	}
+
+	_, err = client.ListTemplates(ctx, &pb.ListTemplatesRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected: Unimplemented | Received: %v", err)
+	}
+
+	_, err = client.Send(ctx, &pb.SendRequest{To: "to@example.com", Template: "welcome"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected: Unimplemented | Received: %v", err)
+	}
+}
+
+// TestHTTPErrorsIntegration checks error status codes
+// and problem+json bodies.
+func TestHTTPErrorsIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	token := signIn(t)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		token  string
+		body   string
+		status int
+	}{
+		{"unknown route", "GET", baseURL + "/v1/unknown", token, "", http.StatusNotFound},
+		{"method not allowed", "GET", sendURL, token, "", http.StatusMethodNotAllowed},
+		{"invalid credentials", "POST", signinURL, "", `{"clientID": "invalid", "secret": "invalid"}`, http.StatusUnauthorized},
+		{"no token", "POST", sendURL, "", `{"to": "to@example.com"}`, http.StatusUnauthorized},
+		{"malformed body", "POST", sendURL, token, `{"to":`, http.StatusBadRequest},
+		{"no recipients", "POST", sendURL, token, `{"subject": "Subject"}`, http.StatusUnprocessableEntity},
+		{"unknown field", "POST", sendURL, token, `{"to": "to@example.com", "username": "jane"}`, http.StatusUnprocessableEntity},
+		{"invalid recipient", "POST", sendURL, token, `{"to": "to"}`, http.StatusUnprocessableEntity},
+		{"empty batch", "POST", batchURL, token, `{"subject": "Subject", "recipients": []}`, http.StatusUnprocessableEntity},
+		{"invalid batch", "POST", batchURL, token, `{"recipients": [{"to": "invalid"}]}`, http.StatusUnprocessableEntity},
+		{"batch template", "POST", batchURL, token, `{"template": "welcome", "recipients": [{"to": "to@example.com"}]}`, http.StatusNotImplemented},
+		{"missing secret", "POST", signinURL, "", `{"clientID": "invalid"}`, http.StatusUnprocessableEntity},
+		{"invalid message id", "GET", baseURL + "/v1/messages/invalid", token, "", http.StatusBadRequest},
+		{"unknown message", "GET", baseURL + "/v1/messages/" + user1, token, "", http.StatusNotFound},
+		{"sign out", "POST", signoutURL, token, `{}`, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] %s: %s", tt.name, err.Error())
+		}
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		res.Body.Close()
+
+		if res.StatusCode != tt.status || p.Status != tt.status {
+			t.Errorf("%s - Expected: %d | Received: %d (body %d)", tt.name, tt.status, res.StatusCode, p.Status)
+		}
+
+		if ct := res.Header.Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s - Expected: 'application/problem+json' | Received: '%s'", tt.name, ct)
 		}
 	}
-	`
-	reader := strings.NewReader(emailJSON)
-	req, _ := http.NewRequest("POST", sendURL, reader)
+
+	// Delivery failure.
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+	sendgridServer.FailNext(http.StatusInternalServerError)
+	fallback.FailNext(1, true, errors.New("fallback failed"))
+
+	req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(`{"to": "to@example.com"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Send error: %s", err.Error())
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected: 502 | Received: %d", res.StatusCode)
+	}
+}
+
+// TestValidationIntegration checks field errors
+// of requests not matching their schema.
+func TestValidationIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	token := signIn(t)
+
+	body := `{"to": "to@example.com", "personalizations": [{"to": "invalid"}, {}]}`
+	req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Send error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	var p problem
+	json.NewDecoder(res.Body).Decode(&p)
+
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected: 422 | Received: %d", res.StatusCode)
+	}
+
+	fields := []string{"personalizations.0.to", "personalizations.1.to"}
+	if len(p.Errors) != len(fields) {
+		t.Fatalf("Expected: %d field errors | Received: %+v", len(fields), p.Errors)
+	}
+
+	for i, f := range fields {
+		if p.Errors[i].Field != f {
+			t.Errorf("Expected: '%s' | Received: '%s'", f, p.Errors[i].Field)
+		}
+	}
+}
+
+// TestOpenAPIIntegration gets the API specification.
+func TestOpenAPIIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	res, err := http.Get(baseURL + "/v1/openapi.json")
+	if err != nil {
+		t.Fatalf("[ERROR] OpenAPI error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected: 200 | Received: %d", res.StatusCode)
+	}
+
+	var doc struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	json.NewDecoder(res.Body).Decode(&doc)
+
+	if doc.OpenAPI == "" {
+		t.Errorf("Expected: openapi version | Received: ''")
+	}
+
+	for _, path := range []string{"/signin", "/signout", "/send", "/messages/{id}"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("Expected: path '%s' | Received: %v", path, doc.Paths)
+		}
+	}
+}
+
+// TestSendBatchIntegration sends a batch with an invalid recipient
+// through SendGrid, that receives valid ones in a single request.
+func TestSendBatchIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+	token := signIn(t)
+
+	body := `{
+		"subject": "Hi {{name}}",
+		"body": "Hello {{name}}.",
+		"recipients": [
+			{"to": "diana@example.com", "substitutions": {"{{name}}": "Diana"}},
+			{"to": "invalid"},
+			{"to": "bruce@example.com", "substitutions": {"{{name}}": "Bruce"}}
+		]
+	}`
+
+	req, _ := http.NewRequest("POST", batchURL, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Send batch error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected: 200 | Received: %d", res.StatusCode)
+	}
+
+	var br sendBatchResponse
+	json.NewDecoder(res.Body).Decode(&br)
+
+	if br.Accepted != 2 || br.Rejected != 1 || len(br.Items) != 3 {
+		t.Fatalf("Expected: 2 accepted, 1 rejected | Received: %+v", br)
+	}
+
+	rejected := br.Items[1]
+	if rejected.Status != batchRejected || rejected.ID != "" || len(rejected.Errors) != 1 || rejected.Errors[0].Field != "recipients.1.to" {
+		t.Errorf("Expected: rejected 'recipients.1.to' | Received: %+v", rejected)
+	}
+
+	sgms := sendgridServer.Messages()
+	if len(sgms) != 1 || len(sgms[0].Personalizations) != 2 {
+		t.Fatalf("Expected: 1 SendGrid request with 2 personalizations | Received: %d requests", len(sgms))
+	}
+
+	for i, item := range []batchItem{br.Items[0], br.Items[2]} {
+		if item.Status != batchAccepted {
+			t.Errorf("Expected: '%s' | Received: '%s'", batchAccepted, item.Status)
+		}
+
+		if id := sgms[0].Personalizations[i].CustomArgs["poslan_message_id"]; id != item.ID {
+			t.Errorf("Expected: '%s' | Received: '%s'", item.ID, id)
+		}
+
+		req, _ := http.NewRequest("GET", baseURL+"/v1/messages/"+item.ID, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] Message error: %s", err.Error())
+		}
+
+		var mr messageResponse
+		json.NewDecoder(res.Body).Decode(&mr)
+		res.Body.Close()
+
+		if mr.Status == nil || mr.Status.Provider != "sendgrid" {
+			t.Errorf("Expected: sent by sendgrid | Received: %+v", mr.Status)
+		}
+	}
+}
+
+// TestScheduleIntegration schedules, reschedules
+// and cancels messages.
+func TestScheduleIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	token := signIn(t)
+
+	do := func(method, url, body string, v interface{}) int {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] %s %s: %s", method, url, err.Error())
+		}
+		defer res.Body.Close()
+
+		json.NewDecoder(res.Body).Decode(v)
+		return res.StatusCode
+	}
+
+	schedule := func(body string) string {
+		var sr sendResponse
+		if status := do("POST", sendURL, body, &sr); status != http.StatusOK || sr.ID == uuid.Nil {
+			t.Fatalf("Expected: 200 | Received: %d", status)
+		}
+		return baseURL + "/v1/messages/" + sr.ID.String()
+	}
+
+	// Delayed then sent after rescheduling.
+	url := schedule(`{"to": "to@example.com", "delay": "1h"}`)
+
+	var mr messageResponse
+	do("GET", url, "", &mr)
+	if mr.Status == nil || mr.Status.Status != model.StatusScheduled {
+		t.Fatalf("Expected: '%s' | Received: %+v", model.StatusScheduled, mr.Status)
+	}
+
+	if len(sesServer.Messages()) != 0 {
+		t.Errorf("Expected: no message sent | Received: %d", len(sesServer.Messages()))
+	}
+
+	sendAt := time.Now().Add(100 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+	if status := do("PATCH", url, `{"sendAt": "`+sendAt+`"}`, &mr); status != http.StatusOK {
+		t.Errorf("Expected: 200 | Received: %d", status)
+	}
+
+	for i := 0; i < 20 && mr.Status.Status == model.StatusScheduled; i++ {
+		time.Sleep(100 * time.Millisecond)
+		do("GET", url, "", &mr)
+	}
+
+	if mr.Status.Status != model.StatusSent || len(sesServer.Messages()) != 1 {
+		t.Errorf("Expected: '%s' | Received: %+v", model.StatusSent, mr.Status)
+	}
+
+	if status := do("DELETE", url, "", &problem{}); status != http.StatusConflict {
+		t.Errorf("Expected: 409 | Received: %d", status)
+	}
+
+	// Scheduled then canceled.
+	url = schedule(`{"to": "to@example.com", "sendAt": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`)
+
+	if status := do("DELETE", url, "", &mr); status != http.StatusOK || mr.Status.Status != model.StatusCanceled {
+		t.Errorf("Expected: '%s' | Received: %d %+v", model.StatusCanceled, status, mr.Status)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(scheduleDir, "*"+scheduledExt))
+	if len(files) != 0 {
+		t.Errorf("Expected: no stored messages | Received: %d", len(files))
+	}
+
+	// Stored messages cannot be changed behind the service.
+	q, _ := OpenQueue(scheduleDir)
+	if _, err := q.Purge(uuid.New()); err != errDirLocked {
+		t.Errorf("Expected: %s | Received: %v", errDirLocked, err)
+	}
+
+	// Invalid schedules.
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		status int
+	}{
+		{"POST", sendURL, `{"to": "to@example.com", "delay": "1h", "sendAt": "2019-06-01T10:00:00Z"}`, http.StatusUnprocessableEntity},
+		{"POST", sendURL, `{"to": "to@example.com", "delay": "tomorrow"}`, http.StatusUnprocessableEntity},
+		{"PATCH", url, `{}`, http.StatusUnprocessableEntity},
+		{"PATCH", url, `{"delay": "1h"}`, http.StatusConflict},
+		{"PATCH", baseURL + "/v1/messages/" + user1, `{"delay": "1h"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if status := do(tt.method, tt.url, tt.body, &problem{}); status != tt.status {
+			t.Errorf("%s %s - Expected: %d | Received: %d", tt.method, tt.body, tt.status, status)
+		}
+	}
+}
+
+// TestIdempotencyIntegration sends requests with idempotency keys.
+func TestIdempotencyIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	token := signIn(t)
+
+	post := func(key, body string) (*http.Response, sendResponse) {
+		req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", key)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] Send error: %s", err.Error())
+		}
+		defer res.Body.Close()
+
+		var sr sendResponse
+		json.NewDecoder(res.Body).Decode(&sr)
+		return res, sr
+	}
+
+	body := `{"to": "to@example.com", "subject": "Subject"}`
+	key := uuid.New().String()
+
+	// Replay
+	res1, sr1 := post(key, body)
+	res2, sr2 := post(key, body)
+
+	if res1.StatusCode != http.StatusOK || res2.StatusCode != http.StatusOK {
+		t.Fatalf("Expected: 200 | Received: %d, %d", res1.StatusCode, res2.StatusCode)
+	}
+
+	if sr1.ID != sr2.ID || res2.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected: replayed '%s' | Received: '%s'", sr1.ID, sr2.ID)
+	}
+
+	if n := len(sesServer.Messages()); n != 1 {
+		t.Errorf("Expected: 1 message sent | Received: %d", n)
+	}
+
+	// Reused with a different request
+	if res, _ := post(key, `{"to": "other@example.com"}`); res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected: 422 | Received: %d", res.StatusCode)
+	}
+
+	// Failed requests can be retried
+	key = uuid.New().String()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+	sendgridServer.FailNext(http.StatusInternalServerError)
+	fallback.FailNext(1, true, errors.New("fallback failed"))
+
+	if res, _ := post(key, body); res.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected: 502 | Received: %d", res.StatusCode)
+	}
+
+	if res, _ := post(key, body); res.StatusCode != http.StatusOK {
+		t.Errorf("Expected: 200 | Received: %d", res.StatusCode)
+	}
+
+	// Concurrent duplicate
+	key = uuid.New().String()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+	sendgridServer.FailNext(http.StatusInternalServerError)
+	fallback.SetLatency(300 * time.Millisecond)
+	defer fallback.SetLatency(0)
+
+	done := make(chan int)
+	go func() {
+		res, _ := post(key, body)
+		done <- res.StatusCode
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if res, _ := post(key, body); res.StatusCode != http.StatusConflict {
+		t.Errorf("Expected: 409 | Received: %d", res.StatusCode)
+	}
+
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("Expected: 200 | Received: %d", status)
+	}
+}
+
+// TestAdminIntegration checks metrics, liveness
+// and readiness endpoints.
+func TestAdminIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	send(t)
+
+	adminURL := fmt.Sprintf("%s://%s:%d", protocol, host, adminPort)
+
+	get := func(path string) (int, string) {
+		res, err := http.Get(adminURL + path)
+		if err != nil {
+			t.Fatalf("[ERROR] GET %s: %s", path, err.Error())
+		}
+		defer res.Body.Close()
+
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	status, body := get("/metrics")
+	if status != http.StatusOK || !strings.Contains(body, `poslan_mailer_request_count{error="false",method="Send"}`) {
+		t.Errorf("Expected: Send request count | Received: %d", status)
+	}
+
+	for _, path := range []string{"/live", "/ready"} {
+		if status, body := get(path); status != http.StatusOK {
+			t.Errorf("%s - Expected: 200 | Received: %d %s", path, status, body)
+		}
+	}
+
+	// Not ready after repeated failures of every provider.
+	for i := 0; i < maxProviderFailures; i++ {
+		sesServer.FailNext(ses.ErrCodeMessageRejected)
+		sendgridServer.FailNext(http.StatusInternalServerError)
+		fallback.FailNext(1, true, errors.New("fallback failed"))
+
+		req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(`{"to": "to@example.com"}`))
+		req.Header.Set("Authorization", "Bearer "+signIn(t))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] Send error: %s", err.Error())
+		}
+		res.Body.Close()
+	}
+
+	if status, body := get("/ready"); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected: 503 | Received: %d %s", status, body)
+	}
+
+	_, body = get("/metrics")
+	for _, m := range []string{
+		`poslan_provider_attempts_total{provider="amazon"}`,
+		`poslan_provider_successes_total{provider="amazon"}`,
+		`poslan_provider_failures_total{class="transient",provider="fallback"}`,
+		`poslan_provider_failovers_total{from="amazon",to="sendgrid"}`,
+		`poslan_provider_latency_seconds_count{provider="sendgrid"}`,
+		`poslan_client_messages_total{client="` + clientID + `",status="failed"}`,
+		`poslan_scheduler_queue_depth`,
+	} {
+		if !strings.Contains(body, m) {
+			t.Errorf("Expected: metric %s | Received: none", m)
+		}
+	}
+
+	// Ready again after a successful delivery.
+	send(t)
+
+	if status, body := get("/ready"); status != http.StatusOK {
+		t.Errorf("Expected: 200 | Received: %d %s", status, body)
+	}
+}
+
+// TestLogLevelIntegration changes the log level at runtime.
+func TestLogLevelIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	url := fmt.Sprintf("%s://%s:%d/log/level", protocol, host, adminPort)
+	admin := signIn(t)
+	other := signInAs(t, client2ID, client2Secret)
+
+	do := func(token, method, body string) (int, logLevelBody) {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] %s %s: %s", method, url, err.Error())
+		}
+		defer res.Body.Close()
+
+		var llb logLevelBody
+		json.NewDecoder(res.Body).Decode(&llb)
+		return res.StatusCode, llb
+	}
+
+	tests := []struct {
+		token  string
+		method string
+		body   string
+		status int
+		level  string
+	}{
+		{admin, "GET", "", http.StatusOK, "debug"},
+		{"", "PUT", `{"level": "error"}`, http.StatusUnauthorized, ""},
+		{other, "PUT", `{"level": "error"}`, http.StatusForbidden, ""},
+		{admin, "PUT", `{"level": "warn"}`, http.StatusOK, "warn"},
+		{admin, "PUT", `{"level": "verbose"}`, http.StatusBadRequest, ""},
+		{admin, "GET", "", http.StatusOK, "warn"},
+		{admin, "DELETE", "", http.StatusMethodNotAllowed, ""},
+		{admin, "PUT", `{"level": "debug"}`, http.StatusOK, "debug"},
+	}
+
+	for _, tt := range tests {
+		status, llb := do(tt.token, tt.method, tt.body)
+		if status != tt.status || llb.Level != tt.level {
+			t.Errorf("%s %s - Expected: %d '%s' | Received: %d '%s'", tt.method, tt.body, tt.status, tt.level, status, llb.Level)
+		}
+	}
+}
+
+// TestReloadIntegration reloads config on SIGHUP and checks new
+// provider priorities are applied and invalid configs are not.
+func TestReloadIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	metricsURL := fmt.Sprintf("%s://%s:%d/metrics", protocol, host, adminPort)
+
+	reloadCount := func(result string) float64 {
+		res, err := http.Get(metricsURL)
+		if err != nil {
+			t.Fatalf("[ERROR] GET %s: %s", metricsURL, err.Error())
+		}
+		defer res.Body.Close()
+
+		body, _ := ioutil.ReadAll(res.Body)
+		prefix := fmt.Sprintf(`poslan_config_reloads_total{result="%s"} `, result)
+		for _, line := range strings.Split(string(body), "\n") {
+			if strings.HasPrefix(line, prefix) {
+				var n float64
+				fmt.Sscanf(strings.TrimPrefix(line, prefix), "%g", &n)
+				return n
+			}
+		}
+		return 0
+	}
+
+	reload := func(result string, env map[string]string) {
+		for k, v := range env {
+			os.Setenv(k, v)
+		}
+
+		n := reloadCount(result)
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+		for i := 0; i < 50; i++ {
+			if reloadCount(result) > n {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatalf("Expected: config reload %s | Received: none", result)
+	}
+
+	// Restore startup config.
+	defer reload(reloadSuccess, map[string]string{
+		"PROVIDER_PRIORITY_1": "1",
+		"PROVIDER_PRIORITY_2": "2",
+	})
+
+	// SendGrid becomes the first provider.
+	reload(reloadSuccess, map[string]string{
+		"PROVIDER_PRIORITY_1": "2",
+		"PROVIDER_PRIORITY_2": "1",
+	})
+
+	reset()
+	send(t)
+
+	if len(sendgridServer.Messages()) != 1 || len(sesServer.Messages()) != 0 {
+		t.Errorf("Expected: 1 SendGrid, 0 SES messages | Received: %d, %d", len(sendgridServer.Messages()), len(sesServer.Messages()))
+	}
+
+	// Invalid config is not applied.
+	reload(reloadFailure, map[string]string{
+		"PROVIDER_PRIORITY_1": "0",
+	})
+
+	reset()
+	send(t)
+
+	if len(sendgridServer.Messages()) != 1 || len(sesServer.Messages()) != 0 {
+		t.Errorf("Expected: 1 SendGrid, 0 SES messages | Received: %d, %d", len(sendgridServer.Messages()), len(sesServer.Messages()))
+	}
+}
+
+// TestProvidersIntegration lists providers and checks
+// disabled, drained and reprioritized ones are routed around.
+func TestProvidersIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	adminURL := fmt.Sprintf("%s://%s:%d/providers", protocol, host, adminPort)
+	token := signIn(t)
+
+	do := func(method, path, token, body string, v interface{}) int {
+		req, _ := http.NewRequest(method, adminURL+path, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] %s %s: %s", method, path, err.Error())
+		}
+		defer res.Body.Close()
+
+		if v != nil {
+			json.NewDecoder(res.Body).Decode(v)
+		}
+		return res.StatusCode
+	}
+
+	// Only admin clients.
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"invalid token", "invalid", http.StatusUnauthorized},
+		{"not admin", signInAs(t, client2ID, client2Secret), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		if received := do("GET", "", tt.token, "", nil); received != tt.expected {
+			t.Errorf("%s - Expected: %d | Received: %d", tt.name, tt.expected, received)
+		}
+	}
+
+	var pb providersBody
+	if status := do("GET", "", token, "", &pb); status != http.StatusOK || len(pb.Providers) != 3 {
+		t.Fatalf("Expected: 200, 3 providers | Received: %d, %+v", status, pb.Providers)
+	}
+
+	amazon := pb.Providers[0]
+	if amazon.Name != "amazon" || amazon.State != providerEnabled || !amazon.Available {
+		t.Errorf("Expected: enabled and available amazon provider | Received: %+v", amazon)
+	}
+
+	if amazon.Config == nil || amazon.Config.APIKey != redactedValue || amazon.Config.IDKey != redactedValue {
+		t.Errorf("Expected: masked amazon secrets | Received: %+v", amazon.Config)
+	}
+
+	if fb := pb.Providers[2]; fb.Name != "fallback" || fb.Config != nil {
+		t.Errorf("Expected: fallback provider without config | Received: %+v", fb)
+	}
+
+	// Restore providers.
+	defer func() {
+		do("POST", "/amazon/enable", token, "", nil)
+		do("POST", "/sendgrid/enable", token, "", nil)
+		do("PUT", "/fallback/priority", token, `{"priority": 0}`, nil)
+	}()
+
+	// Disabled providers are not used.
+	var p providerBody
+	if status := do("POST", "/amazon/disable", token, "", &p); status != http.StatusOK || p.State != providerDisabled || p.Available {
+		t.Errorf("Expected: 200 disabled | Received: %d %+v", status, p)
+	}
+
+	reset()
+	send(t)
+
+	if len(sesServer.Messages()) != 0 || len(sendgridServer.Messages()) != 1 {
+		t.Errorf("Expected: 0 SES, 1 SendGrid messages | Received: %d, %d", len(sesServer.Messages()), len(sendgridServer.Messages()))
+	}
+
+	// Reprioritized providers are tried first.
+	if status := do("PUT", "/fallback/priority", token, `{"priority": 1}`, &p); status != http.StatusOK || p.Priority != 1 {
+		t.Errorf("Expected: 200 priority 1 | Received: %d %+v", status, p)
+	}
+
+	reset()
+	send(t)
+
+	if len(fallback.Messages()) != 1 || len(sendgridServer.Messages()) != 0 {
+		t.Errorf("Expected: 1 in-memory, 0 SendGrid messages | Received: %d, %d", len(fallback.Messages()), len(sendgridServer.Messages()))
+	}
+
+	// Drained providers are not used.
+	if status := do("POST", "/fallback/drain", token, "", &p); status != http.StatusOK || p.State != providerDrained {
+		t.Errorf("Expected: 200 drained | Received: %d %+v", status, p)
+	}
+
+	reset()
+	send(t)
+
+	if len(fallback.Messages()) != 0 || len(sendgridServer.Messages()) != 1 {
+		t.Errorf("Expected: 0 in-memory, 1 SendGrid messages | Received: %d, %d", len(fallback.Messages()), len(sendgridServer.Messages()))
+	}
+
+	// Enabled again.
+	if status := do("POST", "/amazon/enable", token, "", &p); status != http.StatusOK || p.State != providerEnabled {
+		t.Errorf("Expected: 200 enabled | Received: %d %+v", status, p)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"unknown provider", "GET", "/unknown", "", http.StatusNotFound},
+		{"negative priority", "PUT", "/amazon/priority", `{"priority": -1}`, http.StatusBadRequest},
+		{"unknown action", "POST", "/amazon/restart", "", http.StatusNotFound},
+		{"method not allowed", "GET", "/amazon/enable", "", http.StatusMethodNotAllowed},
+	} {
+		if received := do(tt.method, tt.path, token, tt.body, nil); received != tt.expected {
+			t.Errorf("%s - Expected: %d | Received: %d", tt.name, tt.expected, received)
+		}
+	}
+}
+
+// TestTracingIntegration checks that spans continue
+// the caller trace and that each provider attempt is traced.
+func TestTracingIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+
+	traceID := "0af7651916cd43dd8448eb211c80319c"
+	parentID := "b7ad6b7169203331"
+
+	req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(`{"to": "to@example.com"}`))
+	req.Header.Set("Authorization", "Bearer "+signIn(t))
+	req.Header.Set(traceparentHeader, "00-"+traceID+"-"+parentID+"-01")
 
 	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Send error: %s", err.Error())
+	}
+	res.Body.Close()
+
+	// Server span is finished once the response is written.
+	var spans map[string]zipkinmodel.SpanModel
+	for i := 0; i < 20; i++ {
+		spans = traceSpans(t, traceID)
+		if _, ok := spans["POST /v1/send"]; ok {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	server, ok := spans["POST /v1/send"]
+	if !ok || server.Kind != zipkinmodel.Server || server.ParentID == nil || server.ParentID.String() != parentID {
+		t.Fatalf("Expected: server span child of '%s' | Received: %+v", parentID, server)
+	}
+
+	send, ok := spans["Send"]
+	if !ok || send.ParentID == nil || *send.ParentID != server.ID {
+		t.Errorf("Expected: Send span child of server span | Received: %+v", send)
+	}
+
+	tests := []struct {
+		name    string
+		outcome string
+		err     bool
+	}{
+		{"send amazon", errorClassTransient, true},
+		{"send sendgrid", model.StatusSent, false},
+	}
 
+	for _, tt := range tests {
+		s, ok := spans[tt.name]
+		if !ok {
+			t.Errorf("%s - Expected: span | Received: none", tt.name)
+			continue
+		}
+
+		_, hasErr := s.Tags["error"]
+		if s.Tags[outcomeTag] != tt.outcome || hasErr != tt.err || s.ParentID == nil || *s.ParentID != send.ID {
+			t.Errorf("%s - Expected: outcome '%s' child of Send span | Received: %+v", tt.name, tt.outcome, s)
+		}
+	}
+}
+
+// traceSpans returns the exported spans of a trace by name.
+func traceSpans(t *testing.T, traceID string) map[string]zipkinmodel.SpanModel {
+	data, err := ioutil.ReadFile(spansFile)
 	if err != nil {
-		t.Logf("[ERROR] TestSendIntegration error: %s", err.Error())
+		t.Fatalf("[ERROR] %s", err.Error())
 	}
 
+	spans := make(map[string]zipkinmodel.SpanModel)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var s zipkinmodel.SpanModel
+		if err := json.Unmarshal([]byte(line), &s); err == nil && s.TraceID.String() == traceID {
+			spans[s.Name] = s
+		}
+	}
+	return spans
+}
+
+// TestMessageIntegration gets the status of a sent message.
+func TestMessageIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+
+	sent := send(t)
+	token := signIn(t)
+
+	req, _ := http.NewRequest("GET", baseURL+"/v1/messages/"+sent.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Message error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
 	if res.StatusCode != http.StatusOK {
-		t.Errorf("Expected: 200 - StatusOK | Received: Status: %d - %s", res.StatusCode, http.StatusText(res.StatusCode))
+		t.Fatalf("Expected: 200 | Received: %d", res.StatusCode)
+	}
+
+	var mr messageResponse
+	json.NewDecoder(res.Body).Decode(&mr)
+
+	if mr.Status == nil || mr.Status.Provider != "sendgrid" || mr.Status.Attempts != 2 {
+		t.Errorf("Expected: sent by sendgrid after 2 attempts | Received: %+v", mr.Status)
+	}
+}
+
+// TestRequestIDIntegration checks that request IDs are echoed,
+// recorded in message status and forwarded to providers.
+func TestRequestIDIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skiping integration test.")
+	}
+
+	reset()
+	sesServer.FailNext(ses.ErrCodeMessageRejected)
+	token := signIn(t)
+
+	sendWithID := func(id string) (*http.Response, sendResponse) {
+		req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(`{"to": "to@example.com", "subject": "Subject", "body": "Body."}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(requestIDHeader, id)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("[ERROR] Send error: %s", err.Error())
+		}
+		defer res.Body.Close()
+
+		var sr sendResponse
+		json.NewDecoder(res.Body).Decode(&sr)
+		return res, sr
+	}
+
+	// SES rejects the first message so it is delivered by SendGrid.
+	res, sent := sendWithID("req-1")
+	if res.Header.Get(requestIDHeader) != "req-1" {
+		t.Errorf("Expected: 'req-1' response request ID | Received: '%s'", res.Header.Get(requestIDHeader))
+	}
+
+	sgms := sendgridServer.Messages()
+	if len(sgms) != 1 || sgms[0].CustomArgs["poslan_request_id"] != "req-1" {
+		t.Errorf("Expected: 'req-1' SendGrid custom arg | Received: %+v", sgms)
+	}
+
+	req, _ := http.NewRequest("GET", baseURL+"/v1/messages/"+sent.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Message error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	var mr messageResponse
+	json.NewDecoder(res.Body).Decode(&mr)
+	if mr.Status == nil || mr.Status.RequestID != "req-1" {
+		t.Errorf("Expected: 'req-1' message status request ID | Received: %+v", mr.Status)
+	}
+
+	res, _ = sendWithID("req-2")
+	sesms := sesServer.Messages()
+	if len(sesms) != 1 || sesms[0].Tags["poslan_request_id"] != "req-2" {
+		t.Errorf("Expected: 'req-2' SES tag | Received: %+v", sesms)
+	}
+
+	// Invalid IDs are replaced.
+	res, _ = sendWithID("invalid id")
+	if id := res.Header.Get(requestIDHeader); id == "" || id == "invalid id" {
+		t.Errorf("Expected: generated request ID | Received: '%s'", id)
+	}
+
+	// Errors include the request ID.
+	req, _ = http.NewRequest("GET", baseURL+"/v1/unknown", nil)
+	req.Header.Set(requestIDHeader, "req-3")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Request error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	var p problem
+	json.NewDecoder(res.Body).Decode(&p)
+	if p.RequestID != "req-3" || res.Header.Get(requestIDHeader) != "req-3" {
+		t.Errorf("Expected: 'req-3' problem request ID | Received: '%s'", p.RequestID)
 	}
 }
 
 func setup() {
+	sesServer = mailertest.NewSESServer()
+	sendgridServer = mailertest.NewSendGridServer("sendgrid-key")
+	fallback = mailertest.NewProvider("fallback", 3)
+	scheduleDir, _ = ioutil.TempDir("", "poslan-schedule")
+	if f, err := ioutil.TempFile("", "poslan-spans"); err == nil {
+		spansFile = f.Name()
+		f.Close()
+	}
+
 	cfg, err := configForTest()
 	if err != nil {
 		log.Println("[ERROR] Cannot run test: Invalid configuraration.")
@@ -91,37 +1231,151 @@ func setup() {
 
 	errchan := make(chan error)
 
-	go TestingRun(cfg, errchan)
+	go TestingRun(cfg, errchan, fallback)
 
-	select {
-	case err = <-errchan:
-		msg := fmt.Sprintf("test setup cannot be completed completed: %s", err.Error())
-		log.Printf("[ERROR] %s", msg)
-		os.Exit(1)
+	// Wait for the service to be listening.
+	for i := 0; i < 50; i++ {
+		select {
+		case err = <-errchan:
+			msg := fmt.Sprintf("test setup cannot be completed completed: %v", err)
+			log.Printf("[ERROR] %s", msg)
+			os.Exit(1)
+
+		default:
+		}
+
+		if listening(port) && listening(smtpPort) && listening(grpcPort) && listening(adminPort) {
+			log.Println("Setup completed.")
+			return
+		}
 
-	default:
-		log.Println("Setup completed.")
+		time.Sleep(100 * time.Millisecond)
 	}
+
+	log.Println("[ERROR] test setup cannot be completed: service not listening.")
+	os.Exit(1)
 }
 
 func teardown() {
+	sesServer.Close()
+	sendgridServer.Close()
+	os.RemoveAll(scheduleDir)
+	os.Remove(spansFile)
 	log.Println("Teardown completed")
 }
 
-// TODO: Implement a custom leader only used for test.
-// Something like the one already implemented in
-// internal/config/loadFromFile(filePath string)
-// Although it is probably easy to edit those values stright here
-func configForTest() (*config.Config, error) {
-	// Uncomment and edit if you need to add custom values.
-	// os.Setenv("KEY1", "VAL1")
-	// os.Setenv("KEY2", "VAL2")
-	// os.Setenv("KEY2", "VAL2")
+func reset() {
+	sesServer.Reset()
+	sendgridServer.Reset()
+	fallback.Reset()
+}
+
+// send signs in and sends a mail.
+func send(t *testing.T) sendResponse {
+	token := signIn(t)
 
+	emailJSON := `
+	{
+		"to": "sendmailtest@sharklasers.com",
+		"cc": "sendmailtest@sharklasers.com",
+		"bcc": "sendmailtest@sharklasers.com",
+		"subject": "Subject",
+		"body": "Body text.",
+		"metadata": {"campaign": "welcome"}
+	}
+	`
+	req, _ := http.NewRequest("POST", sendURL, bytes.NewBufferString(emailJSON))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("[ERROR] Send error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected: 200 - StatusOK | Received: Status: %d - %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+
+	var sr sendResponse
+	json.NewDecoder(res.Body).Decode(&sr)
+	return sr
+}
+
+func signIn(t *testing.T) string {
+	return signInAs(t, clientID, clientSecret)
+}
+
+// signInAs signs in a client and returns its auth token.
+func signInAs(t *testing.T, clientID, clientSecret string) string {
+	body := fmt.Sprintf(`{"clientID": "%s", "secret": "%s"}`, clientID, clientSecret)
+
+	res, err := http.Post(signinURL, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("[ERROR] Sign in error: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	var sr signInResponse
+	json.NewDecoder(res.Body).Decode(&sr)
+
+	if sr.Token == "" {
+		t.Fatalf("[ERROR] Sign in error: status %d", res.StatusCode)
+	}
+
+	return sr.Token
+}
+
+// userContext returns a context with the data of a signed in user.
+func userContext() context.Context {
+	ud := map[string]string{
+		"clientID": clientID,
+		"username": "Diana Prince",
+		"email":    "diana.p@gmail.com",
+	}
+	return context.WithValue(context.Background(), userDataCtxKey, ud)
+}
+
+func listening(port int) bool {
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, fmt.Sprintf("%d", port)))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func freePort() int {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return port
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// configForTest returns a config whose providers
+// target local stand-ins of their APIs.
+func configForTest() (*config.Config, error) {
 	// App
 	app := config.AppConfig{
 		ServerPort: port,
+		GRPCPort:   grpcPort,
+		AdminPort:  adminPort,
 		LogLevel:   config.LogLevel.Debug,
+		SMTP: config.SMTPConfig{
+			Enabled:           true,
+			Port:              smtpPort,
+			AllowInsecureAuth: true,
+		},
+		AdminClients:   []string{clientID},
+		ScheduleDir:    scheduleDir,
+		IdempotencyTTL: 60,
+		Tracing: config.TracingConfig{
+			Exporter:   config.TracingFile,
+			Endpoint:   spansFile,
+			SampleRate: 1,
+		},
 	}
 
 	provider1 := config.ProviderConfig{
@@ -129,17 +1383,18 @@ func configForTest() (*config.Config, error) {
 		Type:     "amazon-ses",
 		Enabled:  true,
 		Priority: 1,
-		IDKey:    config.GetEnvOrDef("PROVIDER_ID_KEY_1", ""),
-		APIKey:   config.GetEnvOrDef("PROVIDER_API_KEY_1", ""),
+		IDKey:    "ses-id-key",
+		APIKey:   "ses-api-key",
+		Endpoint: sesServer.URL,
 	}
 
 	provider2 := config.ProviderConfig{
 		Name:     "sendgrid",
 		Type:     "sendgrid",
 		Enabled:  true,
-		Priority: 1,
-		IDKey:    config.GetEnvOrDef("PROVIDER_ID_KEY_2", ""),
-		APIKey:   config.GetEnvOrDef("PROVIDER_API_KEY_2", ""),
+		Priority: 2,
+		APIKey:   "sendgrid-key",
+		Endpoint: sendgridServer.URL,
 	}
 
 	mailers := config.MailerConfig{
@@ -154,7 +1409,5 @@ func configForTest() (*config.Config, error) {
 		Mailer: mailers,
 	}
 
-	// fmt.Printf("\n%+v\n", cfg)
-
 	return cfg, nil
 }
@@ -1,8 +1,36 @@
 package mailer
 
+import "time"
+
 const (
 	// This values Eventually can be obtained from config
 	// or the client as appropriate.
 	// Fixed value at the moment for the sake of simplicity (PoC).
 	charset = "UTF-8"
 )
+
+const (
+	// Liveness thresholds.
+	maxHeapMb     = 512
+	maxGoroutines = 10000
+	// Providers are not available after maxProviderFailures
+	// consecutive failures until providerFailureWindow has passed
+	// since the last one.
+	maxProviderFailures   = 5
+	providerFailureWindow = time.Minute
+)
+
+const (
+	// configPollInterval is the time between
+	// config file change checks.
+	configPollInterval = 5 * time.Second
+)
+
+const (
+	// Default span collector endpoints.
+	zipkinHTTPEndpoint = "http://localhost:9411/api/v2/spans"
+	otlpHTTPEndpoint   = "http://localhost:4318/v1/traces"
+	// tracingBatchInterval is the max time spans
+	// are kept before being sent to the collector.
+	tracingBatchInterval = time.Second
+)
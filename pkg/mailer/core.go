@@ -17,24 +17,28 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/adrianpk/poslan/internal/amazon"
 	"github.com/adrianpk/poslan/internal/config"
 	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/file"
+	"github.com/adrianpk/poslan/internal/mailgun"
+	"github.com/adrianpk/poslan/internal/postmark"
 	"github.com/adrianpk/poslan/internal/sendgrid"
+	"github.com/adrianpk/poslan/internal/sys"
+	"github.com/adrianpk/poslan/internal/webhook"
 	"github.com/adrianpk/poslan/pkg/auth"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/heptiolabs/healthcheck"
 	health "github.com/heptiolabs/healthcheck"
-	zipkin "github.com/openzipkin/zipkin-go"
-	reporter "github.com/openzipkin/zipkin-go/reporter/http"
 )
 
 const (
-	serviceName        = "poslanAuthentication"
-	serviceHostPort    = "localhost:8000"
-	zipkinHTTPEndpoint = "http://localhost:9411/api/v2/spans"
+	serviceName = "poslan"
 )
 
 // checkSigTerm listens to sigterm events.
@@ -48,104 +52,223 @@ func checkSigTerm(cancel context.CancelFunc) {
 
 func makeService(ctx context.Context, cfg *c.Config, log log.Logger) *service {
 	return &service{
-		name:   "Poslan",
-		ctx:    ctx,
-		cfg:    cfg,
-		logger: log,
-		auth:   auth.Server{Logger: log},
+		name:     "Poslan",
+		ctx:      ctx,
+		cfg:      cfg,
+		logger:   log,
+		auth:     auth.Server{Logger: log},
+		statuses: newStatusStore(maxStatuses),
+		meters:   delivery,
+		tracer:   noopTracer(),
+		sends:    &sync.WaitGroup{},
 	}
 }
 
 // Init a service instance.
 func (svc *service) Init() (s Service, err error) {
 	svc.Disable()
-	// FIX: Readiness & liveness checks temporarily disabled.
 	svc.health = health.NewHandler()
 	svc.health.AddReadinessCheck("ready", svc.ReadinessCheck())
-	svc.health.AddLivenessCheck("heap-threshold", svc.HeapLivenessCheck(10))
-	svc.health.AddLivenessCheck("goroutine-threshold", healthcheck.GoroutineCountCheck(25))
+	svc.health.AddReadinessCheck("providers", svc.ProvidersReadinessCheck())
+	svc.health.AddLivenessCheck("heap-threshold", svc.HeapLivenessCheck(maxHeapMb))
+	svc.health.AddLivenessCheck("goroutine-threshold", healthcheck.GoroutineCountCheck(maxGoroutines))
 
-	ok1 := initAmazon(svc)
-	ok2 := initSendGrid(svc)
-
-	if !(<-ok1 && <-ok2) {
+	ps, ok := initProviders(svc, svc.cfg)
+	if !ok {
 		return nil, fmt.Errorf("Cannot initialize '%s' service", svc.name)
 	}
+	svc.providers = svc.manage(append(ps, svc.external...))
+
+	svc.tracer, svc.spans, err = makeTracer(svc.cfg.App.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot initialize '%s' service tracer: %s", svc.name, err.Error())
+	}
+
+	err = initScheduler(svc)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot initialize '%s' service scheduler: %s", svc.name, err.Error())
+	}
 
 	s = addLogging(svc, svc.logger)
-	// s = addTracing(svc) // TODO: Implement.
-	s = addInstrumentation(svc, svc.logger)
-	s = addAuthentication(svc, svc.logger, svc.auth)
+	s = addTracing(s, svc.logger)
+	s = addInstrumentation(s, svc.logger)
+	s = addAuthentication(s, svc.logger, svc.auth)
 
 	return s, nil
 }
 
-func initAmazon(svc *service) chan bool {
-	ok := make(chan bool)
-	go func() {
-		defer close(ok)
-		p, err := amazon.Init(svc.ctx, svc.cfg, svc.logger)
-		if err != nil {
-			svc.logger.Log(
-				"level", config.LogLevel.Error,
+// initProviders initializes every enabled provider in config.
+// It returns false if any of them cannot be initialized.
+func initProviders(svc *service, cfg *c.Config) ([]sys.Provider, bool) {
+	pchs := make([]chan sys.Provider, 0)
+
+	for _, pc := range cfg.Mailer.Providers {
+		if !pc.Enabled {
+			continue
+		}
+
+		switch pc.Type {
+		case c.ProviderType.AmazonSES.String():
+			pchs = append(pchs, initAmazon(svc, cfg, pc.Name))
+		case c.ProviderType.SendGrid.String():
+			pchs = append(pchs, initSendGrid(svc, cfg, pc.Name))
+		case c.ProviderType.Mailgun.String():
+			pchs = append(pchs, initMailgun(svc, cfg, pc.Name))
+		case c.ProviderType.Postmark.String():
+			pchs = append(pchs, initPostmark(svc, cfg, pc.Name))
+		case c.ProviderType.Webhook.String():
+			pchs = append(pchs, initWebhook(svc, cfg, pc.Name))
+		case c.ProviderType.File.String():
+			pchs = append(pchs, initFile(svc, cfg, pc.Name))
+		default:
+			level.Warn(svc.logger).Log(
 				"package", "main",
-				"method", "initAmazon",
-				"message", "Cannot initialize Amazon SES provider.",
-				"error", err.Error(),
+				"method", "initProviders",
+				"message", "Unknown provider type.",
+				"provider", pc.Name,
+				"type", pc.Type,
 			)
-			ok <- false
-			return
 		}
-		svc.mux.Lock()
-		svc.providers = append(svc.providers, p)
-		svc.mux.Unlock()
-		ok <- true
-	}()
-	return ok
+	}
+
+	ps := make([]sys.Provider, 0, len(pchs))
+	res := true
+	for _, pch := range pchs {
+		p := <-pch
+		if p == nil {
+			res = false
+			continue
+		}
+		ps = append(ps, p)
+	}
+
+	return ps, res
+}
+
+func initAmazon(svc *service, cfg *c.Config, name string) chan sys.Provider {
+	return initProvider(svc, name, "Amazon SES", func() (sys.Provider, error) {
+		return amazon.Init(svc.ctx, cfg, svc.logger, name)
+	})
 }
 
-func initSendGrid(svc *service) chan bool {
-	ok := make(chan bool)
+func initSendGrid(svc *service, cfg *c.Config, name string) chan sys.Provider {
+	return initProvider(svc, name, "SendGrid", func() (sys.Provider, error) {
+		return sendgrid.Init(svc.ctx, cfg, svc.logger, name)
+	})
+}
+
+func initMailgun(svc *service, cfg *c.Config, name string) chan sys.Provider {
+	return initProvider(svc, name, "Mailgun", func() (sys.Provider, error) {
+		return mailgun.Init(svc.ctx, cfg, svc.logger, name)
+	})
+}
+
+func initPostmark(svc *service, cfg *c.Config, name string) chan sys.Provider {
+	return initProvider(svc, name, "Postmark", func() (sys.Provider, error) {
+		return postmark.Init(svc.ctx, cfg, svc.logger, name)
+	})
+}
+
+func initWebhook(svc *service, cfg *c.Config, name string) chan sys.Provider {
+	return initProvider(svc, name, "webhook", func() (sys.Provider, error) {
+		return webhook.Init(svc.ctx, cfg, svc.logger, name)
+	})
+}
+
+func initFile(svc *service, cfg *c.Config, name string) chan sys.Provider {
+	return initProvider(svc, name, "file", func() (sys.Provider, error) {
+		return file.Init(svc.ctx, cfg, svc.logger, name)
+	})
+}
+
+// initProvider asynchronously initializes a provider.
+// Returned channel receives the provider
+// or nil if it cannot be initialized.
+func initProvider(svc *service, name, kind string, init func() (sys.Provider, error)) chan sys.Provider {
+	pch := make(chan sys.Provider)
 	go func() {
-		defer close(ok)
-		p, err := sendgrid.Init(svc.ctx, svc.cfg, svc.logger)
+		defer close(pch)
+		p, err := init()
 		if err != nil {
-			svc.logger.Log(
-				"level", config.LogLevel.Error,
+			level.Error(svc.logger).Log(
 				"package", "main",
-				"method", "initSendGreid",
-				"message", "Cannot initialize SendGrid provider.",
+				"method", "initProvider",
+				"message", fmt.Sprintf("Cannot initialize %s provider.", kind),
+				"provider", name,
 				"error", err.Error(),
 			)
-			ok <- false
+			pch <- nil
 			return
 		}
-		svc.mux.Lock()
-		svc.providers = append(svc.providers, p)
-		svc.mux.Unlock()
-		ok <- true
+		pch <- p
 	}()
-	return ok
+	return pch
+}
+
+// initScheduler loads stored scheduled messages
+// and starts releasing them at their send time.
+// The schedule dir is locked while the service runs
+// so that its messages are not changed behind it.
+func initScheduler(svc *service) error {
+	dir := svc.cfg.App.ScheduleDir
+	if dir != "" {
+		lock, err := lockDir(dir)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			<-svc.ctx.Done()
+			lock.Close()
+		}()
+	}
+
+	sch, err := newScheduler(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, sm := range sch.Messages() {
+		svc.statuses.Put(scheduledStatus(sm))
+	}
+
+	svc.scheduler = sch
+	registerSchedulerMeters(sch)
+	go sch.Run(svc.ctx, svc.logger, svc.release)
+	return nil
 }
 
 // Middleware
 func addLogging(svc Service, logger log.Logger) Service {
 	if loggingOn {
 		return loggingMiddleware{
+			ctx:    svc.Context(),
 			logger: logger,
 			next:   svc}
 	}
 	return svc
 }
 
+func addTracing(svc Service, logger log.Logger) Service {
+	if tracingOn {
+		return tracingMiddleware{
+			ctx:    svc.Context(),
+			logger: logger,
+			tracer: svc.Tracer(),
+			next:   svc,
+		}
+	}
+	return svc
+}
+
 func addInstrumentation(svc Service, logger log.Logger) Service {
 	if instrumentationOn {
 		m := instrumentationMeters()
 		return instrumentationMiddleware{
+			ctx:            svc.Context(),
 			logger:         logger,
 			requestCount:   m.ReqCount,
 			requestLatency: m.ReqLatency,
-			countResult:    m.CountResult,
 			next:           svc,
 		}
 	}
@@ -154,51 +277,84 @@ func addInstrumentation(svc Service, logger log.Logger) Service {
 
 func addAuthentication(svc Service, logger log.Logger, auth auth.SecServer) Service {
 	return authenticationMiddleware{
+		ctx:    svc.Context(),
 		logger: svc.Logger(),
 		auth:   auth,
 		next:   svc,
 	}
 }
 
-func makeLogger() log.Logger {
-	w := log.NewSyncWriter(os.Stdout)
-	logger := log.NewLogfmtLogger(w)
-	logger.Log("level", c.LogLevel.Info, "message", "Config Logger started.")
-	return logger
-}
+// makeLogger returns a leveled and redacting logger writing to stdout.
+// Invalid values fall back to logfmt format, hash privacy and info level.
+func makeLogger(format, lvl, privacy string) *leveledLogger {
+	var errs []error
+
+	base, err := newFormatLogger(os.Stdout, format)
+	if err != nil {
+		errs = append(errs, err)
+		base, _ = newFormatLogger(os.Stdout, string(c.LogFormat.Logfmt))
+	}
 
-func makeTracer() (*zipkin.Tracer, error) {
-	r := reporter.NewReporter(zipkinHTTPEndpoint)
-	ze, err := zipkin.NewEndpoint(serviceName, serviceHostPort)
+	redacting, err := newRedactingLogger(base, privacy)
 	if err != nil {
-		return nil, err
+		errs = append(errs, err)
+		redacting, _ = newRedactingLogger(base, string(c.PrivacyLevel.Hash))
 	}
-	return zipkin.NewTracer(r, zipkin.WithLocalEndpoint(ze))
+
+	logger, err := newLeveledLogger(redacting, lvl)
+	if err != nil {
+		errs = append(errs, err)
+		logger, _ = newLeveledLogger(redacting, string(c.LogLevel.Info))
+	}
+
+	for _, err := range errs {
+		level.Warn(logger).Log("message", err.Error())
+	}
+	return logger
 }
 
 // Start the service.
+// Service is ready once its providers are started.
 func (svc *service) Start() {
 	go svc.checkCancel()
 	svc.StartProviders()
+	svc.Enable()
 }
 
 func (svc *service) checkCancel() {
 	<-svc.ctx.Done()
+	svc.Disable()
 	svc.StopProviders()
+	if svc.spans != nil {
+		svc.spans.Close()
+	}
 }
 
 // StarMailer is used in service startup
 // to start each configured provider.
 func (svc *service) StartProviders() {
-	for _, m := range svc.providers {
-		m.Start()
+	startProviders(svc.logger, svc.Providers())
+}
+
+// startProviders starts providers logging
+// the ones that cannot be started.
+func startProviders(logger log.Logger, ps []sys.Provider) {
+	for _, m := range ps {
+		if err := m.Start(); err != nil {
+			level.Error(logger).Log(
+				"package", "mailer",
+				"method", "StartProviders",
+				"provider", m.Name(),
+				"error", err.Error(),
+			)
+		}
 	}
 }
 
 // StarMailer is used in service stop
 // to stop each configured provider.
 func (svc *service) StopProviders() {
-	for _, m := range svc.providers {
+	for _, m := range svc.Providers() {
 		m.Stop()
 	}
 }
@@ -207,19 +363,33 @@ func (svc *service) StopProviders() {
 func (svc *service) ReadinessCheck() healthcheck.Check {
 	return func() error {
 
-		if !svc.ready {
+		if !svc.IsReady() {
 			msg := fmt.Sprintf("%s service is not ready!", svc.name)
-			svc.logger.Log("level", c.LogLevel.Warn, "message", msg)
+			level.Warn(svc.logger).Log("message", msg)
 			return errors.New(msg)
 		}
 
 		msg := fmt.Sprintf("%s service is ready", svc.name)
-		svc.logger.Log("level", c.LogLevel.Info, "message", msg)
+		level.Info(svc.logger).Log("message", msg)
 
 		return nil
 	}
 }
 
+// ProvidersReadinessCheck fails if none of the providers
+// that deliver messages by default is available.
+func (svc *service) ProvidersReadinessCheck() healthcheck.Check {
+	return func() error {
+		cfg, ps := svc.current()
+		for _, p := range routeProviders(cfg, ps, cfg.App.DryRun) {
+			if svc.isAvailable(p) {
+				return nil
+			}
+		}
+		return errors.New("no provider is available")
+	}
+}
+
 // HeapLivenessCheck is a heap allocation liveness test for the service.
 func (svc *service) HeapLivenessCheck(maxMb uint64) healthcheck.Check {
 	return func() error {
@@ -251,9 +421,21 @@ func checkError(err error, msg ...string) {
 	}
 }
 
+// checkConfigError logs every config problem, if any, and exits.
+func checkConfigError(logger log.Logger, err error) {
+	if ve, ok := err.(c.ValidationError); ok {
+		for _, p := range ve.Problems {
+			level.Error(logger).Log("message", "Invalid config.", "problem", p)
+		}
+	}
+	checkError(err)
+}
+
 // TestingRun lets start the service for testing purposes.
 // Basically a copy of Run but where you can pass a custom config.
-func TestingRun(cfg *config.Config, errchan chan error) {
+// Providers, if any, are used along with the ones in config
+// (i.e.: mailertest in-memory providers).
+func TestingRun(cfg *config.Config, errchan chan error, providers ...sys.Provider) {
 	setUpEnv(cfg)
 
 	// Context
@@ -261,20 +443,47 @@ func TestingRun(cfg *config.Config, errchan chan error) {
 	go checkSigTerm(cancel)
 
 	// Logger
-	logger := makeLogger()
+	logger := makeLogger(string(c.LogFormat.Logfmt), string(c.LogLevel.Info), string(c.PrivacyLevel.Hash))
 
 	// Config
-	cfg, err := c.Load(logger)
-	checkError(err)
+	cfg, err := c.Load(logger, "")
+	checkConfigError(logger, err)
+
+	logger = makeLogger(string(cfg.App.LogFormat), string(cfg.App.LogLevel), string(cfg.App.LogPrivacy))
+	level.Info(logger).Log("message", "Config Logger started.")
 
 	// Service
-	svc, err := makeService(ctx, cfg, logger).Init()
+	s := makeService(ctx, cfg, logger)
+	s.external = providers
+	svc, err := s.Init()
 	checkError(err)
 
-	// Handlers
-	http.Handle("/signin", SignInHandler(svc))
-	http.Handle("/signout", SignOutHandler(svc))
-	http.Handle("/send", SendHandler(svc))
+	// Admin
+	if cfg.App.AdminPort > 0 {
+		go func() {
+			if err := runAdmin(ctx, cfg, s, logger); err != nil {
+				errchan <- err
+			}
+		}()
+	}
+
+	// gRPC
+	if cfg.App.GRPCPort > 0 {
+		go func() {
+			if err := runGRPC(ctx, cfg, svc, logger); err != nil {
+				errchan <- err
+			}
+		}()
+	}
+
+	// SMTP
+	if cfg.App.SMTP.Enabled {
+		go func() {
+			if err := runSMTP(ctx, cfg, svc, logger); err != nil {
+				errchan <- err
+			}
+		}()
+	}
 
 	// Listen
 	listener, err := net.Listen("tcp", cfg.App.ServerPortFmt())
@@ -283,8 +492,11 @@ func TestingRun(cfg *config.Config, errchan chan error) {
 		return
 	}
 
+	s.Start()
+	go watchConfig(ctx, s, "")
+
 	// Serve
-	if err := http.Serve(listener, nil); err != nil {
+	if err := http.Serve(listener, MakeHTTPHandler(svc)); err != nil {
 		errchan <- err
 		return
 	}
@@ -297,21 +509,52 @@ func TestingRun(cfg *config.Config, errchan chan error) {
 // TODO: Supply default values if appropiate.
 func setUpEnv(cfg *config.Config) {
 	os.Setenv("POSLAN_SERVER_PORT", fmt.Sprintf("%d", cfg.App.ServerPort))
+	os.Setenv("POSLAN_GRPC_PORT", fmt.Sprintf("%d", cfg.App.GRPCPort))
+	os.Setenv("POSLAN_ADMIN_PORT", fmt.Sprintf("%d", cfg.App.AdminPort))
 	os.Setenv("POSLAN_LOG_LEVEL", string(cfg.App.LogLevel))
-	// Provider 1
-	os.Setenv("PROVIDER_NAME_1", cfg.Mailer.Providers[0].Name)
-	os.Setenv("PROVIDER_TYPE_1", cfg.Mailer.Providers[0].Type)
-	os.Setenv("PROVIDER_PRIORITY_1", fmt.Sprintf("%d", cfg.Mailer.Providers[0].Priority))
-	os.Setenv("PROVIDER_SENDER_NAME_1", cfg.Mailer.Providers[0].Sender.Name)
-	os.Setenv("PROVIDER_SENDER_EMAIL_1", cfg.Mailer.Providers[0].Sender.Email)
-	os.Setenv("PROVIDER_ID_KEY_1", cfg.Mailer.Providers[0].IDKey)
-	os.Setenv("PROVIDER_API_KEY_1", cfg.Mailer.Providers[0].APIKey)
-	// Provider 1
-	os.Setenv("PROVIDER_NAME_2", cfg.Mailer.Providers[1].Name)
-	os.Setenv("PROVIDER_TYPE_2", cfg.Mailer.Providers[1].Type)
-	os.Setenv("PROVIDER_PRIORITY_2", fmt.Sprintf("%d", cfg.Mailer.Providers[1].Priority))
-	os.Setenv("PROVIDER_SENDER_NAME_2", cfg.Mailer.Providers[1].Sender.Name)
-	os.Setenv("PROVIDER_SENDER_EMAIL_2", cfg.Mailer.Providers[1].Sender.Email)
-	os.Setenv("PROVIDER_ID_KEY_2", cfg.Mailer.Providers[1].IDKey)
-	os.Setenv("PROVIDER_API_KEY_2", cfg.Mailer.Providers[1].APIKey)
+	os.Setenv("POSLAN_LOG_FORMAT", string(cfg.App.LogFormat))
+	os.Setenv("POSLAN_LOG_PRIVACY", string(cfg.App.LogPrivacy))
+	os.Setenv("POSLAN_DRY_RUN", fmt.Sprintf("%t", cfg.App.DryRun))
+	os.Setenv("POSLAN_DRY_RUN_CLIENTS", strings.Join(cfg.App.DryRunClients, ","))
+	os.Setenv("POSLAN_ADMIN_CLIENTS", strings.Join(cfg.App.AdminClients, ","))
+	// SMTP
+	os.Setenv("POSLAN_SMTP_ENABLED", fmt.Sprintf("%t", cfg.App.SMTP.Enabled))
+	os.Setenv("POSLAN_SMTP_PORT", fmt.Sprintf("%d", cfg.App.SMTP.Port))
+	os.Setenv("POSLAN_SMTP_HOSTNAME", cfg.App.SMTP.Hostname)
+	os.Setenv("POSLAN_SMTP_TLS_CERT", cfg.App.SMTP.TLSCert)
+	os.Setenv("POSLAN_SMTP_TLS_KEY", cfg.App.SMTP.TLSKey)
+	os.Setenv("POSLAN_SMTP_MAX_MESSAGE_BYTES", fmt.Sprintf("%d", cfg.App.SMTP.MaxMessageBytes))
+	os.Setenv("POSLAN_SMTP_ALLOW_INSECURE_AUTH", fmt.Sprintf("%t", cfg.App.SMTP.AllowInsecureAuth))
+	// Schedule
+	os.Setenv("POSLAN_SCHEDULE_DIR", cfg.App.ScheduleDir)
+	// Idempotency
+	os.Setenv("POSLAN_IDEMPOTENCY_TTL", fmt.Sprintf("%d", cfg.App.IdempotencyTTL))
+	// Tracing
+	os.Setenv("POSLAN_TRACING_EXPORTER", cfg.App.Tracing.Exporter)
+	os.Setenv("POSLAN_TRACING_ENDPOINT", cfg.App.Tracing.Endpoint)
+	os.Setenv("POSLAN_TRACING_SAMPLE_RATE", fmt.Sprintf("%g", cfg.App.Tracing.SampleRate))
+	// Providers
+	for i, p := range cfg.Mailer.Providers {
+		n := i + 1
+		os.Setenv(fmt.Sprintf("PROVIDER_NAME_%d", n), p.Name)
+		os.Setenv(fmt.Sprintf("PROVIDER_TYPE_%d", n), p.Type)
+		os.Setenv(fmt.Sprintf("PROVIDER_ENABLED_%d", n), fmt.Sprintf("%t", p.Enabled))
+		os.Setenv(fmt.Sprintf("PROVIDER_PRIORITY_%d", n), fmt.Sprintf("%d", p.Priority))
+		os.Setenv(fmt.Sprintf("PROVIDER_SENDER_NAME_%d", n), p.Sender.Name)
+		os.Setenv(fmt.Sprintf("PROVIDER_SENDER_EMAIL_%d", n), p.Sender.Email)
+		os.Setenv(fmt.Sprintf("PROVIDER_ID_KEY_%d", n), p.IDKey)
+		os.Setenv(fmt.Sprintf("PROVIDER_API_KEY_%d", n), p.APIKey)
+		os.Setenv(fmt.Sprintf("PROVIDER_REGION_%d", n), p.Region)
+		os.Setenv(fmt.Sprintf("PROVIDER_ENDPOINT_%d", n), p.Endpoint)
+		os.Setenv(fmt.Sprintf("PROVIDER_ROLE_ARN_%d", n), p.RoleARN)
+		os.Setenv(fmt.Sprintf("PROVIDER_CONFIG_SET_%d", n), p.ConfigurationSet)
+		os.Setenv(fmt.Sprintf("PROVIDER_SANDBOX_%d", n), fmt.Sprintf("%t", p.Sandbox))
+		os.Setenv(fmt.Sprintf("PROVIDER_UNSUBSCRIBE_GROUP_%d", n), fmt.Sprintf("%d", p.UnsubscribeGroup))
+		os.Setenv(fmt.Sprintf("PROVIDER_DOMAIN_%d", n), p.Domain)
+		os.Setenv(fmt.Sprintf("PROVIDER_STREAM_%d", n), p.Stream)
+		os.Setenv(fmt.Sprintf("PROVIDER_FORMAT_%d", n), p.Format)
+		os.Setenv(fmt.Sprintf("PROVIDER_TIMEOUT_%d", n), fmt.Sprintf("%d", p.Timeout))
+		os.Setenv(fmt.Sprintf("PROVIDER_PATH_%d", n), p.Path)
+		os.Setenv(fmt.Sprintf("PROVIDER_TESTONLY_%d", n), fmt.Sprintf("%t", p.TestOnly))
+	}
 }
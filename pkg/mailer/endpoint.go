@@ -9,25 +9,26 @@ package mailer
 
 import (
 	"context"
-	"fmt"
+	"time"
 
-	c "github.com/adrianpk/poslan/internal/config"
+	js "github.com/adrianpk/poslan/internal/jsonschema"
+	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log/level"
 )
 
 func makeSignInEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(signInRequest)
 
-		reqstr := fmt.Sprintf("Req: %+v", req)
-		svc.Logger().Log("level", c.LogLevel.Info, "req", reqstr)
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log("request", "SignIn", "clientID", req.ClientID, "secret", req.Secret)
 
-		user, err := svc.SignIn(ctx, req.ClientID, req.Secret)
+		token, err := svc.SignIn(ctx, req.ClientID, req.Secret)
 		if err != nil {
-			return signInResponse{user, err.Error()}, nil
+			return nil, err
 		}
 
-		return signInResponse{user, ""}, nil
+		return signInResponse{Token: token}, nil
 	}
 }
 
@@ -35,15 +36,14 @@ func makeSignOutEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(signOutRequest)
 
-		reqstr := fmt.Sprintf("Req: %+v", req)
-		svc.Logger().Log("level", c.LogLevel.Info, "req", reqstr)
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log("request", "SignOut", "id", req.ID)
 
 		err := svc.SignOut(ctx, req.ID)
 		if err != nil {
-			return signOutResponse{err.Error()}, nil
+			return nil, err
 		}
 
-		return signOutResponse{""}, nil
+		return signOutResponse{}, nil
 	}
 }
 
@@ -51,14 +51,200 @@ func makeSendEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(sendRequest)
 
-		reqstr := fmt.Sprintf("Req: %+v", req)
-		svc.Logger().Log("level", c.LogLevel.Info, "req", reqstr)
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log(
+			"request", "Send",
+			"to", req.To,
+			"cc", req.Cc,
+			"bcc", req.Bcc,
+			"replyTo", req.ReplyTo,
+			"subject", req.Subject,
+			"body", req.Body,
+		)
 
-		err := svc.Send(ctx, req.To, req.Cc, req.Bcc, req.Subject, req.Body)
+		sendAt, err := req.sendTime(time.Now())
 		if err != nil {
-			return signOutResponse{err.Error()}, nil
+			return nil, err
 		}
 
-		return sendResponse{Err: ""}, nil
+		em := &model.Email{
+			SendAt:   sendAt,
+			To:       req.To,
+			CC:       req.Cc,
+			BCC:      req.Bcc,
+			ReplyTo:  req.ReplyTo,
+			Subject:  req.Subject,
+			Body:     req.Body,
+			Headers:  req.Headers,
+			Metadata: req.Metadata,
+			Tags:     req.Tags,
+		}
+
+		for _, p := range req.Personalizations {
+			em.Personalizations = append(em.Personalizations, model.Personalization{
+				To:            p.To,
+				Substitutions: p.Substitutions,
+			})
+		}
+
+		now := time.Now()
+		sent, err := svc.Send(ctx, em)
+		if err != nil {
+			return nil, err
+		}
+
+		return newSendResponse(sent, now), nil
+	}
+}
+
+func makeSendBatchEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(sendBatchRequest)
+
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log("request", "SendBatch", "recipients", len(req.Recipients), "subject", req.Subject, "body", req.Body)
+
+		if req.Template != "" {
+			return nil, wrapError(errNotImplemented, errTemplatesNotSupported)
+		}
+
+		em := &model.Email{
+			CC:       req.Cc,
+			BCC:      req.Bcc,
+			ReplyTo:  req.ReplyTo,
+			Subject:  req.Subject,
+			Body:     req.Body,
+			Headers:  req.Headers,
+			Metadata: req.Metadata,
+			Tags:     req.Tags,
+		}
+
+		// Only valid recipients are sent.
+		var errs []js.FieldError
+		var idx []int
+		for i, r := range req.Recipients {
+			if len(r.Errors) > 0 {
+				errs = append(errs, r.Errors...)
+				continue
+			}
+			idx = append(idx, i)
+			em.Personalizations = append(em.Personalizations, model.Personalization{
+				To:            r.To,
+				Substitutions: r.Substitutions,
+			})
+		}
+
+		if len(idx) == 0 {
+			return nil, validationError{errs: errs}
+		}
+
+		// Partially delivered batches are not an error,
+		// messages not delivered are reported as failed.
+		sent, err := svc.SendBatch(ctx, em)
+		be, partial := err.(*batchError)
+		if err != nil && !partial {
+			return nil, err
+		}
+
+		res := sendBatchResponse{
+			ID:    sent.ID,
+			Items: make([]batchItem, len(req.Recipients)),
+		}
+
+		for i, r := range req.Recipients {
+			res.Items[i] = batchItem{Index: i, To: r.To, Status: batchRejected, Errors: r.Errors}
+		}
+
+		for j, i := range idx {
+			id := sent.Personalizations[j].ID
+			res.Items[i].ID = id.String()
+
+			if partial {
+				if msg, ok := be.failed[id]; ok {
+					res.Items[i].Status = batchFailed
+					res.Items[i].Error = msg
+					res.Failed++
+					continue
+				}
+			}
+
+			res.Items[i].Status = batchAccepted
+			res.Accepted++
+		}
+
+		res.Rejected = len(req.Recipients) - len(idx)
+
+		return res, nil
+	}
+}
+
+func makeMessageEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(messageRequest)
+
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log("request", "Message", "id", req.ID)
+
+		st, err := svc.Message(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return newMessageResponse(st), nil
+	}
+}
+
+func makeCancelEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(messageRequest)
+
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log("request", "Cancel", "id", req.ID)
+
+		st, err := svc.Cancel(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return newMessageResponse(st), nil
+	}
+}
+
+func makeRescheduleEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(rescheduleRequest)
+
+		level.Debug(ctxLogger(ctx, svc.Logger())).Log("request", "Reschedule", "id", req.ID)
+
+		sendAt, err := req.sendTime(time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		if sendAt.IsZero() {
+			return nil, validationError{errs: []js.FieldError{{Message: "either 'sendAt' or 'delay' is required"}}}
+		}
+
+		st, err := svc.Reschedule(ctx, req.ID, sendAt)
+		if err != nil {
+			return nil, err
+		}
+
+		return newMessageResponse(st), nil
+	}
+}
+
+// sendTime returns the send time set by the schedule,
+// zero if it is not set.
+func (s schedule) sendTime(now time.Time) (time.Time, error) {
+	switch {
+	case s.SendAt != nil && s.Delay != "":
+		return time.Time{}, validationError{errs: []js.FieldError{{Field: "delay", Message: "cannot be used along with 'sendAt'"}}}
+	case s.SendAt != nil:
+		return *s.SendAt, nil
+	case s.Delay != "":
+		d, err := time.ParseDuration(s.Delay)
+		if err != nil {
+			return time.Time{}, validationError{errs: []js.FieldError{{Field: "delay", Message: err.Error()}}}
+		}
+		return now.Add(d), nil
+	default:
+		return time.Time{}, nil
 	}
 }
@@ -0,0 +1,182 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	js "github.com/adrianpk/poslan/internal/jsonschema"
+	"github.com/google/uuid"
+)
+
+// Service errors.
+// Transports map them to their own status codes.
+var (
+	errInvalidRequest     = errors.New("invalid request")
+	errInvalidCredentials = errors.New("invalid credentials")
+	errInvalidToken       = errors.New("invalid token")
+	errForbidden          = errors.New("forbidden")
+	errMessageNotFound    = errors.New("message not found")
+	errConflict           = errors.New("conflict")
+	errInvalidEmail       = errors.New("invalid email")
+	errValidation         = errors.New("validation failed")
+	errRateLimited        = errors.New("rate limited")
+	errDeliveryFailed     = errors.New("delivery failed")
+	errNotImplemented     = errors.New("not implemented")
+	errNotFound           = errors.New("not found")
+	errMethodNotAllowed   = errors.New("method not allowed")
+)
+
+var (
+	errTemplatesNotSupported = errors.New("templates are not supported")
+	errRequestInProgress     = errors.New("a request with the same idempotency key is in progress")
+	errKeyReused             = errors.New("idempotency key was used with a different request")
+)
+
+// serviceError is a service error kind with its cause.
+type serviceError struct {
+	kind  error
+	cause error
+}
+
+func (e serviceError) Error() string {
+	return e.kind.Error() + ": " + e.cause.Error()
+}
+
+// wrapError returns an error of a kind
+// keeping the cause as detail.
+func wrapError(kind, cause error) error {
+	if cause == nil {
+		return kind
+	}
+	return serviceError{kind: kind, cause: cause}
+}
+
+// validationError is a request validation error.
+type validationError struct {
+	errs []js.FieldError
+}
+
+func (e validationError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, fe := range e.errs {
+		msgs = append(msgs, fe.Error())
+	}
+	return errValidation.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// batchError is returned along with the batch
+// when only some of its messages were delivered.
+type batchError struct {
+	// failed are the delivery errors of the
+	// messages not delivered by their ID.
+	failed map[uuid.UUID]string
+	cause  error
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("%d batch messages not delivered: %s", len(e.failed), e.cause.Error())
+}
+
+// errorKind returns the kind of a service error.
+func errorKind(err error) error {
+	switch e := err.(type) {
+	case serviceError:
+		return e.kind
+	case validationError:
+		return errValidation
+	default:
+		return err
+	}
+}
+
+// problem is an RFC 7807 problem details error body.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// RequestID identifies the failed request.
+	RequestID string `json:"requestID,omitempty"`
+	// Errors are field validation errors.
+	Errors []js.FieldError `json:"errors,omitempty"`
+}
+
+// httpStatus returns the HTTP status code for an error.
+func httpStatus(err error) int {
+	switch errorKind(err) {
+	case errInvalidRequest:
+		return http.StatusBadRequest
+	case errInvalidCredentials, errInvalidToken:
+		return http.StatusUnauthorized
+	case errForbidden:
+		return http.StatusForbidden
+	case errMessageNotFound, errNotFound:
+		return http.StatusNotFound
+	case errMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case errConflict:
+		return http.StatusConflict
+	case errInvalidEmail, errValidation, errKeyReused:
+		return http.StatusUnprocessableEntity
+	case errRateLimited:
+		return http.StatusTooManyRequests
+	case errDeliveryFailed:
+		return http.StatusBadGateway
+	case errNotImplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// encodeError writes errors as problem+json.
+// Details of unexpected errors are not exposed.
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	status := httpStatus(err)
+
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		RequestID: requestIDFromContext(ctx),
+	}
+
+	if status != http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+
+	if ve, ok := err.(validationError); ok {
+		p.Detail = errValidation.Error()
+		p.Errors = ve.errs
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// notFoundHandler replies to requests for unknown routes.
+func notFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodeError(r.Context(), errNotFound, w)
+	})
+}
+
+// methodNotAllowedHandler replies to requests
+// with a method not supported by the route.
+func methodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodeError(r.Context(), errMethodNotAllowed, w)
+	})
+}
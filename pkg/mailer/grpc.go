@@ -0,0 +1,281 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/pb"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	kitzipkin "github.com/go-kit/kit/tracing/zipkin"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer exposes service endpoints over gRPC.
+type grpcServer struct {
+	signIn  grpctransport.Handler
+	send    grpctransport.Handler
+	message grpctransport.Handler
+}
+
+// NewGRPCServer returns a gRPC server for the service.
+func NewGRPCServer(svc Service) pb.PoslanServer {
+	opts := grpctransport.ServerBefore(metadataToContext)
+	// Request IDs are taken from 'x-request-id' metadata
+	// or generated, and echoed in response header.
+	reqID := []grpctransport.ServerOption{
+		grpctransport.ServerBefore(requestIDFromMetadata),
+		grpctransport.ServerAfter(requestIDToMetadata),
+	}
+	// Spans are named after the gRPC method,
+	// their parent is taken from B3 metadata.
+	trace := kitzipkin.GRPCServerTrace(svc.Tracer())
+	return &grpcServer{
+		signIn: grpctransport.NewServer(
+			makeSignInEndpoint(svc),
+			decodeGRPCSignInRequest,
+			encodeGRPCSignInResponse,
+			append(reqID, trace)...,
+		),
+		send: grpctransport.NewServer(
+			makeSendEndpoint(svc),
+			decodeGRPCSendRequest,
+			encodeGRPCSendResponse,
+			append(reqID, opts, trace)...,
+		),
+		message: grpctransport.NewServer(
+			makeMessageEndpoint(svc),
+			decodeGRPCMessageRequest,
+			encodeGRPCMessageResponse,
+			append(reqID, opts, trace)...,
+		),
+	}
+}
+
+// SignIn returns an auth token for client credentials.
+func (s *grpcServer) SignIn(ctx context.Context, req *pb.SignInRequest) (*pb.SignInResponse, error) {
+	_, res, err := s.signIn.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return res.(*pb.SignInResponse), nil
+}
+
+// Send delivers a message.
+func (s *grpcServer) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendResponse, error) {
+	_, res, err := s.send.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return res.(*pb.SendResponse), nil
+}
+
+// GetMessage returns the delivery status of a message.
+func (s *grpcServer) GetMessage(ctx context.Context, req *pb.GetMessageRequest) (*pb.MessageStatus, error) {
+	_, res, err := s.message.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return res.(*pb.MessageStatus), nil
+}
+
+// ListTemplates returns available message templates.
+// TODO: Implement after adding templates support.
+func (s *grpcServer) ListTemplates(ctx context.Context, req *pb.ListTemplatesRequest) (*pb.ListTemplatesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, errTemplatesNotSupported.Error())
+}
+
+// runGRPC serves gRPC requests until context is done.
+func runGRPC(ctx context.Context, cfg *c.Config, svc Service, logger log.Logger) error {
+	l, err := net.Listen("tcp", cfg.App.GRPCPortFmt())
+	if err != nil {
+		return err
+	}
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpctransport.Interceptor))
+	pb.RegisterPoslanServer(srv, NewGRPCServer(svc))
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	level.Info(logger).Log("message", "gRPC server listening.", "addr", l.Addr().String())
+
+	return srv.Serve(l)
+}
+
+// metadataToContext extracts bearer token from 'authorization'
+// metadata and stores it in context.
+func metadataToContext(ctx context.Context, md metadata.MD) context.Context {
+	vs := md.Get("authorization")
+	if len(vs) < 1 {
+		return ctx
+	}
+
+	tk, err := bearerToken(vs[0])
+	if err != nil {
+		return ctx
+	}
+
+	return tokenToContext(ctx, tk)
+}
+
+// Decoders
+func decodeGRPCSignInRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.SignInRequest)
+	return signInRequest{
+		ClientID: req.ClientId,
+		Secret:   req.Secret,
+	}, nil
+}
+
+func decodeGRPCSendRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.SendRequest)
+
+	if req.Template != "" {
+		return nil, status.Error(codes.Unimplemented, errTemplatesNotSupported.Error())
+	}
+
+	sr := sendRequest{
+		To:       req.To,
+		Cc:       req.Cc,
+		Bcc:      req.Bcc,
+		ReplyTo:  req.ReplyTo,
+		Subject:  req.Subject,
+		Body:     req.Body,
+		Headers:  req.Headers,
+		Metadata: req.Metadata,
+		Tags:     req.Tags,
+	}
+
+	for _, p := range req.Personalizations {
+		sr.Personalizations = append(sr.Personalizations, personalization{
+			To:            p.To,
+			Substitutions: p.Substitutions,
+		})
+	}
+
+	return sr, nil
+}
+
+func decodeGRPCMessageRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.GetMessageRequest)
+
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, wrapError(errInvalidRequest, errors.New("invalid message id"))
+	}
+
+	return messageRequest{ID: id}, nil
+}
+
+// Encoders
+func encodeGRPCSignInResponse(ctx context.Context, r interface{}) (interface{}, error) {
+	res := r.(signInResponse)
+	return &pb.SignInResponse{Token: res.Token}, nil
+}
+
+func encodeGRPCSendResponse(ctx context.Context, r interface{}) (interface{}, error) {
+	res := r.(sendResponse)
+	return &pb.SendResponse{Email: pbEmail(res)}, nil
+}
+
+func encodeGRPCMessageResponse(ctx context.Context, r interface{}) (interface{}, error) {
+	res := r.(messageResponse)
+	return pbMessageStatus(res.Status), nil
+}
+
+// grpcError returns a gRPC status error for a service error.
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var code codes.Code
+	switch errorKind(err) {
+	case errInvalidRequest:
+		code = codes.InvalidArgument
+	case errInvalidCredentials, errInvalidToken:
+		code = codes.Unauthenticated
+	case errForbidden:
+		code = codes.PermissionDenied
+	case errMessageNotFound:
+		code = codes.NotFound
+	case errConflict:
+		code = codes.AlreadyExists
+	case errInvalidEmail, errValidation:
+		code = codes.InvalidArgument
+	case errRateLimited:
+		code = codes.ResourceExhausted
+	case errDeliveryFailed:
+		code = codes.Unavailable
+	case errNotImplemented:
+		code = codes.Unimplemented
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	return status.Error(code, err.Error())
+}
+
+func pbEmail(res sendResponse) *pb.Email {
+	e := &pb.Email{
+		Id:       res.ID.String(),
+		Name:     res.Name,
+		From:     res.From,
+		To:       res.To,
+		Cc:       res.Cc,
+		Bcc:      res.Bcc,
+		ReplyTo:  res.ReplyTo,
+		Subject:  res.Subject,
+		Body:     res.Body,
+		Charset:  res.Charset,
+		Headers:  res.Headers,
+		Metadata: res.Metadata,
+		Tags:     res.Tags,
+	}
+
+	for _, p := range res.Personalizations {
+		e.Personalizations = append(e.Personalizations, &pb.Personalization{
+			To:            p.To,
+			Substitutions: p.Substitutions,
+		})
+	}
+
+	return e
+}
+
+func pbMessageStatus(st *messageStatus) *pb.MessageStatus {
+	return &pb.MessageStatus{
+		Id:        st.ID.String(),
+		Status:    st.Status,
+		Provider:  st.Provider,
+		Attempts:  int32(st.Attempts),
+		Error:     st.Error,
+		CreatedAt: unix(st.CreatedAt),
+		UpdatedAt: unix(st.UpdatedAt),
+	}
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
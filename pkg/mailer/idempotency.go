@@ -0,0 +1,227 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/auth"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencySweepFrequency = time.Minute
+)
+
+// idempotentResponse is a stored response.
+type idempotentResponse struct {
+	// fingerprint identifies the request.
+	fingerprint [sha256.Size]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore keeps in memory the responses
+// of requests by client and idempotency key.
+type idempotencyStore struct {
+	mux       sync.Mutex
+	ttl       time.Duration
+	responses map[string]*idempotentResponse
+	swept     time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:       ttl,
+		responses: make(map[string]*idempotentResponse),
+	}
+}
+
+// Begin returns the stored response of a key, if any,
+// otherwise the key is reserved until Done or Abort are called.
+// It fails if the key is reserved or it was used
+// with a request of a different fingerprint.
+func (is *idempotencyStore) Begin(key string, fp [sha256.Size]byte) (res *idempotentResponse, err error) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+
+	now := time.Now()
+	is.sweep(now)
+
+	r, ok := is.responses[key]
+	if ok && now.Before(r.expires) {
+		switch {
+		case r.fingerprint != fp:
+			return nil, errKeyReused
+		case !r.done:
+			return nil, errRequestInProgress
+		default:
+			return r, nil
+		}
+	}
+
+	is.responses[key] = &idempotentResponse{
+		fingerprint: fp,
+		expires:     now.Add(is.ttl),
+	}
+	return nil, nil
+}
+
+// Done stores the response of a reserved key.
+func (is *idempotencyStore) Done(key string, status int, header http.Header, body []byte) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+
+	r, ok := is.responses[key]
+	if !ok {
+		return
+	}
+
+	r.done = true
+	r.status = status
+	r.header = header
+	r.body = body
+	r.expires = time.Now().Add(is.ttl)
+}
+
+// Abort releases a reserved key.
+func (is *idempotencyStore) Abort(key string) {
+	is.mux.Lock()
+	defer is.mux.Unlock()
+
+	delete(is.responses, key)
+}
+
+// sweep removes expired responses.
+func (is *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(is.swept) < idempotencySweepFrequency {
+		return
+	}
+
+	for k, r := range is.responses {
+		if !now.Before(r.expires) {
+			delete(is.responses, k)
+		}
+	}
+	is.swept = now
+}
+
+// idempotent replays the stored response of requests
+// of a client with the same Idempotency-Key header.
+// Only successful responses are stored so that
+// failed requests can be retried with the same key.
+// Requests without key or a valid token are not affected.
+func idempotent(is *idempotencyStore, as auth.SecServer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tk, err := readToken(r)
+		if err != nil || as.ValidateToken(tk) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ud, _ := userDataToContext(r.Context(), r).Value(userDataCtxKey).(map[string]string)
+		if ud["clientID"] == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			encodeError(r.Context(), wrapError(errInvalidRequest, errors.New("idempotency key too long")), w)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+		if err != nil {
+			encodeError(r.Context(), wrapError(errInvalidRequest, err), w)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key = ud["clientID"] + ":" + key
+		fp := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+
+		res, err := is.Begin(key, fp)
+		switch err {
+		case errRequestInProgress:
+			encodeError(r.Context(), wrapError(errConflict, err), w)
+			return
+		case errKeyReused:
+			encodeError(r.Context(), err, w)
+			return
+		}
+
+		if res != nil {
+			// Replays keep the ID of the current request.
+			for k, v := range res.header {
+				if k == http.CanonicalHeaderKey(requestIDHeader) {
+					continue
+				}
+				w.Header()[k] = v
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(res.status)
+			w.Write(res.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if rec.status < 200 || rec.status > 299 {
+				is.Abort(key)
+				return
+			}
+			is.Done(key, rec.status, copyHeader(rec.Header()), rec.body.Bytes())
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// responseRecorder writes a response
+// keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+func copyHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
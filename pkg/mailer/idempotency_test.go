@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestIdempotencyStore checks key reservation and expiration.
+func TestIdempotencyStore(t *testing.T) {
+	is := newIdempotencyStore(50 * time.Millisecond)
+	fp1 := sha256.Sum256([]byte("request 1"))
+	fp2 := sha256.Sum256([]byte("request 2"))
+
+	if res, err := is.Begin("key", fp1); res != nil || err != nil {
+		t.Fatalf("Expected: key reserved | Received: %v %v", res, err)
+	}
+
+	if _, err := is.Begin("key", fp1); err != errRequestInProgress {
+		t.Errorf("Expected: '%v' | Received: '%v'", errRequestInProgress, err)
+	}
+
+	is.Done("key", http.StatusOK, http.Header{}, []byte("body"))
+
+	if res, err := is.Begin("key", fp1); err != nil || res == nil || string(res.body) != "body" {
+		t.Errorf("Expected: stored response | Received: %v %v", res, err)
+	}
+
+	if _, err := is.Begin("key", fp2); err != errKeyReused {
+		t.Errorf("Expected: '%v' | Received: '%v'", errKeyReused, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if res, err := is.Begin("key", fp2); res != nil || err != nil {
+		t.Errorf("Expected: expired key reserved | Received: %v %v", res, err)
+	}
+
+	is.Abort("key")
+
+	if res, err := is.Begin("key", fp1); res != nil || err != nil {
+		t.Errorf("Expected: released key reserved | Received: %v %v", res, err)
+	}
+}
@@ -8,18 +8,18 @@ import (
 	// "github.com/go-kit/kit/log"
 
 	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/metrics"
 	"github.com/google/uuid"
+	zipkin "github.com/openzipkin/zipkin-go"
 )
 
 type instrumentationMiddleware struct {
 	ctx            context.Context
-	cfg            *config.Config
 	logger         log.Logger
 	requestCount   metrics.Counter
 	requestLatency metrics.Histogram
-	countResult    metrics.Histogram
 	next           Service
 }
 
@@ -46,14 +46,58 @@ func (mw instrumentationMiddleware) SignOut(ctx context.Context, id uuid.UUID) (
 }
 
 // Send is an instrumentation middleware wrapper over another interface implementation of Send.
-func (mw instrumentationMiddleware) Send(ctx context.Context, to, cc, bcc, subject, body string) (err error) {
+func (mw instrumentationMiddleware) Send(ctx context.Context, email *model.Email) (output *model.Email, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "Send", "error", fmt.Sprint(err != nil)}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return mw.next.Send(ctx, to, cc, bcc, subject, body)
+	return mw.next.Send(ctx, email)
+}
+
+// SendBatch is an instrumentation middleware wrapper over another interface implementation of SendBatch.
+func (mw instrumentationMiddleware) SendBatch(ctx context.Context, email *model.Email) (output *model.Email, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "SendBatch", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.next.SendBatch(ctx, email)
+}
+
+// Message is an instrumentation middleware wrapper over another interface implementation of Message.
+func (mw instrumentationMiddleware) Message(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "Message", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.next.Message(ctx, id)
+}
+
+// Cancel is an instrumentation middleware wrapper over another interface implementation of Cancel.
+func (mw instrumentationMiddleware) Cancel(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "Cancel", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.next.Cancel(ctx, id)
+}
+
+// Reschedule is an instrumentation middleware wrapper over another interface implementation of Reschedule.
+func (mw instrumentationMiddleware) Reschedule(ctx context.Context, id uuid.UUID, sendAt time.Time) (output *model.MessageStatus, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "Reschedule", "error", fmt.Sprint(err != nil)}
+		mw.requestCount.With(lvs...).Add(1)
+		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return mw.next.Reschedule(ctx, id, sendAt)
 }
 
 // Config returns service context.
@@ -63,10 +107,15 @@ func (mw instrumentationMiddleware) Context() context.Context {
 
 // Config returns service config.
 func (mw instrumentationMiddleware) Config() *config.Config {
-	return mw.cfg
+	return mw.next.Config()
 }
 
 // Config returns service logger.
 func (mw instrumentationMiddleware) Logger() log.Logger {
 	return mw.logger
 }
+
+// Tracer returns service tracer.
+func (mw instrumentationMiddleware) Tracer() *zipkin.Tracer {
+	return mw.next.Tracer()
+}
@@ -9,11 +9,13 @@ package mailer
 
 import (
 	"context"
+	"time"
 
 	"github.com/adrianpk/poslan/internal/config"
 	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
+	zipkin "github.com/openzipkin/zipkin-go"
 )
 
 // Service provides authentication and authorization services
@@ -21,9 +23,14 @@ type Service interface {
 	Context() context.Context
 	Config() *config.Config
 	Logger() log.Logger
+	Tracer() *zipkin.Tracer
 	SignIn(ctx context.Context, clientID, secret string) (string, error)
 	SignOut(ctx context.Context, id uuid.UUID) error
-	Send(ctx context.Context, to, cc, bcc, subject, body string) error
+	Send(ctx context.Context, email *model.Email) (*model.Email, error)
+	SendBatch(ctx context.Context, email *model.Email) (*model.Email, error)
+	Message(ctx context.Context, id uuid.UUID) (*model.MessageStatus, error)
+	Cancel(ctx context.Context, id uuid.UUID) (*model.MessageStatus, error)
+	Reschedule(ctx context.Context, id uuid.UUID, sendAt time.Time) (*model.MessageStatus, error)
 }
 
 // Mailer interface
@@ -0,0 +1,18 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"os"
+)
+
+// lockFile does nothing: files are not locked on Windows
+// so the schedule dir is not protected from concurrent changes.
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,172 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+var (
+	logFieldsCtxKey = contextKey("log-fields")
+)
+
+// leveledLogger filters out log events below its level.
+// Level can be changed at runtime.
+type leveledLogger struct {
+	base   log.Logger
+	mux    sync.RWMutex
+	level  string
+	logger log.Logger
+}
+
+// newFormatLogger returns a logger writing timestamped
+// events to w in the format provided: logfmt or json.
+func newFormatLogger(w io.Writer, format string) (log.Logger, error) {
+	var logger log.Logger
+
+	switch format {
+	case string(c.LogFormat.Logfmt), "":
+		logger = log.NewLogfmtLogger(log.NewSyncWriter(w))
+	case string(c.LogFormat.JSON):
+		logger = log.NewJSONLogger(log.NewSyncWriter(w))
+	default:
+		return nil, fmt.Errorf("unknown log format '%s'", format)
+	}
+
+	return log.With(logger, "ts", log.DefaultTimestampUTC), nil
+}
+
+// newLeveledLogger returns a logger passing to base the events
+// of the level provided (debug, info, warn or error) or above.
+func newLeveledLogger(base log.Logger, lvl string) (*leveledLogger, error) {
+	l := &leveledLogger{base: base}
+	if err := l.SetLevel(lvl); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Log logs the event if its level is allowed.
+func (l *leveledLogger) Log(keyvals ...interface{}) error {
+	l.mux.RLock()
+	logger := l.logger
+	l.mux.RUnlock()
+
+	return logger.Log(keyvals...)
+}
+
+// Level returns the current level.
+func (l *leveledLogger) Level() string {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	return l.level
+}
+
+// SetLevel changes the level.
+func (l *leveledLogger) SetLevel(lvl string) error {
+	opt, ok := levelOption(lvl)
+	if !ok {
+		return fmt.Errorf("unknown log level '%s'", lvl)
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.level = lvl
+	l.logger = level.NewFilter(l.base, opt)
+	return nil
+}
+
+// levelOption returns the filter option of a level.
+// Fatal is treated as error.
+func levelOption(lvl string) (level.Option, bool) {
+	switch lvl {
+	case string(c.LogLevel.Debug):
+		return level.AllowDebug(), true
+	case string(c.LogLevel.Info):
+		return level.AllowInfo(), true
+	case string(c.LogLevel.Warn):
+		return level.AllowWarn(), true
+	case string(c.LogLevel.Error), string(c.LogLevel.Fatal):
+		return level.AllowError(), true
+	default:
+		return nil, false
+	}
+}
+
+// withLogFields returns a context whose request scoped
+// log fields include the key value pairs provided.
+func withLogFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	fields, _ := ctx.Value(logFieldsCtxKey).([]interface{})
+	fs := make([]interface{}, 0, len(fields)+len(keyvals))
+	fs = append(fs, fields...)
+	fs = append(fs, keyvals...)
+	return context.WithValue(ctx, logFieldsCtxKey, fs)
+}
+
+// ctxLogger returns a logger adding the request scoped
+// fields of the context: signed in client ID and log fields.
+func ctxLogger(ctx context.Context, logger log.Logger) log.Logger {
+	var fields []interface{}
+
+	if ud, ok := ctx.Value(userDataCtxKey).(map[string]string); ok && ud["clientID"] != "" {
+		fields = append(fields, "clientID", ud["clientID"])
+	}
+
+	if fs, ok := ctx.Value(logFieldsCtxKey).([]interface{}); ok {
+		fields = append(fields, fs...)
+	}
+
+	if len(fields) == 0 {
+		return logger
+	}
+	return log.With(logger, fields...)
+}
+
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// logLevelHandler returns the current log level
+// and lets change it with PUT requests.
+func logLevelHandler(l *leveledLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body logLevelBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				encodeError(r.Context(), wrapError(errInvalidRequest, err), w)
+				return
+			}
+
+			if err := l.SetLevel(body.Level); err != nil {
+				encodeError(r.Context(), wrapError(errInvalidRequest, err), w)
+				return
+			}
+
+			level.Warn(l).Log("package", "mailer", "method", "logLevelHandler", "message", "Log level changed.", "logLevel", body.Level)
+		default:
+			encodeError(r.Context(), errMethodNotAllowed, w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(logLevelBody{Level: l.Level()})
+	})
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// TestLeveledLogger checks level filtering, runtime level
+// changes and request scoped fields.
+func TestLeveledLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	base, _ := newFormatLogger(&buf, "json")
+	logger, err := newLeveledLogger(base, "info")
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	ctx := context.WithValue(context.Background(), userDataCtxKey, map[string]string{"clientID": clientID})
+	ctx = withLogFields(ctx, "messageID", "message-id")
+
+	level.Debug(logger).Log("message", "filtered")
+	level.Info(ctxLogger(ctx, logger)).Log("message", "logged")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected: a single JSON entry | Received: %s", buf.String())
+	}
+
+	if entry["message"] != "logged" || entry["level"] != "info" || entry["clientID"] != clientID || entry["messageID"] != "message-id" {
+		t.Errorf("Expected: info entry with request fields | Received: %v", entry)
+	}
+
+	buf.Reset()
+	if err := logger.SetLevel("debug"); err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	level.Debug(logger).Log("message", "logged")
+	if !strings.Contains(buf.String(), `"level":"debug"`) {
+		t.Errorf("Expected: debug entry | Received: %s", buf.String())
+	}
+
+	if err := logger.SetLevel("verbose"); err == nil || logger.Level() != "debug" {
+		t.Errorf("Expected: error and unchanged level | Received: %v, %s", err, logger.Level())
+	}
+
+	if _, err := newFormatLogger(&buf, "xml"); err == nil {
+		t.Error("Expected: unknown format error | Received: no error")
+	}
+}
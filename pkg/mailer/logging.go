@@ -9,18 +9,18 @@ package mailer
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/adrianpk/poslan/internal/config"
-	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
+	zipkin "github.com/openzipkin/zipkin-go"
 )
 
 type loggingMiddleware struct {
 	ctx    context.Context
-	cfg    *config.Config
 	logger log.Logger
 	next   Service
 }
@@ -28,12 +28,9 @@ type loggingMiddleware struct {
 // SignIn is a logging middleware wrapper over another interface implementation of SignIn.
 func (mw loggingMiddleware) SignIn(ctx context.Context, clientID, secret string) (output string, err error) {
 	defer func(begin time.Time) {
-		input := fmt.Sprintf("{%s, %s}", clientID, secret)
-		mw.logger.Log(
-			"level", c.LogLevel.Info,
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
 			"method", "SignIn",
-			"input", input,
-			"output", output,
+			"clientID", clientID,
 			"err", err,
 			"took", time.Since(begin),
 		)
@@ -46,11 +43,9 @@ func (mw loggingMiddleware) SignIn(ctx context.Context, clientID, secret string)
 // SignOut is a logging middleware wrapper over another interface implementation of SignOut.
 func (mw loggingMiddleware) SignOut(ctx context.Context, id uuid.UUID) (err error) {
 	defer func(begin time.Time) {
-		input := fmt.Sprintf("{%s}", id.String())
-		mw.logger.Log(
-			"level", c.LogLevel.Info,
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
 			"method", "SignOut",
-			"input", input,
+			"id", id,
 			"err", err,
 			"took", time.Since(begin),
 		)
@@ -61,19 +56,86 @@ func (mw loggingMiddleware) SignOut(ctx context.Context, id uuid.UUID) (err erro
 }
 
 // Send is a logging middleware wrapper over another interface implementation of Send.
-func (mw loggingMiddleware) Send(ctx context.Context, to, cc, bcc, subject, body string) (err error) {
+func (mw loggingMiddleware) Send(ctx context.Context, email *model.Email) (output *model.Email, err error) {
 	defer func(begin time.Time) {
-		input := fmt.Sprintf("{%s, %s, %s, %s}", to, cc, bcc, body)
-		mw.logger.Log(
-			"level", c.LogLevel.Info,
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
 			"method", "Send",
-			"input", input,
+			"to", email.To,
+			"cc", email.CC,
+			"bcc", email.BCC,
+			"subject", email.Subject,
+			"body", email.Body,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	err = mw.next.Send(ctx, to, cc, bcc, subject, body)
+	output, err = mw.next.Send(ctx, email)
+	return
+}
+
+// SendBatch is a logging middleware wrapper over another interface implementation of SendBatch.
+func (mw loggingMiddleware) SendBatch(ctx context.Context, email *model.Email) (output *model.Email, err error) {
+	defer func(begin time.Time) {
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
+			"method", "SendBatch",
+			"recipients", len(email.Personalizations),
+			"cc", email.CC,
+			"bcc", email.BCC,
+			"subject", email.Subject,
+			"body", email.Body,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.SendBatch(ctx, email)
+	return
+}
+
+// Message is a logging middleware wrapper over another interface implementation of Message.
+func (mw loggingMiddleware) Message(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	defer func(begin time.Time) {
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
+			"method", "Message",
+			"id", id,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.Message(ctx, id)
+	return
+}
+
+// Cancel is a logging middleware wrapper over another interface implementation of Cancel.
+func (mw loggingMiddleware) Cancel(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	defer func(begin time.Time) {
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
+			"method", "Cancel",
+			"id", id,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.Cancel(ctx, id)
+	return
+}
+
+// Reschedule is a logging middleware wrapper over another interface implementation of Reschedule.
+func (mw loggingMiddleware) Reschedule(ctx context.Context, id uuid.UUID, sendAt time.Time) (output *model.MessageStatus, err error) {
+	defer func(begin time.Time) {
+		level.Info(ctxLogger(ctx, mw.logger)).Log(
+			"method", "Reschedule",
+			"id", id,
+			"sendAt", sendAt.Format(time.RFC3339),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	output, err = mw.next.Reschedule(ctx, id, sendAt)
 	return
 }
 
@@ -83,10 +145,15 @@ func (mw loggingMiddleware) Context() context.Context {
 
 // Config returns service config.
 func (mw loggingMiddleware) Config() *config.Config {
-	return mw.cfg
+	return mw.next.Config()
 }
 
 // Remove is a logging middleware wrapper over another interface implementation of Remove.
 func (mw loggingMiddleware) Logger() log.Logger {
 	return mw.logger
 }
+
+// Tracer returns service tracer.
+func (mw loggingMiddleware) Tracer() *zipkin.Tracer {
+	return mw.next.Tracer()
+}
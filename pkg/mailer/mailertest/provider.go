@@ -0,0 +1,156 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package mailertest provides utilities to test mail delivery offline:
+// a scriptable in-memory provider and local stand-ins of provider APIs.
+package mailertest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+)
+
+// Result is a scripted provider send result.
+type Result struct {
+	// Resend is returned along with Err.
+	Resend bool
+	// Err is returned by Send, nil means success.
+	Err error
+	// Latency delays Send.
+	Latency time.Duration
+}
+
+// Provider is a scriptable in-memory delivery provider.
+// Unless scripted, each send succeeds and the message is recorded.
+type Provider struct {
+	mux      sync.Mutex
+	name     string
+	priority int
+	ready    bool
+	latency  time.Duration
+	script   []Result
+	sent     []*model.Email
+	attempts int
+}
+
+// NewProvider returns a ready in-memory provider.
+func NewProvider(name string, priority int) *Provider {
+	return &Provider{
+		name:     name,
+		priority: priority,
+		ready:    true,
+	}
+}
+
+// Script queues results to be returned by next sends,
+// once they are consumed sends succeed again.
+func (p *Provider) Script(rs ...Result) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.script = append(p.script, rs...)
+}
+
+// FailNext makes the next n sends fail.
+func (p *Provider) FailNext(n int, resend bool, err error) {
+	for i := 0; i < n; i++ {
+		p.Script(Result{Resend: resend, Err: err})
+	}
+}
+
+// SetLatency sets a delay applied to every send.
+func (p *Provider) SetLatency(d time.Duration) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.latency = d
+}
+
+// SetReady sets provider ready state.
+func (p *Provider) SetReady(ready bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.ready = ready
+}
+
+// Send records the email or returns the next scripted error.
+func (p *Provider) Send(em *model.Email) (resend bool, err error) {
+	p.mux.Lock()
+	p.attempts++
+	r := Result{}
+	if len(p.script) > 0 {
+		r = p.script[0]
+		p.script = p.script[1:]
+	}
+	latency := p.latency + r.Latency
+	p.mux.Unlock()
+
+	time.Sleep(latency)
+
+	if r.Err != nil {
+		return r.Resend, r.Err
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	e := *em
+	p.sent = append(p.sent, &e)
+
+	return false, nil
+}
+
+// Messages returns successfully sent messages.
+func (p *Provider) Messages() []*model.Email {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	ms := make([]*model.Email, len(p.sent))
+	copy(ms, p.sent)
+	return ms
+}
+
+// Attempts returns the number of sends, successful or not.
+func (p *Provider) Attempts() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.attempts
+}
+
+// Reset clears recorded messages, attempts and pending script.
+func (p *Provider) Reset() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.sent = nil
+	p.script = nil
+	p.attempts = 0
+}
+
+// Name return the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Priority return the provider priority.
+func (p *Provider) Priority() int {
+	return p.priority
+}
+
+// Start the provider.
+func (p *Provider) Start() error {
+	return nil
+}
+
+// Stop the provider.
+func (p *Provider) Stop() error {
+	return nil
+}
+
+// IsReady return true if provider is ready.
+func (p *Provider) IsReady() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.ready
+}
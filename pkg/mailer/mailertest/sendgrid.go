@@ -0,0 +1,123 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+const (
+	sgSendPath = "/v3/mail/send"
+)
+
+// SendGridServer emulates SendGrid v3 '/mail/send' API.
+// Point a SendGrid provider config endpoint to its URL.
+type SendGridServer struct {
+	*httptest.Server
+	mux      sync.Mutex
+	apiKey   string
+	messages []*sgmail.SGMailV3
+	failures []int
+}
+
+// NewSendGridServer starts a SendGrid API stand-in.
+// If apiKey is not empty requests must be authorized with it.
+// Caller should call Close when finished.
+func NewSendGridServer(apiKey string) *SendGridServer {
+	s := &SendGridServer{apiKey: apiKey}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// FailNext makes the next requests fail
+// with each one of the provided status codes.
+func (s *SendGridServer) FailNext(status ...int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failures = append(s.failures, status...)
+}
+
+// Messages returns received messages.
+func (s *SendGridServer) Messages() []*sgmail.SGMailV3 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ms := make([]*sgmail.SGMailV3, len(s.messages))
+	copy(ms, s.messages)
+	return ms
+}
+
+// Reset clears received messages and pending failures.
+func (s *SendGridServer) Reset() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.messages = nil
+	s.failures = nil
+}
+
+func (s *SendGridServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != sgSendPath {
+		sgError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		sgError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		sgError(w, http.StatusUnauthorized, "The provided authorization grant is invalid, expired, or revoked")
+		return
+	}
+
+	s.mux.Lock()
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mux.Unlock()
+		sgError(w, status, "scripted failure")
+		return
+	}
+	s.mux.Unlock()
+
+	var m sgmail.SGMailV3
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		sgError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(m.Personalizations) == 0 || m.From == nil {
+		sgError(w, http.StatusBadRequest, "personalizations and from are required")
+		return
+	}
+
+	s.mux.Lock()
+	s.messages = append(s.messages, &m)
+	s.mux.Unlock()
+
+	// As SendGrid, sandbox mode answers OK instead of accepted.
+	if ms := m.MailSettings; ms != nil && ms.SandboxMode != nil && ms.SandboxMode.Enable != nil && *ms.SandboxMode.Enable {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func sgError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"errors":[{"message":%q,"field":null,"help":null}]}`, strings.TrimSpace(msg))
+}
@@ -0,0 +1,165 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailertest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// SESMessage is a message received by the SES stand-in.
+type SESMessage struct {
+	Action           string
+	Source           string
+	Destinations     []string
+	Raw              []byte
+	ConfigurationSet string
+	Tags             map[string]string
+}
+
+// SESServer emulates Amazon SES query API
+// SendRawEmail action.
+// Point an Amazon SES provider config endpoint to its URL.
+type SESServer struct {
+	*httptest.Server
+	mux      sync.Mutex
+	messages []SESMessage
+	failures []string
+}
+
+// NewSESServer starts an Amazon SES API stand-in.
+// Caller should call Close when finished.
+func NewSESServer() *SESServer {
+	s := &SESServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// FailNext makes the next requests fail
+// with each one of the provided SES error codes
+// (i.e.: ses.ErrCodeMessageRejected).
+func (s *SESServer) FailNext(codes ...string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+// Messages returns received messages.
+func (s *SESServer) Messages() []SESMessage {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ms := make([]SESMessage, len(s.messages))
+	copy(ms, s.messages)
+	return ms
+}
+
+// Reset clears received messages and pending failures.
+func (s *SESServer) Reset() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.messages = nil
+	s.failures = nil
+}
+
+func (s *SESServer) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		sesError(w, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+		return
+	}
+
+	action := r.PostForm.Get("Action")
+	if action != "SendRawEmail" {
+		sesError(w, http.StatusBadRequest, "InvalidAction", fmt.Sprintf("action '%s' not supported", action))
+		return
+	}
+
+	s.mux.Lock()
+	if len(s.failures) > 0 {
+		code := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mux.Unlock()
+		sesError(w, http.StatusBadRequest, code, "scripted failure")
+		return
+	}
+	s.mux.Unlock()
+
+	raw, err := base64.StdEncoding.DecodeString(r.PostForm.Get("RawMessage.Data"))
+	if err != nil {
+		sesError(w, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+		return
+	}
+
+	m := SESMessage{
+		Action:           action,
+		Source:           r.PostForm.Get("Source"),
+		Destinations:     members(r, "Destinations.member.%d"),
+		Raw:              raw,
+		ConfigurationSet: r.PostForm.Get("ConfigurationSetName"),
+		Tags:             make(map[string]string),
+	}
+
+	for i := 1; ; i++ {
+		name := r.PostForm.Get(fmt.Sprintf("Tags.member.%d.Name", i))
+		if name == "" {
+			break
+		}
+		m.Tags[name] = r.PostForm.Get(fmt.Sprintf("Tags.member.%d.Value", i))
+	}
+
+	s.mux.Lock()
+	s.messages = append(s.messages, m)
+	s.mux.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<SendRawEmailResponse xmlns="http://ses.amazonaws.com/doc/2010-12-01/">
+  <SendRawEmailResult>
+    <MessageId>%s</MessageId>
+  </SendRawEmailResult>
+  <ResponseMetadata>
+    <RequestId>%s</RequestId>
+  </ResponseMetadata>
+</SendRawEmailResponse>`, uuid.New().String(), uuid.New().String())
+}
+
+// members returns query API list values.
+func members(r *http.Request, format string) []string {
+	vs := make([]string, 0)
+	for i := 1; ; i++ {
+		v := r.PostForm.Get(fmt.Sprintf(format, i))
+		if v == "" {
+			break
+		}
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+	return vs
+}
+
+func sesError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<ErrorResponse xmlns="http://ses.amazonaws.com/doc/2010-12-01/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>%s</Code>
+    <Message>%s</Message>
+  </Error>
+  <RequestId>%s</RequestId>
+</ErrorResponse>`, code, xmlEscape(msg), uuid.New().String())
+}
+
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -8,41 +8,192 @@
 package mailer
 
 import (
+	"net"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	metricsNamespace = "poslan"
+)
+
+// Delivery error classes.
+const (
+	// errorClassTimeout errors are provider request timeouts.
+	errorClassTimeout = "timeout"
+	// errorClassTransient errors let resend the message.
+	errorClassTransient = "transient"
+	// errorClassRejected messages cannot be resent.
+	errorClassRejected = "rejected"
+)
+
 type meters struct {
-	ReqCount    *kitprometheus.Counter
-	ReqLatency  *kitprometheus.Summary
-	CountResult *kitprometheus.Summary
+	ReqCount   *kitprometheus.Counter
+	ReqLatency *kitprometheus.Summary
+}
+
+// deliveryMeters measure message delivery by provider and client.
+type deliveryMeters struct {
+	// Attempts by provider.
+	Attempts metrics.Counter
+	// Successes by provider.
+	Successes metrics.Counter
+	// Failures by provider and error class.
+	Failures metrics.Counter
+	// Failovers from a provider to the next one.
+	Failovers metrics.Counter
+	// Latency of provider sends.
+	Latency metrics.Histogram
+	// Messages by client and result.
+	ClientMessages metrics.Counter
+}
+
+// reloadMeters measure config reloads.
+type reloadMeters struct {
+	// Reloads by result (success, failure).
+	Reloads metrics.Counter
+	// LastSuccessful is 1 if the last reload succeeded, 0 otherwise.
+	LastSuccessful metrics.Gauge
+	// LastSuccess is the Unix time of the last successful reload.
+	LastSuccess metrics.Gauge
 }
 
 // Instrumentation
 func instrumentationMeters() meters {
 	fieldKeys := []string{"method", "error"}
 	requestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
-		Namespace: "poslan",
-		Subsystem: "auth",
+		Namespace: metricsNamespace,
+		Subsystem: "mailer",
 		Name:      "request_count",
 		Help:      "Nº of requests received.",
 	}, fieldKeys)
 	requestLatency := kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
-		Namespace: "poslan",
-		Subsystem: "auth",
+		Namespace: metricsNamespace,
+		Subsystem: "mailer",
 		Name:      "request_latency_microseconds",
 		Help:      "Total duration of requests in μSeconds.",
 	}, fieldKeys)
-	countResult := kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
-		Namespace: "poslan",
-		Subsystem: "auth",
-		Name:      "count_result",
-		Help:      "Sumary.",
-	}, []string{}) // no fields here
 
 	return meters{
-		ReqCount:    requestCount,
-		ReqLatency:  requestLatency,
-		CountResult: countResult,
+		ReqCount:   requestCount,
+		ReqLatency: requestLatency,
+	}
+}
+
+// delivery meters are shared by every service instance
+// since collectors can only be registered once.
+var delivery = makeDeliveryMeters()
+
+func makeDeliveryMeters() deliveryMeters {
+	return deliveryMeters{
+		Attempts: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provider",
+			Name:      "attempts_total",
+			Help:      "Nº of delivery attempts.",
+		}, []string{"provider"}),
+		Successes: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provider",
+			Name:      "successes_total",
+			Help:      "Nº of messages accepted by the provider.",
+		}, []string{"provider"}),
+		Failures: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provider",
+			Name:      "failures_total",
+			Help:      "Nº of failed delivery attempts by error class (timeout, transient, rejected).",
+		}, []string{"provider", "class"}),
+		Failovers: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provider",
+			Name:      "failovers_total",
+			Help:      "Nº of messages passed from a failed provider to the next one.",
+		}, []string{"from", "to"}),
+		Latency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provider",
+			Name:      "latency_seconds",
+			Help:      "Duration of provider delivery attempts in seconds.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"provider"}),
+		ClientMessages: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "client",
+			Name:      "messages_total",
+			Help:      "Nº of messages by client and delivery status.",
+		}, []string{"client", "status"}),
+	}
+}
+
+// reload meters are shared by every service instance
+// since collectors can only be registered once.
+var reloads = makeReloadMeters()
+
+func makeReloadMeters() reloadMeters {
+	return reloadMeters{
+		Reloads: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "config",
+			Name:      "reloads_total",
+			Help:      "Nº of config reloads by result (success, failure).",
+		}, []string{"result"}),
+		LastSuccessful: kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "config",
+			Name:      "last_reload_successful",
+			Help:      "Whether the last config reload succeeded.",
+		}, []string{}),
+		LastSuccess: kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "config",
+			Name:      "last_reload_success_timestamp_seconds",
+			Help:      "Unix time of the last successful config reload.",
+		}, []string{}),
+	}
+}
+
+// registerSchedulerMeters registers scheduler queue gauges,
+// they are computed when metrics are collected.
+func registerSchedulerMeters(sch *scheduler) {
+	register(stdprometheus.NewGaugeFunc(stdprometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scheduler",
+		Name:      "queue_depth",
+		Help:      "Nº of scheduled messages waiting to be sent.",
+	}, func() float64 {
+		depth, _ := sch.Stats(time.Now())
+		return float64(depth)
+	}))
+
+	register(stdprometheus.NewGaugeFunc(stdprometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "scheduler",
+		Name:      "queue_age_seconds",
+		Help:      "Time since the send time of the oldest due message not yet released.",
+	}, func() float64 {
+		_, age := sch.Stats(time.Now())
+		return age.Seconds()
+	}))
+}
+
+// register registers a collector replacing
+// a previously registered one, if any.
+func register(c stdprometheus.Collector) {
+	stdprometheus.Unregister(c)
+	stdprometheus.MustRegister(c)
+}
+
+// errorClass returns the class of a delivery error.
+func errorClass(err error, resend bool) string {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return errorClassTimeout
+	}
+	if resend {
+		return errorClassTransient
 	}
+	return errorClassRejected
 }
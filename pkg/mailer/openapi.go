@@ -0,0 +1,398 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	js "github.com/adrianpk/poslan/internal/jsonschema"
+	"github.com/adrianpk/poslan/pkg/model"
+)
+
+const (
+	apiVersion = "1.0.0"
+	// maxBodyBytes is the max size of request bodies.
+	maxBodyBytes = 1 << 20
+	// maxBatchSize is the max number of recipients of a batch,
+	// it matches SendGrid personalizations limit.
+	maxBatchSize = 1000
+)
+
+// Request schemas.
+// Requests are validated against them before decoding.
+var (
+	signInSchema = &js.Schema{
+		Type:     js.Object,
+		Required: []string{"clientID", "secret"},
+		Properties: map[string]*js.Schema{
+			"clientID": {Type: js.String, MinLength: js.Int(1), Description: "Client ID.", Example: "dd74cb9cfb5a4f1cac4d"},
+			"secret":   {Type: js.String, MinLength: js.Int(1), Description: "Client secret."},
+		},
+	}
+
+	signOutSchema = &js.Schema{
+		Type: js.Object,
+		Properties: map[string]*js.Schema{
+			"id": {Type: js.String, Format: js.UUID, Description: "Session ID."},
+		},
+	}
+
+	personalizationSchema = &js.Schema{
+		Type:     js.Object,
+		Required: []string{"to"},
+		Properties: map[string]*js.Schema{
+			"to": {Type: js.String, Format: js.EmailList, MinLength: js.Int(1), Description: "Recipients of this message."},
+			"substitutions": {
+				Type:                 js.Object,
+				Description:          "Replaced in subject and body by their value.",
+				AdditionalProperties: &js.Schema{Type: js.String},
+				Example:              map[string]string{"{{name}}": "Diana"},
+			},
+		},
+	}
+
+	sendSchema = &js.Schema{
+		Type:        js.Object,
+		Description: "Either 'to' or 'personalizations' must be provided.",
+		Properties: map[string]*js.Schema{
+			"to":      {Type: js.String, Format: js.EmailList, Example: "jane@example.com"},
+			"cc":      {Type: js.String, Format: js.EmailList},
+			"bcc":     {Type: js.String, Format: js.EmailList},
+			"replyTo": {Type: js.String, Format: js.EmailList},
+			"subject": {Type: js.String, MaxLength: js.Int(998), Example: "Welcome"},
+			"body":    {Type: js.String, Description: "Plain text body."},
+			"headers": {
+				Type:                 js.Object,
+				Description:          "Custom message headers.",
+				AdditionalProperties: &js.Schema{Type: js.String},
+				Example:              map[string]string{"X-Campaign": "welcome"},
+			},
+			"metadata": {
+				Type:                 js.Object,
+				Description:          "Name/value pairs attached to the message (i.e.: SES message tags).",
+				AdditionalProperties: &js.Schema{Type: js.String, MaxLength: js.Int(256)},
+			},
+			"tags": {
+				Type:        js.Array,
+				Description: "Message categories.",
+				MaxItems:    js.Int(10),
+				Items:       &js.Schema{Type: js.String, MinLength: js.Int(1), MaxLength: js.Int(255)},
+			},
+			"personalizations": {
+				Type:        js.Array,
+				Description: "Each one is delivered as an individual message.",
+				MaxItems:    js.Int(1000),
+				Items:       personalizationSchema,
+			},
+			"sendAt": sendAtSchema,
+			"delay":  delaySchema,
+		},
+	}
+
+	sendAtSchema = &js.Schema{Type: js.String, Format: js.DateTime, Description: "Delivery time, not along with 'delay'.", Example: "2019-06-01T10:00:00Z"}
+	delaySchema  = &js.Schema{Type: js.String, Format: js.Duration, Description: "Delivery delay, not along with 'sendAt'.", Example: "1h30m"}
+
+	rescheduleSchema = &js.Schema{
+		Type:        js.Object,
+		Description: "Either 'sendAt' or 'delay' must be provided.",
+		Properties: map[string]*js.Schema{
+			"sendAt": sendAtSchema,
+			"delay":  delaySchema,
+		},
+	}
+
+	sendBatchSchema = &js.Schema{
+		Type:        js.Object,
+		Description: "Recipients failing validation are rejected individually.",
+		Required:    []string{"recipients"},
+		Properties: map[string]*js.Schema{
+			"template": {Type: js.String, Description: "Not supported yet."},
+			"cc":       {Type: js.String, Format: js.EmailList, Description: "Only included in the first message."},
+			"bcc":      {Type: js.String, Format: js.EmailList, Description: "Only included in the first message."},
+			"replyTo":  {Type: js.String, Format: js.EmailList},
+			"subject":  {Type: js.String, MaxLength: js.Int(998), Example: "Hi {{name}}"},
+			"body":     {Type: js.String, Description: "Plain text body."},
+			"headers": {
+				Type:                 js.Object,
+				Description:          "Custom message headers.",
+				AdditionalProperties: &js.Schema{Type: js.String},
+			},
+			"metadata": {
+				Type:                 js.Object,
+				Description:          "Name/value pairs attached to each message.",
+				AdditionalProperties: &js.Schema{Type: js.String, MaxLength: js.Int(256)},
+			},
+			"tags": {
+				Type:     js.Array,
+				MaxItems: js.Int(10),
+				Items:    &js.Schema{Type: js.String, MinLength: js.Int(1), MaxLength: js.Int(255)},
+			},
+			"recipients": {
+				Type:        js.Array,
+				Description: "Each one is delivered as an individual message.",
+				MinItems:    js.Int(1),
+				MaxItems:    js.Int(maxBatchSize),
+				Items:       personalizationSchema,
+			},
+		},
+	}
+)
+
+// Response schemas.
+var (
+	signInResponseSchema = &js.Schema{
+		Type: js.Object,
+		Properties: map[string]*js.Schema{
+			"token": {Type: js.String, Description: "Bearer token."},
+		},
+	}
+
+	sendResponseSchema = &js.Schema{
+		Type: js.Object,
+		Properties: map[string]*js.Schema{
+			"id":               {Type: js.String, Format: js.UUID},
+			"status":           {Type: js.String, Enum: []string{model.StatusSent, model.StatusScheduled}},
+			"sendAt":           {Type: js.String, Format: js.DateTime, Description: "Delivery time of scheduled messages."},
+			"requestID":        {Type: js.String},
+			"name":             {Type: js.String},
+			"from":             {Type: js.String},
+			"to":               {Type: js.String},
+			"cc":               {Type: js.String},
+			"bcc":              {Type: js.String},
+			"replyTo":          {Type: js.String},
+			"subject":          {Type: js.String},
+			"body":             {Type: js.String},
+			"charset":          {Type: js.String},
+			"headers":          {Type: js.Object, AdditionalProperties: &js.Schema{Type: js.String}},
+			"metadata":         {Type: js.Object, AdditionalProperties: &js.Schema{Type: js.String}},
+			"tags":             {Type: js.Array, Items: &js.Schema{Type: js.String}},
+			"personalizations": {Type: js.Array, Items: personalizationSchema},
+		},
+	}
+
+	sendBatchResponseSchema = &js.Schema{
+		Type: js.Object,
+		Properties: map[string]*js.Schema{
+			"id":       {Type: js.String, Format: js.UUID, Description: "Batch ID."},
+			"accepted": {Type: js.Integer},
+			"rejected": {Type: js.Integer},
+			"failed":   {Type: js.Integer, Description: "Valid recipients not delivered."},
+			"items": {
+				Type: js.Array,
+				Items: &js.Schema{
+					Type: js.Object,
+					Properties: map[string]*js.Schema{
+						"index":  {Type: js.Integer, Description: "Recipient index in request."},
+						"to":     {Type: js.String},
+						"id":     {Type: js.String, Format: js.UUID, Description: "Message ID of valid recipients."},
+						"status": {Type: js.String, Enum: []string{batchAccepted, batchRejected, batchFailed}},
+						"errors": problemSchema.Properties["errors"],
+						"error":  {Type: js.String, Description: "Delivery error of failed recipients."},
+					},
+				},
+			},
+		},
+	}
+
+	messageStatusSchema = &js.Schema{
+		Type: js.Object,
+		Properties: map[string]*js.Schema{
+			"id":        {Type: js.String, Format: js.UUID},
+			"status":    {Type: js.String, Enum: []string{model.StatusSent, model.StatusFailed, model.StatusScheduled, model.StatusCanceled}},
+			"provider":  {Type: js.String, Description: "Provider that delivered the message."},
+			"attempts":  {Type: js.Integer, Description: "Number of providers tried."},
+			"error":     {Type: js.String},
+			"sendAt":    {Type: js.String, Format: js.DateTime, Description: "Delivery time of scheduled messages."},
+			"requestID": {Type: js.String},
+			"createdAt": {Type: js.String, Format: js.DateTime},
+			"updatedAt": {Type: js.String, Format: js.DateTime},
+		},
+	}
+
+	problemSchema = &js.Schema{
+		Type:        js.Object,
+		Description: "RFC 7807 problem details.",
+		Properties: map[string]*js.Schema{
+			"type":      {Type: js.String},
+			"title":     {Type: js.String},
+			"status":    {Type: js.Integer},
+			"detail":    {Type: js.String},
+			"requestID": {Type: js.String},
+			"errors": {
+				Type:        js.Array,
+				Description: "Field validation errors.",
+				Items: &js.Schema{
+					Type: js.Object,
+					Properties: map[string]*js.Schema{
+						"field":   {Type: js.String, Example: "personalizations.0.to"},
+						"message": {Type: js.String, Example: "is required"},
+					},
+				},
+			},
+		},
+	}
+)
+
+// requestIDParameter is accepted by every operation.
+var requestIDParameter = map[string]interface{}{
+	"name":        requestIDHeader,
+	"in":          "header",
+	"description": "Request ID echoed in the response and forwarded to providers. Alphanumeric, '_', '-' and '.' only, a new one is generated if missing or not valid.",
+	"schema":      &js.Schema{Type: js.String, MaxLength: js.Int(maxRequestIDLength)},
+}
+
+// openAPI returns the OpenAPI 3 document of the HTTP API.
+func openAPI() map[string]interface{} {
+	bearer := []map[string][]string{{"bearer": {}}}
+
+	messageResponseSchema := &js.Schema{Type: js.Object, Properties: map[string]*js.Schema{"status": messageStatusSchema}}
+
+	idempotencyKeyParameter := map[string]interface{}{
+		"name":        idempotencyKeyHeader,
+		"in":          "header",
+		"description": "Replays return the original response, a request with the same key in progress returns 409.",
+		"schema":      &js.Schema{Type: js.String, MaxLength: js.Int(maxIdempotencyKeyLength)},
+	}
+
+	idParameter := map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   &js.Schema{Type: js.String, Format: js.UUID},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.2",
+		"info": map[string]interface{}{
+			"title":       "Poslan",
+			"description": "Redundant mail delivery service.",
+			"version":     apiVersion,
+			"license":     map[string]string{"name": "MIT", "url": "https://opensource.org/licenses/MIT"},
+		},
+		"servers": []map[string]string{{"url": "/v1"}},
+		"paths": map[string]interface{}{
+			"/signin": map[string]interface{}{
+				"post": operation("signIn", "Returns a bearer token for client credentials.", signInSchema, signInResponseSchema, nil,
+					http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity),
+			},
+			"/signout": map[string]interface{}{
+				"post": operation("signOut", "Closes a session (not implemented yet).", signOutSchema, &js.Schema{Type: js.Object}, bearer,
+					http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity, http.StatusNotImplemented),
+			},
+			"/send": map[string]interface{}{
+				"post": withParameters(
+					operation("send", "Sends a mail through the first available provider, now or at a scheduled time.", sendSchema,
+						sendResponseSchema, bearer,
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict,
+						http.StatusUnprocessableEntity, http.StatusBadGateway),
+					idempotencyKeyParameter,
+				),
+			},
+			"/send/batch": map[string]interface{}{
+				"post": withParameters(
+					operation("sendBatch", "Sends a mail to many recipients, each one as an individual message.", sendBatchSchema,
+						sendBatchResponseSchema, bearer,
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict,
+						http.StatusUnprocessableEntity, http.StatusBadGateway, http.StatusNotImplemented),
+					idempotencyKeyParameter,
+				),
+			},
+			"/messages/{id}": map[string]interface{}{
+				"get": withParameters(
+					operation("getMessage", "Returns the delivery status of a sent mail.", nil,
+						messageResponseSchema, bearer,
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+					idParameter,
+				),
+				"patch": withParameters(
+					operation("rescheduleMessage", "Changes the delivery time of a scheduled mail.", rescheduleSchema,
+						messageResponseSchema, bearer,
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+						http.StatusConflict, http.StatusUnprocessableEntity),
+					idParameter,
+				),
+				"delete": withParameters(
+					operation("cancelMessage", "Cancels a scheduled mail.", nil,
+						messageResponseSchema, bearer,
+						http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+						http.StatusConflict),
+					idParameter,
+				),
+			},
+			"/openapi.json": map[string]interface{}{
+				"get": operation("openAPI", "Returns this document.", nil, &js.Schema{Type: js.Object, AdditionalProperties: &js.Schema{}}, nil),
+			},
+		},
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func operation(id, summary string, req, res *js.Schema, security []map[string][]string, errs ...int) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"parameters":  []map[string]interface{}{requestIDParameter},
+	}
+
+	if req != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": req}},
+		}
+	}
+
+	if security != nil {
+		op["security"] = security
+	}
+
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "OK",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": res}},
+		},
+	}
+
+	for _, status := range errs {
+		responses[statusKey(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     map[string]interface{}{"application/problem+json": map[string]interface{}{"schema": problemSchema}},
+		}
+	}
+
+	op["responses"] = responses
+	return op
+}
+
+func withParameters(op map[string]interface{}, params ...map[string]interface{}) map[string]interface{} {
+	ps, _ := op["parameters"].([]map[string]interface{})
+	op["parameters"] = append(ps, params...)
+	return op
+}
+
+func statusKey(status int) string {
+	return strconv.Itoa(status)
+}
+
+// OpenAPIHandler serves the OpenAPI document.
+func OpenAPIHandler() http.Handler {
+	doc, err := json.MarshalIndent(openAPI(), "", "  ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			encodeError(r.Context(), err, w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(doc)
+	})
+}
@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	js "github.com/adrianpk/poslan/internal/jsonschema"
+)
+
+// TestRequestSamples validates sample requests
+// in resources/rest against their schemas.
+func TestRequestSamples(t *testing.T) {
+	samples := map[string]*js.Schema{
+		"signin.json":     signInSchema,
+		"send.json":       sendSchema,
+		"send-batch.json": sendBatchSchema,
+	}
+
+	for name, schema := range samples {
+		data, err := ioutil.ReadFile(filepath.Join("..", "..", "resources", "rest", name))
+		if err != nil {
+			t.Fatalf("[ERROR] %s: %s", name, err.Error())
+		}
+
+		if errs := schema.ValidateJSON(data); len(errs) > 0 {
+			t.Errorf("%s - Expected: valid | Received: %v", name, errs)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/sys"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+// Operations on providers and scheduled messages
+// run without starting the service (i.e.: command line).
+
+const (
+	// opsClientID identifies the messages sent by operators.
+	opsClientID = "ops"
+)
+
+// ProbeResult is the outcome of a probe sent through a provider.
+type ProbeResult struct {
+	Provider string
+	Latency  time.Duration
+	Err      error
+}
+
+// QueuedMessage is a message waiting to be sent.
+type QueuedMessage struct {
+	ClientID  string
+	Email     *model.Email
+	CreatedAt time.Time
+}
+
+// Queue lets inspect and change the messages stored in the
+// schedule dir. Messages cannot be changed while a service runs
+// on the same dir: its scheduler would not see the changes.
+type Queue struct {
+	dir string
+	sch *scheduler
+}
+
+// Deliver sends an email through the enabled providers
+// in config, in priority order, or only through
+// the one provided, if any.
+func Deliver(ctx context.Context, cfg *c.Config, logger log.Logger, email *model.Email, provider string) (*model.MessageStatus, error) {
+	svc := makeService(ctx, cfg, logger)
+
+	ps, ok := initProviders(svc, cfg)
+	if !ok {
+		return nil, errors.New("cannot initialize providers")
+	}
+
+	if provider != "" {
+		ps = selectProvider(ps, provider)
+		if len(ps) == 0 {
+			return nil, fmt.Errorf("provider '%s' is not enabled", provider)
+		}
+	}
+
+	startProviders(logger, ps)
+	defer stopProviders(ps)
+	svc.providers = svc.manage(ps)
+
+	e := makeEmail(email.Name, email.From, email)
+	err := svc.deliver(ctx, opsClientID, e, []uuid.UUID{e.ID})
+
+	st, _ := svc.statuses.Get(e.ID)
+	return st, err
+}
+
+// ProbeProviders sends an email through each enabled provider
+// in config, or only through the one provided, if any.
+// Providers that cannot be initialized are reported as failed.
+func ProbeProviders(ctx context.Context, cfg *c.Config, logger log.Logger, email *model.Email, provider string) []ProbeResult {
+	svc := makeService(ctx, cfg, logger)
+	ps, _ := initProviders(svc, cfg)
+	startProviders(logger, ps)
+	defer stopProviders(ps)
+
+	var rs []ProbeResult
+	for _, pc := range cfg.Mailer.Providers {
+		if !pc.Enabled || (provider != "" && pc.Name != provider) {
+			continue
+		}
+
+		sps := selectProvider(ps, pc.Name)
+		if len(sps) == 0 {
+			rs = append(rs, ProbeResult{Provider: pc.Name, Err: errors.New("cannot initialize provider")})
+			continue
+		}
+
+		e := makeEmail(email.Name, email.From, email)
+		begin := time.Now()
+		_, err := sps[0].Send(e)
+		rs = append(rs, ProbeResult{Provider: pc.Name, Latency: time.Since(begin), Err: err})
+	}
+
+	return rs
+}
+
+// selectProvider returns the providers with the name provided.
+func selectProvider(ps []sys.Provider, name string) []sys.Provider {
+	for _, p := range ps {
+		if p.Name() == name {
+			return []sys.Provider{p}
+		}
+	}
+	return nil
+}
+
+// stopProviders stops providers.
+func stopProviders(ps []sys.Provider) {
+	for _, p := range ps {
+		p.Stop()
+	}
+}
+
+// OpenQueue returns the queue of the messages stored in dir.
+func OpenQueue(dir string) (*Queue, error) {
+	if dir == "" {
+		return nil, errors.New("schedule dir is not set, scheduled messages are only kept in memory")
+	}
+
+	sch, err := newScheduler(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Queue{dir: dir, sch: sch}, nil
+}
+
+// Messages returns queued messages sorted by send time.
+func (q *Queue) Messages() []QueuedMessage {
+	sms := q.sch.Messages()
+	sort.Slice(sms, func(i, j int) bool {
+		return sms[i].Email.SendAt.Before(sms[j].Email.SendAt)
+	})
+
+	qms := make([]QueuedMessage, len(sms))
+	for i, sm := range sms {
+		qms[i] = QueuedMessage(sm)
+	}
+	return qms
+}
+
+// Requeue changes the send time of a queued message.
+// It returns false if there is no such message.
+func (q *Queue) Requeue(id uuid.UUID, sendAt time.Time) (ok bool, err error) {
+	return q.change(func(sch *scheduler) (bool, error) {
+		return sch.Reschedule(id, sendAt)
+	})
+}
+
+// Purge removes a queued message.
+// It returns false if there is no such message.
+func (q *Queue) Purge(id uuid.UUID) (ok bool, err error) {
+	return q.change(func(sch *scheduler) (bool, error) {
+		return sch.Cancel(id)
+	})
+}
+
+// change applies a change to the queued messages
+// unless a running service has the dir locked.
+func (q *Queue) change(fn func(sch *scheduler) (bool, error)) (bool, error) {
+	lock, err := lockDir(q.dir)
+	if err != nil {
+		return false, err
+	}
+	defer lock.Close()
+
+	// Messages are loaded again once the dir is locked.
+	sch, err := newScheduler(q.dir)
+	if err != nil {
+		return false, err
+	}
+	q.sch = sch
+
+	return fn(sch)
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/google/uuid"
+)
+
+// TestQueueLocked checks that queued messages cannot be
+// changed while a service runs on the schedule dir.
+func TestQueueLocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poslan-queue")
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	sch, err := newScheduler(dir)
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	id := uuid.New()
+	sch.Add(scheduledMessage{ClientID: clientID, Email: &model.Email{ID: id, To: "to@example.com", SendAt: time.Now().Add(time.Hour)}})
+
+	// Held by the service scheduler.
+	lock, err := lockDir(dir)
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	q, err := OpenQueue(dir)
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	if _, err := q.Purge(id); err != errDirLocked {
+		t.Errorf("Expected: %s | Received: %v", errDirLocked, err)
+	}
+
+	lock.Close()
+
+	if ok, err := q.Purge(id); !ok || err != nil {
+		t.Errorf("Expected: purged | Received: %t %v", ok, err)
+	}
+}
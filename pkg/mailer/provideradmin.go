@@ -0,0 +1,341 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/sys"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// Provider admin states.
+const (
+	providerEnabled  = "enabled"
+	providerDisabled = "disabled"
+	providerDraining = "draining"
+	providerDrained  = "drained"
+)
+
+// providerControl holds the runtime admin changes of a provider.
+// Controls are kept by provider name so that
+// they are not lost on config reloads.
+type providerControl struct {
+	mux      sync.Mutex
+	disabled bool
+	draining bool
+	// priority overrides config priority if positive.
+	priority int
+	inFlight int
+}
+
+// managedProvider is a provider whose state and
+// priority can be changed at runtime.
+// Disabled and draining providers are not ready
+// so that new sends are not routed to them.
+type managedProvider struct {
+	sys.Provider
+	ctl *providerControl
+}
+
+// Priority returns the runtime priority, if set,
+// or the provider one.
+func (p *managedProvider) Priority() int {
+	p.ctl.mux.Lock()
+	defer p.ctl.mux.Unlock()
+
+	if p.ctl.priority > 0 {
+		return p.ctl.priority
+	}
+	return p.Provider.Priority()
+}
+
+// IsReady return true if the provider is enabled
+// and it can deliver messages.
+func (p *managedProvider) IsReady() bool {
+	p.ctl.mux.Lock()
+	enabled := !p.ctl.disabled && !p.ctl.draining
+	p.ctl.mux.Unlock()
+
+	return enabled && p.Provider.IsReady()
+}
+
+// Send an email counting it as in flight until it is over.
+func (p *managedProvider) Send(em *model.Email) (resend bool, err error) {
+	p.ctl.mux.Lock()
+	p.ctl.inFlight++
+	p.ctl.mux.Unlock()
+
+	defer func() {
+		p.ctl.mux.Lock()
+		p.ctl.inFlight--
+		p.ctl.mux.Unlock()
+	}()
+
+	return p.Provider.Send(em)
+}
+
+// Enable puts the provider in ready state.
+func (p *managedProvider) Enable() {
+	p.ctl.mux.Lock()
+	defer p.ctl.mux.Unlock()
+
+	p.ctl.disabled = false
+	p.ctl.draining = false
+}
+
+// Disable puts the provider in not-ready state.
+func (p *managedProvider) Disable() {
+	p.ctl.mux.Lock()
+	defer p.ctl.mux.Unlock()
+
+	p.ctl.disabled = true
+	p.ctl.draining = false
+}
+
+// Drain puts the provider in not-ready state,
+// it is drained once its sends in flight are over.
+func (p *managedProvider) Drain() {
+	p.ctl.mux.Lock()
+	defer p.ctl.mux.Unlock()
+
+	p.ctl.disabled = false
+	p.ctl.draining = true
+}
+
+// SetPriority overrides the provider priority,
+// zero restores the provider one.
+func (p *managedProvider) SetPriority(priority int) {
+	p.ctl.mux.Lock()
+	defer p.ctl.mux.Unlock()
+
+	p.ctl.priority = priority
+}
+
+// State returns the provider admin state
+// and its number of sends in flight.
+func (p *managedProvider) State() (state string, inFlight int) {
+	p.ctl.mux.Lock()
+	defer p.ctl.mux.Unlock()
+
+	switch {
+	case p.ctl.disabled:
+		state = providerDisabled
+	case p.ctl.draining && p.ctl.inFlight > 0:
+		state = providerDraining
+	case p.ctl.draining:
+		state = providerDrained
+	default:
+		state = providerEnabled
+	}
+	return state, p.ctl.inFlight
+}
+
+// manage returns providers wrapped with their runtime controls.
+// Service mutex must be held.
+func (s *service) manage(ps []sys.Provider) []sys.Provider {
+	if s.controls == nil {
+		s.controls = make(map[string]*providerControl)
+	}
+
+	mps := make([]sys.Provider, 0, len(ps))
+	for _, p := range ps {
+		ctl, ok := s.controls[p.Name()]
+		if !ok {
+			ctl = &providerControl{}
+			s.controls[p.Name()] = ctl
+		}
+		mps = append(mps, &managedProvider{Provider: p, ctl: ctl})
+	}
+	return mps
+}
+
+// managedProvider returns a service provider by name.
+func (s *service) managedProvider(name string) (*managedProvider, bool) {
+	for _, p := range s.Providers() {
+		if mp, ok := p.(*managedProvider); ok && mp.Name() == name {
+			return mp, true
+		}
+	}
+	return nil, false
+}
+
+// failureCount returns the consecutive failures of a provider.
+func (s *service) failureCount(name string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if pf, ok := s.failures[name]; ok {
+		return pf.count
+	}
+	return 0
+}
+
+type providerBody struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	// State is enabled, disabled, draining or drained.
+	State    string `json:"state"`
+	InFlight int    `json:"inFlight"`
+	// Ready is true if the provider can deliver messages.
+	Ready bool `json:"ready"`
+	// Available is true if it is ready and
+	// it has not failed repeatedly.
+	Available bool `json:"available"`
+	Failures  int  `json:"failures"`
+	// Config is nil for providers not built
+	// from config, secrets are masked.
+	Config *c.ProviderConfig `json:"config,omitempty"`
+}
+
+type providersBody struct {
+	Providers []providerBody `json:"providers"`
+}
+
+type priorityBody struct {
+	Priority int `json:"priority"`
+}
+
+// providerBody returns the state of a provider.
+func (s *service) providerBody(p *managedProvider) providerBody {
+	state, inFlight := p.State()
+
+	pb := providerBody{
+		Name:      p.Name(),
+		Priority:  p.Priority(),
+		State:     state,
+		InFlight:  inFlight,
+		Ready:     p.Provider.IsReady(),
+		Available: s.isAvailable(p),
+		Failures:  s.failureCount(p.Name()),
+	}
+
+	if pc, ok := s.Config().Mailer.ProviderByName(p.Name()); ok {
+		masked := pc.Masked()
+		pb.Config = &masked
+	}
+
+	return pb
+}
+
+// providersHandler lists service providers and lets
+// enable, disable, drain and reprioritize them.
+// Changes are kept until the service is restarted.
+func providersHandler(svc *service) http.Handler {
+	r := mux.NewRouter()
+	r.NotFoundHandler = notFoundHandler()
+	r.MethodNotAllowedHandler = methodNotAllowedHandler()
+
+	r.Methods(http.MethodGet).Path("/providers").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := providersBody{Providers: make([]providerBody, 0)}
+		for _, p := range svc.ProvidersByPriority() {
+			if mp, ok := p.(*managedProvider); ok {
+				body.Providers = append(body.Providers, svc.providerBody(mp))
+			}
+		}
+		encodeResponse(r.Context(), w, body)
+	})
+
+	r.Methods(http.MethodGet).Path("/providers/{name}").Handler(providerHandler(svc, nil))
+
+	actions := map[string]func(*managedProvider, *http.Request) error{
+		"enable":  func(p *managedProvider, _ *http.Request) error { p.Enable(); return nil },
+		"disable": func(p *managedProvider, _ *http.Request) error { p.Disable(); return nil },
+		"drain":   func(p *managedProvider, _ *http.Request) error { p.Drain(); return nil },
+	}
+	for name, action := range actions {
+		r.Methods(http.MethodPost).Path("/providers/{name}/" + name).Handler(providerHandler(svc, action))
+	}
+
+	r.Methods(http.MethodPut).Path("/providers/{name}/priority").Handler(providerHandler(svc, setPriority))
+
+	return adminAuth(svc, r)
+}
+
+// providerHandler applies an action, if any, to the provider
+// in path and returns its state.
+func providerHandler(svc *service, action func(*managedProvider, *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		p, ok := svc.managedProvider(name)
+		if !ok {
+			encodeError(r.Context(), wrapError(errNotFound, fmt.Errorf("provider '%s' not found", name)), w)
+			return
+		}
+
+		if action != nil {
+			if err := action(p, r); err != nil {
+				encodeError(r.Context(), err, w)
+				return
+			}
+
+			state, _ := p.State()
+			level.Warn(ctxLogger(r.Context(), svc.logger)).Log(
+				"package", "mailer",
+				"method", "providerHandler",
+				"message", "Provider changed.",
+				"provider", name,
+				"state", state,
+				"priority", p.Priority(),
+			)
+		}
+
+		encodeResponse(r.Context(), w, svc.providerBody(p))
+	})
+}
+
+// setPriority sets the provider priority of the request body.
+func setPriority(p *managedProvider, r *http.Request) error {
+	var body priorityBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return wrapError(errInvalidRequest, err)
+	}
+
+	if body.Priority < 0 {
+		return wrapError(errInvalidRequest, errors.New("priority must not be negative"))
+	}
+
+	p.SetPriority(body.Priority)
+	return nil
+}
+
+// adminAuth lets only admin clients, signed in
+// with their bearer token, use the handler.
+func adminAuth(svc *service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := userDataToContext(r.Context(), r)
+
+		token, ok := AuthToken(ctx)
+		if !ok || svc.auth.ValidateToken(token) != nil {
+			encodeError(ctx, errInvalidToken, w)
+			return
+		}
+
+		if !svc.Config().App.IsAdmin(clientIDFromContext(ctx)) {
+			encodeError(ctx, errForbidden, w)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIDFromContext returns the signed in client ID, if any.
+func clientIDFromContext(ctx context.Context) string {
+	ud, _ := ctx.Value(userDataCtxKey).(map[string]string)
+	return ud["clientID"]
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/mailer/mailertest"
+	"github.com/adrianpk/poslan/pkg/model"
+)
+
+// TestManagedProvider checks a draining provider
+// is drained once its sends in flight are over.
+func TestManagedProvider(t *testing.T) {
+	t.Parallel()
+
+	p := &managedProvider{Provider: mailertest.NewProvider("managed", 2), ctl: &providerControl{}}
+	p.Provider.(*mailertest.Provider).SetLatency(200 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		p.Send(&model.Email{To: "to@example.com"})
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	p.Drain()
+	if state, inFlight := p.State(); state != providerDraining || inFlight != 1 || p.IsReady() {
+		t.Errorf("Expected: draining, 1, not ready | Received: %s, %d, %t", state, inFlight, p.IsReady())
+	}
+
+	<-done
+	if state, inFlight := p.State(); state != providerDrained || inFlight != 0 {
+		t.Errorf("Expected: drained, 0 | Received: %s, %d", state, inFlight)
+	}
+
+	p.SetPriority(1)
+	p.Enable()
+	if state, _ := p.State(); state != providerEnabled || p.Priority() != 1 || !p.IsReady() {
+		t.Errorf("Expected: enabled, 1, ready | Received: %s, %d, %t", state, p.Priority(), p.IsReady())
+	}
+}
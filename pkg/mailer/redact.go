@@ -0,0 +1,184 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	redactedValue = "[REDACTED]"
+	// maxTruncatedLength is the number of characters
+	// kept of truncated contents.
+	maxTruncatedLength = 16
+	// hashLength is the number of hex digits kept of hashed addresses.
+	hashLength = 12
+)
+
+// Log keys whose values are redacted.
+// Keys are compared in lower case.
+var (
+	secretLogKeys = map[string]bool{
+		"secret":        true,
+		"token":         true,
+		"password":      true,
+		"authorization": true,
+		"apikey":        true,
+	}
+
+	addressLogKeys = map[string]bool{
+		"to":      true,
+		"cc":      true,
+		"bcc":     true,
+		"from":    true,
+		"replyto": true,
+		"email":   true,
+	}
+
+	contentLogKeys = map[string]bool{
+		"subject": true,
+		"body":    true,
+	}
+)
+
+// embeddedAddress matches email addresses embedded
+// in other values (i.e.: provider errors and responses).
+var embeddedAddress = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redactingLogger masks secrets and tokens and redacts
+// email addresses and contents according to its privacy level.
+// Values are identified by their log key so that every
+// logger derived from it is redacted the same way.
+// Addresses found in string and error values of other keys
+// (i.e.: provider errors and results) are also redacted,
+// the rest of their content is logged as is.
+type redactingLogger struct {
+	next    log.Logger
+	privacy string
+}
+
+// newRedactingLogger returns a logger redacting values
+// with a privacy level: none, truncate or hash.
+func newRedactingLogger(next log.Logger, privacy string) (log.Logger, error) {
+	switch privacy {
+	case string(c.PrivacyLevel.None), string(c.PrivacyLevel.Truncate), string(c.PrivacyLevel.Hash):
+	default:
+		return nil, fmt.Errorf("unknown log privacy level '%s'", privacy)
+	}
+
+	return &redactingLogger{next: next, privacy: privacy}, nil
+}
+
+// Log redacts the values of known keys.
+func (l *redactingLogger) Log(keyvals ...interface{}) error {
+	kvs := make([]interface{}, len(keyvals))
+	copy(kvs, keyvals)
+
+	for i := 0; i+1 < len(kvs); i += 2 {
+		key := strings.ToLower(fmt.Sprint(kvs[i]))
+
+		switch {
+		case secretLogKeys[key]:
+			kvs[i+1] = redactedValue
+		case addressLogKeys[key]:
+			kvs[i+1] = l.addresses(fmt.Sprint(kvs[i+1]))
+		case contentLogKeys[key]:
+			kvs[i+1] = l.content(fmt.Sprint(kvs[i+1]))
+		default:
+			kvs[i+1] = l.embedded(kvs[i+1])
+		}
+	}
+
+	return l.next.Log(kvs...)
+}
+
+// addresses redacts a comma separated list of addresses.
+func (l *redactingLogger) addresses(list string) string {
+	if l.privacy == string(c.PrivacyLevel.None) || strings.TrimSpace(list) == "" {
+		return list
+	}
+
+	var rs []string
+	for _, a := range strings.Split(list, ",") {
+		addr := strings.TrimSpace(a)
+		if pa, err := mail.ParseAddress(addr); err == nil {
+			addr = pa.Address
+		}
+		rs = append(rs, l.address(addr))
+	}
+	return strings.Join(rs, ", ")
+}
+
+// embedded redacts the addresses found in
+// string and error values, other values are kept.
+func (l *redactingLogger) embedded(v interface{}) interface{} {
+	if l.privacy == string(c.PrivacyLevel.None) {
+		return v
+	}
+
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case error:
+		s = t.Error()
+	default:
+		return v
+	}
+
+	if !embeddedAddress.MatchString(s) {
+		return v
+	}
+	return embeddedAddress.ReplaceAllStringFunc(s, l.address)
+}
+
+// address keeps the domain of an address, the local part
+// is truncated to its first character or hashed.
+func (l *redactingLogger) address(addr string) string {
+	at := strings.LastIndex(addr, "@")
+	if at < 1 {
+		return l.hash(addr)
+	}
+
+	local, domain := addr[:at], addr[at:]
+	if l.privacy == string(c.PrivacyLevel.Truncate) {
+		r, _ := utf8.DecodeRuneInString(local)
+		return string(r) + "***" + domain
+	}
+	return l.hash(local) + domain
+}
+
+// content truncates or omits contents, keeping their length.
+func (l *redactingLogger) content(s string) string {
+	switch {
+	case l.privacy == string(c.PrivacyLevel.None) || s == "":
+		return s
+	case l.privacy == string(c.PrivacyLevel.Truncate) && utf8.RuneCountInString(s) <= maxTruncatedLength:
+		return s
+	case l.privacy == string(c.PrivacyLevel.Truncate):
+		return fmt.Sprintf("%s... (%d bytes)", string([]rune(s)[:maxTruncatedLength]), len(s))
+	default:
+		return fmt.Sprintf("(%d bytes)", len(s))
+	}
+}
+
+// hash returns a short hash so that redacted
+// values can still be correlated.
+func (l *redactingLogger) hash(s string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(s)))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	kitlog "github.com/go-kit/kit/log"
+)
+
+// TestRedactingLoggerEmbedded checks that addresses found
+// in provider errors and results are redacted.
+func TestRedactingLoggerEmbedded(t *testing.T) {
+	providerErr := errors.New("cannot send email - recipient 'jane@example.com' rejected: 550 mailbox unavailable")
+
+	tests := []struct {
+		privacy string
+		key     string
+		value   interface{}
+		logged  interface{}
+	}{
+		{"hash", "err", providerErr, "cannot send email - recipient '81f8f6dde883@example.com' rejected: 550 mailbox unavailable"},
+		{"truncate", "error", providerErr.Error(), "cannot send email - recipient 'j***@example.com' rejected: 550 mailbox unavailable"},
+		{"hash", "result", `{"id":"<1@mg.example.com>","message":"Queued. Thank you."}`, `{"id":"<6b86b273ff34@mg.example.com>","message":"Queued. Thank you."}`},
+		{"none", "err", providerErr, providerErr.Error()},
+		{"hash", "result", "202 Accepted", "202 Accepted"},
+		{"hash", "attempts", 2, float64(2)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger, err := newRedactingLogger(kitlog.NewJSONLogger(&buf), tt.privacy)
+		if err != nil {
+			t.Fatalf("Expected: no error | Received: %s", err.Error())
+		}
+
+		logger.Log(tt.key, tt.value)
+
+		var entry map[string]interface{}
+		json.Unmarshal(buf.Bytes(), &entry)
+
+		if entry[tt.key] != tt.logged {
+			t.Errorf("%s %s - Expected: '%v' | Received: '%v'", tt.privacy, tt.key, tt.logged, entry[tt.key])
+		}
+	}
+}
+
+// TestRedactingLogger checks that secrets are always masked
+// and addresses and contents redacted by privacy level.
+func TestRedactingLogger(t *testing.T) {
+	body := "Dear Jane, your code is 123456."
+
+	tests := []struct {
+		privacy string
+		key     string
+		value   string
+		logged  string
+	}{
+		{"none", "secret", "a5ee54c8", redactedValue},
+		{"none", "Authorization", "Bearer token", redactedValue},
+		{"none", "to", "Jane <jane@example.com>", "Jane <jane@example.com>"},
+		{"none", "body", body, body},
+		{"truncate", "token", "eyJhbGciOi", redactedValue},
+		{"truncate", "to", "Jane <jane@example.com>, john@example.org", "j***@example.com, j***@example.org"},
+		{"truncate", "subject", "Welcome", "Welcome"},
+		{"truncate", "body", body, "Dear Jane, your ... (31 bytes)"},
+		{"hash", "cc", "jane@example.com", "81f8f6dde883@example.com"},
+		{"hash", "bcc", "Jane@Example.com", "81f8f6dde883@Example.com"},
+		{"hash", "body", body, "(31 bytes)"},
+		{"hash", "method", "Send", "Send"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger, err := newRedactingLogger(kitlog.NewJSONLogger(&buf), tt.privacy)
+		if err != nil {
+			t.Fatalf("Expected: no error | Received: %s", err.Error())
+		}
+
+		logger.Log(tt.key, tt.value)
+
+		var entry map[string]string
+		json.Unmarshal(buf.Bytes(), &entry)
+
+		if entry[tt.key] != tt.logged {
+			t.Errorf("%s %s - Expected: '%s' | Received: '%s'", tt.privacy, tt.key, tt.logged, entry[tt.key])
+		}
+	}
+
+	if _, err := newRedactingLogger(kitlog.NewNopLogger(), "partial"); err == nil {
+		t.Error("Expected: unknown privacy level error | Received: no error")
+	}
+}
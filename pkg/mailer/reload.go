@@ -0,0 +1,205 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Config reload results.
+const (
+	reloadSuccess = "success"
+	reloadFailure = "failure"
+)
+
+// Config reload triggers.
+const (
+	reloadOnSignal = "signal"
+	reloadOnChange = "file"
+)
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchConfig reloads config on SIGHUP and when
+// the config file changes until ctx is done.
+func watchConfig(ctx context.Context, svc *service, path string) {
+	path = c.FilePath(path)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	// Startup config load counts as a successful one.
+	reloads.LastSuccessful.Set(1)
+	reloads.LastSuccess.Set(float64(time.Now().Unix()))
+
+	last, _ := statFile(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			svc.reloadConfig(path, reloadOnSignal)
+		case <-ticker.C:
+			// Files being replaced can be briefly missing,
+			// they are checked again on next tick.
+			st, ok := statFile(path)
+			if !ok || st == last {
+				continue
+			}
+			last = st
+			svc.reloadConfig(path, reloadOnChange)
+		}
+	}
+}
+
+// statFile returns the stamp of a file
+// or false if there is no such file.
+func statFile(path string) (fileStamp, bool) {
+	if path == "" {
+		return fileStamp{}, false
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, true
+}
+
+// reloadConfig loads and validates config and applies it.
+// Current config is kept if the new one is not valid
+// or its providers cannot be initialized.
+func (s *service) reloadConfig(path, trigger string) error {
+	cfg, err := c.Load(s.logger, path)
+	if err == nil {
+		err = s.reload(cfg)
+	}
+
+	if err != nil {
+		reloads.Reloads.With("result", reloadFailure).Add(1)
+		reloads.LastSuccessful.Set(0)
+
+		if ve, ok := err.(c.ValidationError); ok {
+			for _, p := range ve.Problems {
+				level.Error(s.logger).Log("message", "Invalid config.", "problem", p)
+			}
+		}
+		level.Error(s.logger).Log(
+			"package", "mailer",
+			"method", "reloadConfig",
+			"message", "Config not reloaded, current one is kept.",
+			"trigger", trigger,
+			"error", err.Error(),
+		)
+		return err
+	}
+
+	reloads.Reloads.With("result", reloadSuccess).Add(1)
+	reloads.LastSuccessful.Set(1)
+	reloads.LastSuccess.Set(float64(time.Now().Unix()))
+
+	level.Info(s.logger).Log(
+		"package", "mailer",
+		"method", "reloadConfig",
+		"message", "Config reloaded.",
+		"trigger", trigger,
+		"providers", len(s.Providers()),
+		"logLevel", cfg.App.LogLevel,
+	)
+	return nil
+}
+
+// reload swaps service config and providers.
+// New providers are started before the swap, replaced ones are
+// stopped once the sends in progress on them are over.
+// Provider policies (priority, dry-run routing) and log level
+// are applied; settings used at startup only keep their values
+// until the service is restarted.
+func (s *service) reload(cfg *c.Config) error {
+	ps, ok := initProviders(s, cfg)
+	if !ok {
+		return errors.New("cannot initialize providers")
+	}
+	startProviders(s.logger, ps)
+
+	s.mux.Lock()
+	prev := s.cfg
+	// Config providers come first, external ones last.
+	replaced := s.providers[:len(s.providers)-len(s.external)]
+	sends := s.sends
+
+	s.cfg = cfg
+	s.providers = s.manage(append(ps, s.external...))
+	s.sends = &sync.WaitGroup{}
+	s.failures = nil
+	s.mux.Unlock()
+
+	go func() {
+		sends.Wait()
+		for _, p := range replaced {
+			p.Stop()
+		}
+	}()
+
+	if l, ok := s.logger.(*leveledLogger); ok {
+		if err := l.SetLevel(string(cfg.App.LogLevel)); err != nil {
+			level.Warn(s.logger).Log("message", err.Error())
+		}
+	}
+
+	warnRestartOnly(s, prev, cfg)
+	return nil
+}
+
+// warnRestartOnly logs changed settings
+// that are only applied on restart.
+func warnRestartOnly(s *service, prev, cfg *c.Config) {
+	pa, ca := prev.App, cfg.App
+	changes := []struct {
+		key     string
+		changed bool
+	}{
+		{"serverPort", pa.ServerPort != ca.ServerPort},
+		{"grpcPort", pa.GRPCPort != ca.GRPCPort},
+		{"adminPort", pa.AdminPort != ca.AdminPort},
+		{"logFormat", pa.LogFormat != ca.LogFormat},
+		{"logPrivacy", pa.LogPrivacy != ca.LogPrivacy},
+		{"smtp", pa.SMTP != ca.SMTP},
+		{"scheduleDir", pa.ScheduleDir != ca.ScheduleDir},
+		{"idempotencyTTL", pa.IdempotencyTTL != ca.IdempotencyTTL},
+		{"tracing", pa.Tracing != ca.Tracing},
+	}
+
+	for _, ch := range changes {
+		if ch.changed {
+			level.Warn(s.logger).Log(
+				"package", "mailer",
+				"method", "reload",
+				"message", "Config change is only applied on restart.",
+				"key", ch.key,
+			)
+		}
+	}
+}
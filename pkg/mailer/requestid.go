@@ -0,0 +1,105 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// maxRequestIDLength is the max length of
+	// request IDs accepted from callers.
+	maxRequestIDLength = 128
+	// requestIDTag is set on server spans.
+	requestIDTag = "request.id"
+)
+
+var (
+	requestIDCtxKey = contextKey("request-id")
+)
+
+// withRequestID returns a context carrying the request ID,
+// also included in its request scoped log fields.
+func withRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDCtxKey, id)
+	return withLogFields(ctx, "requestID", id)
+}
+
+// requestIDFromContext returns the request ID
+// of the context, if any.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey).(string)
+	return id
+}
+
+// requestIDOrNew returns the request ID provided by the caller
+// or, if it is missing or not valid, a new one.
+func requestIDOrNew(id string) string {
+	if validRequestID(id) {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// validRequestID is true if the ID is not too long and it only
+// has alphanumeric ASCII characters, '_', '-' and '.'
+// so that it can be forwarded to any provider as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	return strings.IndexFunc(id, func(r rune) bool {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return false
+		default:
+			return true
+		}
+	}) < 0
+}
+
+// requestIDHTTP accepts the caller request ID or generates one,
+// stores it in request context and echoes it in the response.
+func requestIDHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestIDOrNew(r.Header.Get(requestIDHeader))
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(withRequestID(r.Context(), id)))
+	})
+}
+
+// requestIDFromMetadata accepts the caller request ID
+// from gRPC metadata or generates one and stores it in context.
+func requestIDFromMetadata(ctx context.Context, md metadata.MD) context.Context {
+	var id string
+	if vs := md.Get(requestIDHeader); len(vs) > 0 {
+		id = vs[0]
+	}
+	return withRequestID(ctx, requestIDOrNew(id))
+}
+
+// requestIDToMetadata echoes the request ID in gRPC response header.
+func requestIDToMetadata(ctx context.Context, header *metadata.MD, _ *metadata.MD) context.Context {
+	id := requestIDFromContext(ctx)
+	if id == "" {
+		return ctx
+	}
+
+	if *header == nil {
+		*header = metadata.MD{}
+	}
+	header.Set(requestIDHeader, id)
+	return ctx
+}
@@ -0,0 +1,363 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledExt = ".json"
+	// releaseWorkers is the max number of
+	// messages released at the same time.
+	releaseWorkers = 8
+	// lockName is the file locked in the schedule dir
+	// by the process that changes its messages.
+	lockName = ".lock"
+)
+
+var errDirLocked = errors.New("schedule dir is in use by a running service, use its API to cancel or reschedule messages")
+
+// scheduledMessage is a message waiting to be sent.
+type scheduledMessage struct {
+	ClientID  string       `json:"clientID"`
+	Email     *model.Email `json:"email"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// scheduler keeps messages until their send time.
+// If dir is set each message is also stored there
+// as a JSON file so that they survive restarts.
+// Files are removed once messages are released,
+// so a message released when the service stops
+// can be sent again after restart.
+type scheduler struct {
+	mux  sync.Mutex
+	dir  string
+	msgs map[uuid.UUID]*scheduledMessage
+	wake chan struct{}
+}
+
+// newScheduler returns a scheduler loading
+// the messages stored in dir, if any.
+func newScheduler(dir string) (*scheduler, error) {
+	s := &scheduler{
+		dir:  dir,
+		msgs: make(map[uuid.UUID]*scheduledMessage),
+		wake: make(chan struct{}, 1),
+	}
+
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fis {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), scheduledExt) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var sm scheduledMessage
+		if err := json.Unmarshal(data, &sm); err != nil {
+			return nil, err
+		}
+
+		s.msgs[sm.Email.ID] = &sm
+	}
+
+	return s, nil
+}
+
+// Add schedules a message.
+func (s *scheduler) Add(sm scheduledMessage) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.store(&sm); err != nil {
+		return err
+	}
+
+	s.msgs[sm.Email.ID] = &sm
+	s.notify()
+	return nil
+}
+
+// Cancel removes a message not yet released.
+// It returns false if there is no such message.
+func (s *scheduler) Cancel(id uuid.UUID) (ok bool, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.msgs[id]; !ok {
+		return false, nil
+	}
+
+	if err := s.remove(id); err != nil {
+		return true, err
+	}
+
+	delete(s.msgs, id)
+	return true, nil
+}
+
+// Reschedule changes the send time of a message not yet released.
+// It returns false if there is no such message.
+func (s *scheduler) Reschedule(id uuid.UUID, sendAt time.Time) (ok bool, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sm, ok := s.msgs[id]
+	if !ok {
+		return false, nil
+	}
+
+	rsm := *sm
+	e := *sm.Email
+	e.SendAt = sendAt
+	rsm.Email = &e
+
+	if err := s.store(&rsm); err != nil {
+		return true, err
+	}
+
+	s.msgs[id] = &rsm
+	s.notify()
+	return true, nil
+}
+
+// Get returns a copy of a message not yet released.
+func (s *scheduler) Get(id uuid.UUID) (sm scheduledMessage, ok bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	m, ok := s.msgs[id]
+	if !ok {
+		return scheduledMessage{}, false
+	}
+	return *m, true
+}
+
+// Messages returns a copy of the scheduled messages.
+func (s *scheduler) Messages() []scheduledMessage {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sms := make([]scheduledMessage, 0, len(s.msgs))
+	for _, sm := range s.msgs {
+		sms = append(sms, *sm)
+	}
+	return sms
+}
+
+// Stats returns the number of scheduled messages
+// and how long ago was due the oldest one not yet released.
+func (s *scheduler) Stats(now time.Time) (depth int, age time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, sm := range s.msgs {
+		if late := now.Sub(sm.Email.SendAt); late > age {
+			age = late
+		}
+	}
+	return len(s.msgs), age
+}
+
+// Run releases messages at their send time
+// until the context is done.
+// Up to releaseWorkers messages are released at a time so
+// that a slow provider does not hold back the rest of them.
+// Due messages wait in the schedule, where they can still be
+// canceled or rescheduled, until a worker is free.
+func (s *scheduler) Run(ctx context.Context, logger log.Logger, release func(sm scheduledMessage)) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	workers := make(chan struct{}, releaseWorkers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		for _, sm := range s.due(time.Now(), cap(workers)-len(workers)) {
+			workers <- struct{}{}
+			wg.Add(1)
+
+			go func(sm scheduledMessage) {
+				defer wg.Done()
+				release(sm)
+				s.released(logger, sm.Email.ID)
+				<-workers
+				s.notify()
+			}(sm)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		// Released workers wake Run up when all of them are busy.
+		if len(workers) < cap(workers) {
+			timer.Reset(s.next(time.Now()))
+		}
+	}
+}
+
+// released removes the stored file of a released message.
+// If it cannot be removed the message is sent again after restart.
+func (s *scheduler) released(logger log.Logger, id uuid.UUID) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.remove(id); err != nil {
+		level.Error(logger).Log(
+			"package", "mailer",
+			"method", "Run",
+			"message", "Cannot remove released message, it will be sent again after restart.",
+			"messageID", id,
+			"error", err.Error(),
+		)
+	}
+}
+
+// due removes and returns up to max messages
+// whose send time is not after now.
+func (s *scheduler) due(now time.Time, max int) []scheduledMessage {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var sms []scheduledMessage
+	for id, sm := range s.msgs {
+		if len(sms) >= max {
+			break
+		}
+		if !sm.Email.SendAt.After(now) {
+			sms = append(sms, *sm)
+			delete(s.msgs, id)
+		}
+	}
+	return sms
+}
+
+// next returns the time until the next send time.
+func (s *scheduler) next(now time.Time) time.Duration {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	// Messages are also checked periodically
+	// in case the system clock changes.
+	d := time.Minute
+	for _, sm := range s.msgs {
+		if until := sm.Email.SendAt.Sub(now); until < d {
+			d = until
+		}
+	}
+
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// notify wakes up Run to recompute the next send time.
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) store(sm *scheduledMessage) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(sm)
+	if err != nil {
+		return err
+	}
+
+	// Written to a temp file and renamed
+	// so that a message file is never partial.
+	tmp := s.path(sm.Email.ID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(sm.Email.ID))
+}
+
+func (s *scheduler) remove(id uuid.UUID) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *scheduler) path(id uuid.UUID) string {
+	return filepath.Join(s.dir, id.String()+scheduledExt)
+}
+
+// lockDir locks the schedule dir so that only one process
+// changes its messages. The lock is released on close.
+func lockDir(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, lockName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, errDirLocked
+	}
+
+	return f, nil
+}
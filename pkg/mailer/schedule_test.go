@@ -0,0 +1,195 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+)
+
+// TestCancelEvicted checks that scheduled messages can be canceled
+// and rescheduled after the status store fills up.
+func TestCancelEvicted(t *testing.T) {
+	svc := makeService(context.Background(), nil, kitlog.NewNopLogger())
+
+	sch, err := newScheduler("")
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+	svc.scheduler = sch
+
+	later := time.Now().Add(time.Hour)
+	kept := scheduledMessage{ClientID: clientID, Email: &model.Email{ID: uuid.New(), SendAt: later}}
+	sch.Add(kept)
+	svc.statuses.Put(scheduledStatus(kept))
+
+	// Its status is not stored at all.
+	unstored := scheduledMessage{ClientID: clientID, Email: &model.Email{ID: uuid.New(), SendAt: later}}
+	sch.Add(unstored)
+
+	for i := 0; i <= maxStatuses; i++ {
+		svc.statuses.Put(model.MessageStatus{ID: uuid.New(), ClientID: clientID, Status: model.StatusSent})
+	}
+
+	if _, ok := svc.statuses.Get(kept.Email.ID); !ok {
+		t.Error("Expected: scheduled status kept | Received: evicted")
+	}
+
+	ctx := userContext()
+
+	st, err := svc.Cancel(ctx, kept.Email.ID)
+	if err != nil || st.Status != model.StatusCanceled {
+		t.Errorf("Expected: '%s' | Received: %+v %v", model.StatusCanceled, st, err)
+	}
+
+	sendAt := later.Add(time.Hour)
+	st, err = svc.Reschedule(ctx, unstored.Email.ID, sendAt)
+	if err != nil || st.Status != model.StatusScheduled || !st.SendAt.Equal(sendAt) {
+		t.Errorf("Expected: '%s' at %s | Received: %+v %v", model.StatusScheduled, sendAt, st, err)
+	}
+
+	// Other clients messages are not found.
+	other := context.WithValue(context.Background(), userDataCtxKey, map[string]string{"clientID": "other"})
+	if _, err := svc.Cancel(other, unstored.Email.ID); err != errMessageNotFound {
+		t.Errorf("Expected: %s | Received: %v", errMessageNotFound, err)
+	}
+}
+
+// TestScheduler checks scheduled messages
+// are stored and released at their send time.
+func TestScheduler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poslan-schedule")
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	sch, err := newScheduler(dir)
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	later := time.Now().Add(time.Hour)
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	for _, id := range ids {
+		sch.Add(scheduledMessage{ClientID: clientID, Email: &model.Email{ID: id, To: "to@example.com", SendAt: later}})
+	}
+
+	if ok, _ := sch.Cancel(ids[1]); !ok {
+		t.Errorf("Expected: canceled | Received: not found")
+	}
+
+	// A new scheduler loads stored messages.
+	sch, err = newScheduler(dir)
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	if depth, age := sch.Stats(later.Add(time.Minute)); depth != 1 || age != time.Minute {
+		t.Errorf("Expected: 1, 1m0s | Received: %d, %s", depth, age)
+	}
+
+	sms := sch.Messages()
+	if len(sms) != 1 || sms[0].Email.ID != ids[0] || !sms[0].Email.SendAt.Equal(later) {
+		t.Fatalf("Expected: message '%s' | Received: %+v", ids[0], sms)
+	}
+
+	released := make(chan scheduledMessage, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sch.Run(ctx, kitlog.NewNopLogger(), func(sm scheduledMessage) { released <- sm })
+
+	sch.Reschedule(ids[0], time.Now())
+
+	select {
+	case sm := <-released:
+		if sm.Email.ID != ids[0] {
+			t.Errorf("Expected: '%s' | Received: '%s'", ids[0], sm.Email.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected: message released | Received: none")
+	}
+
+	if ok, _ := sch.Cancel(ids[0]); ok {
+		t.Errorf("Expected: released message not found | Received: canceled")
+	}
+}
+
+// TestSchedulerWorkers checks that a hanging release does not
+// hold back other due messages nor cancellations.
+func TestSchedulerWorkers(t *testing.T) {
+	sch, err := newScheduler("")
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	hanging, due, pending := uuid.New(), uuid.New(), uuid.New()
+	sch.Add(scheduledMessage{ClientID: clientID, Email: &model.Email{ID: hanging, SendAt: time.Now()}})
+	sch.Add(scheduledMessage{ClientID: clientID, Email: &model.Email{ID: pending, SendAt: time.Now().Add(time.Hour)}})
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+	released := make(chan uuid.UUID, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sch.Run(ctx, kitlog.NewNopLogger(), func(sm scheduledMessage) {
+		released <- sm.Email.ID
+		if sm.Email.ID == hanging {
+			<-unblock
+		}
+	})
+
+	if id := <-released; id != hanging {
+		t.Fatalf("Expected: '%s' | Received: '%s'", hanging, id)
+	}
+
+	sch.Add(scheduledMessage{ClientID: clientID, Email: &model.Email{ID: due, SendAt: time.Now()}})
+
+	select {
+	case id := <-released:
+		if id != due {
+			t.Errorf("Expected: '%s' | Received: '%s'", due, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected: message released | Received: none")
+	}
+
+	if ok, err := sch.Cancel(pending); !ok || err != nil {
+		t.Errorf("Expected: canceled | Received: %t %v", ok, err)
+	}
+}
+
+// TestSchedulerRemoveError checks that released
+// messages that cannot be removed are logged.
+func TestSchedulerRemoveError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poslan-schedule")
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	sch, err := newScheduler(dir)
+	if err != nil {
+		t.Fatalf("[ERROR] %s", err.Error())
+	}
+
+	// A non empty dir in place of the message file cannot be removed.
+	id := uuid.New()
+	os.MkdirAll(filepath.Join(sch.path(id), "file"), 0700)
+
+	var buf bytes.Buffer
+	sch.released(kitlog.NewLogfmtLogger(&buf), id)
+
+	if !strings.Contains(buf.String(), "level=error") || !strings.Contains(buf.String(), id.String()) {
+		t.Errorf("Expected: error logged for '%s' | Received: '%s'", id, buf.String())
+	}
+}
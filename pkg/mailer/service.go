@@ -10,15 +10,22 @@ package mailer
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/adrianpk/poslan/internal/config"
 	"github.com/adrianpk/poslan/internal/sys"
 	"github.com/adrianpk/poslan/pkg/auth"
 	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
 	health "github.com/heptiolabs/healthcheck"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
 )
 
 type service struct {
@@ -29,6 +36,19 @@ type service struct {
 	logger    log.Logger
 	auth      auth.SecServer
 	providers []sys.Provider
+	// external providers are not built from config
+	// (i.e.: mailertest providers), reloads keep them.
+	external []sys.Provider
+	// sends in progress on current providers.
+	sends *sync.WaitGroup
+	// controls of providers by name.
+	controls  map[string]*providerControl
+	statuses  *statusStore
+	scheduler *scheduler
+	failures  map[string]*providerFailures
+	meters    deliveryMeters
+	tracer    *zipkin.Tracer
+	spans     reporter.Reporter
 	health    health.Handler
 	ready     bool
 	alive     bool
@@ -37,6 +57,9 @@ type service struct {
 // SignIn lets a user sign in providing username and password.
 func (s *service) SignIn(ctx context.Context, clientID, secret string) (string, error) {
 	output, err := s.auth.Authenticate(clientID, secret)
+	if err == auth.ErrWrongCredentials {
+		return "", errInvalidCredentials
+	}
 	if err != nil {
 		return "", err
 	}
@@ -46,70 +69,430 @@ func (s *service) SignIn(ctx context.Context, clientID, secret string) (string,
 // SignOut lets a user sign out.
 func (s *service) SignOut(ctx context.Context, id uuid.UUID) error {
 	// TODO: Close session implementation.
-	return errors.New("not implemented")
+	return errNotImplemented
 }
 
 // Send lets the user send a mail.
-func (s *service) Send(ctx context.Context, to, cc, bcc, subject, body string) error {
+// It returns the email as it was delivered to providers.
+func (s *service) Send(ctx context.Context, email *model.Email) (*model.Email, error) {
 	ud := (ctx.Value(userDataCtxKey)).(map[string]string)
-	fromName := ud["username"]
-	fromEmail := ud["email"]
 
-	e := makeEmail(fromName, fromEmail, to, cc, bcc, subject, body)
+	if email.To == "" && len(email.Personalizations) == 0 {
+		return nil, wrapError(errInvalidEmail, errors.New("no recipients"))
+	}
+
+	e := makeEmail(ud["username"], ud["email"], email)
+	e.RequestID = requestIDFromContext(ctx)
+	ctx = withLogFields(ctx, "messageID", e.ID)
+
+	if e.SendAt.After(time.Now()) {
+		return s.schedule(ud["clientID"], e)
+	}
+
+	err := s.deliver(ctx, ud["clientID"], e, []uuid.UUID{e.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
 
-	p1, ok := s.ProviderByPriority(1)
+// schedule stores an email to be sent at its send time.
+func (s *service) schedule(clientID string, e *model.Email) (*model.Email, error) {
+	sm := scheduledMessage{
+		ClientID:  clientID,
+		Email:     e,
+		CreatedAt: time.Now(),
+	}
+
+	err := s.scheduler.Add(sm)
+	if err != nil {
+		return nil, err
+	}
+
+	s.statuses.Put(scheduledStatus(sm))
+	return e, nil
+}
+
+// release sends a scheduled message.
+// Delivery errors are recorded in message status.
+func (s *service) release(sm scheduledMessage) {
+	ctx := withLogFields(s.ctx, "clientID", sm.ClientID, "messageID", sm.Email.ID)
+	if sm.Email.RequestID != "" {
+		ctx = withRequestID(ctx, sm.Email.RequestID)
+	}
+	span, ctx := s.tracer.StartSpanFromContext(ctx, "Release")
+	err := s.deliver(ctx, sm.ClientID, sm.Email, []uuid.UUID{sm.Email.ID})
+	finishSpan(span, err)
+}
+
+// Cancel cancels a scheduled message
+// sent by the signed in client.
+func (s *service) Cancel(ctx context.Context, id uuid.UUID) (*model.MessageStatus, error) {
+	st, err := s.scheduledStatus(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.scheduler.Cancel(id)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
-		return errors.New("no providers configured")
+		return nil, wrapError(errConflict, errors.New("message is not scheduled"))
 	}
 
-	resend, err := p1.Send(e)
+	st.Status = model.StatusCanceled
+	s.statuses.Put(*st)
+
+	return s.Message(ctx, id)
+}
 
+// Reschedule changes the send time of a scheduled
+// message sent by the signed in client.
+func (s *service) Reschedule(ctx context.Context, id uuid.UUID, sendAt time.Time) (*model.MessageStatus, error) {
+	st, err := s.scheduledStatus(ctx, id)
 	if err != nil {
-		s.logger.Log(
-			"level", config.LogLevel.Error,
-			"package", "mailer",
-			"method", "Send",
-			"error", err.Error(),
-		)
+		return nil, err
 	}
 
-	p2, ok2 := s.ProviderByPriority(2)
+	ok, err := s.scheduler.Reschedule(id, sendAt)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, wrapError(errConflict, errors.New("message is not scheduled"))
+	}
+
+	st.SendAt = sendAt
+	s.statuses.Put(*st)
+
+	return s.Message(ctx, id)
+}
 
-	// Previous attempt failed and a second provider enabled.
-	if resend && ok2 {
-		resend, err = p2.Send(e)
+// scheduledStatus returns the status of a message
+// sent by the signed in client if it is scheduled.
+// Pending messages are read from the scheduler,
+// so they do not depend on their status being kept.
+func (s *service) scheduledStatus(ctx context.Context, id uuid.UUID) (*model.MessageStatus, error) {
+	ud, _ := ctx.Value(userDataCtxKey).(map[string]string)
+
+	sm, ok := s.scheduler.Get(id)
+	if ok {
+		if sm.ClientID != ud["clientID"] {
+			return nil, errMessageNotFound
+		}
+
+		st := scheduledStatus(sm)
+		return &st, nil
+	}
+
+	st, err := s.Message(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, wrapError(errConflict, fmt.Errorf("message is %s", st.Status))
+}
+
+// SendBatch lets the user send a mail to many recipients.
+// Each personalization is delivered as an individual message
+// with its own ID, all of them in a single provider request.
+// It returns the email as it was delivered to providers.
+// If only some messages were delivered the email is returned
+// along with a batchError listing the ones that were not.
+func (s *service) SendBatch(ctx context.Context, email *model.Email) (*model.Email, error) {
+	ud := (ctx.Value(userDataCtxKey)).(map[string]string)
+
+	if len(email.Personalizations) == 0 {
+		return nil, wrapError(errInvalidEmail, errors.New("no recipients"))
 	}
 
+	e := makeEmail(ud["username"], ud["email"], email)
+	e.RequestID = requestIDFromContext(ctx)
+
+	ps := make([]model.Personalization, len(email.Personalizations))
+	ids := make([]uuid.UUID, len(ps))
+	for i, p := range email.Personalizations {
+		p.ID = uuid.New()
+		ps[i] = p
+		ids[i] = p.ID
+	}
+	e.Personalizations = ps
+	ctx = withLogFields(ctx, "batchID", e.ID)
+
+	err := s.deliver(ctx, ud["clientID"], e, ids)
 	if err != nil {
-		s.logger.Log(
-			"level", config.LogLevel.Error,
+		failed := make(map[uuid.UUID]string)
+		for _, id := range ids {
+			st, ok := s.statuses.Get(id)
+			if ok && st.Status == model.StatusFailed {
+				failed[id] = st.Error
+			}
+		}
+
+		if len(failed) == len(ids) {
+			return nil, err
+		}
+		return e, &batchError{failed: failed, cause: err}
+	}
+
+	return e, nil
+}
+
+// deliver sends an email through the first provider that accepts it
+// and records the delivery status of each one of the message IDs.
+// Each provider attempt is traced as a child span.
+func (s *service) deliver(ctx context.Context, clientID string, e *model.Email, ids []uuid.UUID) error {
+	cfg, providers, done := s.acquire()
+	defer done()
+
+	// Dry-run sends are only delivered to test-only providers
+	// so that they never reach a real mail provider.
+	dryRun := cfg.App.IsDryRun(clientID)
+
+	ps := routeProviders(cfg, providers, dryRun)
+	if len(ps) < 1 {
+		if dryRun {
+			return wrapError(errDeliveryFailed, errors.New("dry-run: no test-only providers available"))
+		}
+		return wrapError(errDeliveryFailed, errors.New("no providers available"))
+	}
+
+	st := model.MessageStatus{
+		ClientID:  clientID,
+		SendAt:    e.SendAt,
+		RequestID: e.RequestID,
+		CreatedAt: time.Now(),
+	}
+
+	// Try each provider in priority order
+	// until one of them does not ask for a resend.
+	// Personalizations delivered by a provider that fails
+	// on the rest of them are not sent again.
+	var err error
+	for i, p := range ps {
+		if i > 0 {
+			s.meters.Failovers.With("from", ps[i-1].Name(), "to", p.Name()).Add(1)
+		}
+
+		span, _ := s.tracer.StartSpanFromContext(ctx, "send "+p.Name(), zipkin.Kind(zipkinmodel.Client))
+		span.Tag(providerTag, p.Name())
+
+		var resend bool
+		begin := time.Now()
+		resend, err = p.Send(e)
+		st.Attempts++
+
+		s.meters.Attempts.With("provider", p.Name()).Add(1)
+		s.meters.Latency.With("provider", p.Name()).Observe(time.Since(begin).Seconds())
+		s.recordResult(p.Name(), err, resend)
+
+		if err == nil {
+			span.Tag(outcomeTag, model.StatusSent)
+			span.Finish()
+
+			s.meters.Successes.With("provider", p.Name()).Add(1)
+			st.Status = model.StatusSent
+			st.Provider = p.Name()
+			s.putStatuses(st, ids)
+			return nil
+		}
+
+		class := errorClass(err, resend)
+		span.Tag(outcomeTag, class)
+		finishSpan(span, err)
+
+		s.meters.Failures.With("provider", p.Name(), "class", class).Add(1)
+
+		level.Error(ctxLogger(ctx, s.logger)).Log(
 			"package", "mailer",
-			"method", "Send",
+			"method", "deliver",
+			"provider", p.Name(),
 			"error", err.Error(),
 		)
+
+		if pe, ok := err.(*model.PartialError); ok {
+			var sent []uuid.UUID
+			sent, ids = splitIDs(ids, pe.Sent, len(e.Personalizations))
+			e = e.Without(pe.Sent)
+
+			sst := st
+			sst.Status = model.StatusSent
+			sst.Provider = p.Name()
+			s.putStatuses(sst, sent)
+		}
+
+		if !resend {
+			break
+		}
+	}
+
+	st.Status = model.StatusFailed
+	st.Error = err.Error()
+	s.putStatuses(st, ids)
+
+	return wrapError(errDeliveryFailed, err)
+}
+
+// putStatuses records the same status for many messages
+// and counts them by client.
+func (s *service) putStatuses(st model.MessageStatus, ids []uuid.UUID) {
+	s.meters.ClientMessages.With("client", st.ClientID, "status", st.Status).Add(float64(len(ids)))
+	for _, id := range ids {
+		st.ID = id
+		s.statuses.Put(st)
+	}
+}
+
+// splitIDs splits the message IDs of an email with n personalizations
+// in the ones of the personalizations sent and the rest of them.
+// A single ID for all personalizations is kept until all of them are sent.
+func splitIDs(ids []uuid.UUID, sent []int, n int) (done, rest []uuid.UUID) {
+	if len(ids) != n {
+		return nil, ids
+	}
+
+	skip := make(map[int]bool, len(sent))
+	for _, i := range sent {
+		skip[i] = true
+		done = append(done, ids[i])
+	}
+
+	for i, id := range ids {
+		if !skip[i] {
+			rest = append(rest, id)
+		}
+	}
+
+	return done, rest
+}
+
+// Message returns the delivery status of a message
+// sent by the signed in client.
+func (s *service) Message(ctx context.Context, id uuid.UUID) (*model.MessageStatus, error) {
+	ud, _ := ctx.Value(userDataCtxKey).(map[string]string)
+
+	st, ok := s.statuses.Get(id)
+	if !ok {
+		sm, scheduled := s.scheduler.Get(id)
+		if !scheduled {
+			return nil, errMessageNotFound
+		}
+
+		sst := scheduledStatus(sm)
+		st = &sst
+	}
+
+	if st.ClientID != ud["clientID"] {
+		return nil, errMessageNotFound
+	}
+
+	return st, nil
+}
+
+// providerFailures are the recent consecutive
+// delivery failures of a provider.
+type providerFailures struct {
+	count int
+	last  time.Time
+}
+
+// recordResult keeps track of provider consecutive failures.
+// Only provider failures (i.e.: network errors, 5xx responses),
+// the ones a resend is asked for, are counted: a message rejected
+// by a provider tells that the provider is working.
+func (s *service) recordResult(name string, err error, resend bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err == nil || !resend {
+		delete(s.failures, name)
+		return
+	}
+
+	if s.failures == nil {
+		s.failures = make(map[string]*providerFailures)
+	}
+
+	pf, ok := s.failures[name]
+	if !ok {
+		pf = &providerFailures{}
+		s.failures[name] = pf
+	}
+	pf.count++
+	pf.last = time.Now()
+}
+
+// isAvailable is true if the provider is ready and
+// it has not failed repeatedly in the last failure window.
+// Failures expire so that a provider outage does not
+// keep the service not ready once it is over.
+func (s *service) isAvailable(p sys.Provider) bool {
+	if !p.IsReady() {
+		return false
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	pf, ok := s.failures[p.Name()]
+	if !ok {
+		return true
 	}
 
-	return err
+	return pf.count < maxProviderFailures || time.Since(pf.last) > providerFailureWindow
 }
 
 // Providers returns service providers.
 func (s *service) Providers() []sys.Provider {
-	return s.providers
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	ps := make([]sys.Provider, len(s.providers))
+	copy(ps, s.providers)
+	return ps
+}
+
+// current returns service config and providers
+// sorted by priority (1..n).
+func (s *service) current() (*config.Config, []sys.Provider) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.cfg, byPriority(s.providers)
+}
+
+// acquire returns service config and providers sorted by priority
+// for a send, done must be called once it is over so that providers
+// replaced by a config reload are not stopped before.
+func (s *service) acquire() (cfg *config.Config, ps []sys.Provider, done func()) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.sends.Add(1)
+	return s.cfg, byPriority(s.providers), s.sends.Done
 }
 
 // Enable set to true the ready state.
 func (s *service) Enable() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.ready = true
 }
 
 // Disable set to false the ready state.
 func (s *service) Disable() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.ready = false
 }
 
 // IsAlive return true if the service is ready to serve requests.
 // This function can be used by a readiness checker.
 func (s *service) IsReady() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	return s.ready
 }
 
@@ -127,6 +510,9 @@ func (s *service) Context() context.Context {
 
 // Config returns service config.
 func (s *service) Config() *config.Config {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
 	return s.cfg
 }
 
@@ -135,10 +521,54 @@ func (s *service) Logger() log.Logger {
 	return s.logger
 }
 
+// Tracer returns service tracer.
+func (s *service) Tracer() *zipkin.Tracer {
+	return s.tracer
+}
+
+// ProvidersByPriority returns service providers
+// sorted by priority (1..n)
+func (s *service) ProvidersByPriority() []sys.Provider {
+	_, ps := s.current()
+	return ps
+}
+
+// byPriority returns a copy of providers
+// sorted by priority (1..n).
+func byPriority(providers []sys.Provider) []sys.Provider {
+	ps := make([]sys.Provider, len(providers))
+	copy(ps, providers)
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Priority() < ps[j].Priority()
+	})
+	return ps
+}
+
+// routeProviders returns ready providers, in the same order,
+// that can deliver a message: test-only ones for
+// dry-run sends, the rest of them otherwise.
+func routeProviders(cfg *config.Config, providers []sys.Provider, dryRun bool) []sys.Provider {
+	ps := make([]sys.Provider, 0)
+	for _, p := range providers {
+		if p.IsReady() && isTestOnly(cfg, p) == dryRun {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// isTestOnly returns true if provider is test-only.
+// Only sinks can be test-only so that dry-run
+// sends never deliver real mail.
+func isTestOnly(cfg *config.Config, p sys.Provider) bool {
+	pc, ok := cfg.Mailer.ProviderByName(p.Name())
+	return ok && pc.TestOnly && pc.Type == config.ProviderType.File.String()
+}
+
 // ProviderByPriority returns a provider by
 // its prioririty (1..n)
 func (s *service) ProviderByPriority(priority int) (p sys.Provider, ok bool) {
-	for _, p := range s.providers {
+	for _, p := range s.Providers() {
 		if priority == p.Priority() {
 			return p, true
 		}
@@ -149,23 +579,32 @@ func (s *service) ProviderByPriority(priority int) (p sys.Provider, ok bool) {
 // FallbackProvider returns the default provider
 // if it was provided in config.
 func (s *service) FallbackProvider() (p sys.Provider, ok bool) {
-	if len(s.providers) > 0 {
-		return s.providers[0], true
+	if ps := s.Providers(); len(ps) > 0 {
+		return ps[0], true
 	}
 	return nil, false
 }
 
 // Utility functions
-func makeEmail(name, from, to, cc, bcc, subject, body string) *model.Email {
-	return &model.Email{
-		ID:      uuid.New(),
-		Name:    name,
-		From:    from,
-		To:      to,
-		CC:      cc,
-		BCC:     bcc,
-		Subject: subject,
-		Body:    body,
-		Charset: charset,
+// scheduledStatus returns the status of a scheduled message.
+func scheduledStatus(sm scheduledMessage) model.MessageStatus {
+	return model.MessageStatus{
+		ID:        sm.Email.ID,
+		ClientID:  sm.ClientID,
+		Status:    model.StatusScheduled,
+		SendAt:    sm.Email.SendAt,
+		RequestID: sm.Email.RequestID,
+		CreatedAt: sm.CreatedAt,
 	}
 }
+
+// makeEmail returns a copy of the email
+// identified and signed by the sender.
+func makeEmail(name, from string, email *model.Email) *model.Email {
+	e := *email
+	e.ID = uuid.New()
+	e.Name = name
+	e.From = from
+	e.Charset = charset
+	return &e
+}
@@ -0,0 +1,149 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/pkg/mailer/mailertest"
+	"github.com/adrianpk/poslan/pkg/model"
+	kitlog "github.com/go-kit/kit/log"
+)
+
+// TestSendFailover checks that providers are tried in priority order.
+func TestSendFailover(t *testing.T) {
+	t.Parallel()
+
+	p1 := mailertest.NewProvider("first", 1)
+	p2 := mailertest.NewProvider("second", 2)
+	p3 := mailertest.NewProvider("third", 3)
+
+	svc := makeService(context.Background(), &config.Config{}, kitlog.NewNopLogger())
+	svc.providers = append(svc.providers, p3, p2, p1)
+
+	p1.FailNext(1, true, errors.New("first failed"))
+	p2.FailNext(1, true, errors.New("second failed"))
+
+	_, err := svc.Send(userContext(), &model.Email{To: "to@example.com", Subject: "Subject", Body: "Body"})
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	if p1.Attempts() != 1 || p2.Attempts() != 1 || len(p3.Messages()) != 1 {
+		t.Errorf("Expected: 1, 1, 1 | Received: %d, %d, %d", p1.Attempts(), p2.Attempts(), len(p3.Messages()))
+	}
+
+	// No resend: next providers should not be tried.
+	p1.FailNext(1, false, errors.New("first failed"))
+
+	_, err = svc.Send(userContext(), &model.Email{To: "to@example.com", Subject: "Subject", Body: "Body"})
+	if err == nil {
+		t.Error("Expected: error | Received: no error")
+	}
+
+	if p2.Attempts() != 1 {
+		t.Errorf("Expected: 1 | Received: %d", p2.Attempts())
+	}
+}
+
+// TestSendPartialFailover checks that personalizations delivered
+// by a provider that fails on the rest are not sent again.
+func TestSendPartialFailover(t *testing.T) {
+	t.Parallel()
+
+	p1 := mailertest.NewProvider("first", 1)
+	p2 := mailertest.NewProvider("second", 2)
+
+	svc := makeService(context.Background(), &config.Config{}, kitlog.NewNopLogger())
+	svc.providers = append(svc.providers, p2, p1)
+
+	p1.FailNext(1, true, &model.PartialError{Sent: []int{0, 2}, Err: errors.New("first failed")})
+
+	em := &model.Email{Subject: "Subject", Body: "Body"}
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		em.Personalizations = append(em.Personalizations, model.Personalization{To: to})
+	}
+
+	sent, err := svc.SendBatch(userContext(), em)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	ms := p2.Messages()
+	if len(ms) != 1 || len(ms[0].Personalizations) != 1 || ms[0].Personalizations[0].To != "b@example.com" {
+		t.Fatalf("Expected: only b@example.com resent | Received: %v", ms)
+	}
+
+	expected := []string{"first", "second", "first"}
+	for i, p := range sent.Personalizations {
+		st, _ := svc.statuses.Get(p.ID)
+		if st.Status != model.StatusSent || st.Provider != expected[i] {
+			t.Errorf("Expected: %s by %s | Received: %s by %s", model.StatusSent, expected[i], st.Status, st.Provider)
+		}
+	}
+}
+
+// TestSendBatchPartial checks that messages of a partially
+// delivered batch are reported as accepted or failed.
+func TestSendBatchPartial(t *testing.T) {
+	t.Parallel()
+
+	p := mailertest.NewProvider("first", 1)
+
+	svc := makeService(context.Background(), &config.Config{}, kitlog.NewNopLogger())
+	svc.providers = append(svc.providers, p)
+
+	p.FailNext(1, false, &model.PartialError{Sent: []int{0}, Err: errors.New("rejected")})
+
+	req := sendBatchRequest{Subject: "Subject", Body: "Body"}
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		req.Recipients = append(req.Recipients, batchRecipient{personalization: personalization{To: to}})
+	}
+
+	res, err := makeSendBatchEndpoint(svc)(userContext(), req)
+	if err != nil {
+		t.Fatalf("Expected: no error | Received: %s", err.Error())
+	}
+
+	br := res.(sendBatchResponse)
+	if br.Accepted != 1 || br.Failed != 1 || br.Items[0].Status != batchAccepted || br.Items[1].Status != batchFailed || br.Items[1].Error == "" {
+		t.Errorf("Expected: 1 accepted, 1 failed | Received: %+v", br)
+	}
+
+	// Nothing delivered is still an error.
+	p.FailNext(1, false, errors.New("rejected"))
+
+	if _, err := makeSendBatchEndpoint(svc)(userContext(), req); err == nil {
+		t.Error("Expected: error | Received: no error")
+	}
+}
+
+// TestRecordResult checks that only provider
+// failures make a provider unavailable.
+func TestRecordResult(t *testing.T) {
+	p := mailertest.NewProvider("first", 1)
+	svc := makeService(context.Background(), &config.Config{}, kitlog.NewNopLogger())
+
+	for i := 0; i < maxProviderFailures; i++ {
+		svc.recordResult(p.Name(), errors.New("rejected"), false)
+	}
+
+	if !svc.isAvailable(p) {
+		t.Error("Expected: available after rejections | Received: not available")
+	}
+
+	for i := 0; i < maxProviderFailures; i++ {
+		svc.recordResult(p.Name(), errors.New("unavailable"), true)
+	}
+
+	if svc.isAvailable(p) {
+		t.Error("Expected: not available after failures | Received: available")
+	}
+
+	svc.recordResult(p.Name(), nil, false)
+
+	if !svc.isAvailable(p) {
+		t.Error("Expected: available after a send | Received: not available")
+	}
+}
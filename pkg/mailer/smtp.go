@@ -0,0 +1,78 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+
+	c "github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/smtpd"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// smtpBackend relays SMTP submitted messages
+// through the service, as the send endpoint does.
+// AUTH PLAIN username and password are the client ID and secret.
+type smtpBackend struct {
+	svc Service
+}
+
+// Authenticate signs in the client and returns a context
+// carrying its token and user data.
+func (b smtpBackend) Authenticate(ctx context.Context, clientID, secret string) (context.Context, error) {
+	tk, err := b.svc.SignIn(ctx, clientID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenToContext(ctx, tk), nil
+}
+
+// Send delivers the message.
+// Each submitted message gets its own request ID.
+func (b smtpBackend) Send(ctx context.Context, email *model.Email) (*model.Email, error) {
+	return b.svc.Send(withRequestID(ctx, requestIDOrNew("")), email)
+}
+
+// runSMTP serves SMTP submission until context is done.
+func runSMTP(ctx context.Context, cfg *c.Config, svc Service, logger log.Logger) error {
+	sc := cfg.App.SMTP
+
+	srv := &smtpd.Server{
+		Addr:              sc.PortFmt(),
+		Hostname:          sc.Hostname,
+		AllowInsecureAuth: sc.AllowInsecureAuth,
+		MaxMessageBytes:   sc.MaxMessageBytes,
+		Backend:           smtpBackend{svc: svc},
+		Logger:            logger,
+	}
+
+	if sc.HasTLS() {
+		cert, err := tls.LoadX509KeyPair(sc.TLSCert, sc.TLSKey)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	level.Info(logger).Log("message", "SMTP server listening.", "addr", srv.Addr)
+
+	err := srv.ListenAndServe()
+	if err == smtpd.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxStatuses is the max number of message statuses kept.
+	maxStatuses = 10000
+)
+
+// statusStore keeps the delivery status of the
+// last sent messages in memory.
+// Statuses are lost on restart.
+type statusStore struct {
+	mux      sync.Mutex
+	max      int
+	statuses map[uuid.UUID]*model.MessageStatus
+	order    []uuid.UUID
+}
+
+func newStatusStore(max int) *statusStore {
+	return &statusStore{
+		max:      max,
+		statuses: make(map[uuid.UUID]*model.MessageStatus),
+	}
+}
+
+// Put stores a copy of the status.
+// Oldest status is removed when the store is full,
+// statuses of scheduled messages are kept until
+// they are released or canceled.
+func (ss *statusStore) Put(st model.MessageStatus) {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	if _, ok := ss.statuses[st.ID]; !ok {
+		if len(ss.order) >= ss.max {
+			ss.evict()
+		}
+		ss.order = append(ss.order, st.ID)
+	}
+
+	st.UpdatedAt = time.Now()
+	ss.statuses[st.ID] = &st
+}
+
+// Get returns a copy of the status of a message.
+func (ss *statusStore) Get(id uuid.UUID) (st *model.MessageStatus, ok bool) {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+
+	s, ok := ss.statuses[id]
+	if !ok {
+		return nil, false
+	}
+
+	c := *s
+	return &c, true
+}
+
+// evict removes the oldest status
+// that is not a scheduled message one.
+func (ss *statusStore) evict() {
+	for i, id := range ss.order {
+		if ss.statuses[id].Status == model.StatusScheduled {
+			continue
+		}
+
+		delete(ss.statuses, id)
+		ss.order = append(ss.order[:i], ss.order[i+1:]...)
+		return
+	}
+}
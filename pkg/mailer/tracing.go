@@ -0,0 +1,326 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianpk/poslan/internal/config"
+	"github.com/adrianpk/poslan/internal/otlp"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
+	httpreporter "github.com/openzipkin/zipkin-go/reporter/http"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	// providerTag and outcomeTag are set on provider attempt spans.
+	providerTag = "provider"
+	outcomeTag  = "outcome"
+)
+
+// makeTracer returns a tracer exporting spans as configured
+// and its reporter, that must be closed on shutdown.
+func makeTracer(cfg config.TracingConfig) (*zipkin.Tracer, reporter.Reporter, error) {
+	var rep reporter.Reporter
+
+	switch cfg.Exporter {
+	case "":
+		return noopTracer(), reporter.NewNoopReporter(), nil
+
+	case config.TracingZipkin:
+		rep = httpreporter.NewReporter(
+			endpointOrDef(cfg.Endpoint, zipkinHTTPEndpoint),
+			httpreporter.BatchInterval(tracingBatchInterval),
+		)
+
+	case config.TracingOTLP:
+		rep = httpreporter.NewReporter(
+			endpointOrDef(cfg.Endpoint, otlpHTTPEndpoint),
+			httpreporter.BatchInterval(tracingBatchInterval),
+			httpreporter.Serializer(otlp.Serializer{ServiceName: serviceName}),
+		)
+
+	case config.TracingFile:
+		f, err := os.OpenFile(cfg.Endpoint, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		rep = newWriterReporter(f, f)
+
+	case config.TracingStdout:
+		rep = newWriterReporter(os.Stdout, nil)
+
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter '%s'", cfg.Exporter)
+	}
+
+	sampler, err := zipkin.NewBoundarySampler(cfg.SampleRate, time.Now().UnixNano())
+	if err != nil {
+		rep.Close()
+		return nil, nil, err
+	}
+
+	tracer, err := zipkin.NewTracer(
+		rep,
+		zipkin.WithLocalEndpoint(&zipkinmodel.Endpoint{ServiceName: serviceName}),
+		zipkin.WithSampler(sampler),
+		// W3C trace context IDs are 128 bits long
+		// and server spans are not shared with callers.
+		zipkin.WithTraceID128Bit(true),
+		zipkin.WithSharedSpans(false),
+	)
+	if err != nil {
+		rep.Close()
+		return nil, nil, err
+	}
+
+	return tracer, rep, nil
+}
+
+// noopTracer returns a tracer that does not record spans.
+func noopTracer() *zipkin.Tracer {
+	tracer, _ := zipkin.NewTracer(reporter.NewNoopReporter(), zipkin.WithNoopTracer(true))
+	return tracer
+}
+
+func endpointOrDef(endpoint, def string) string {
+	if endpoint == "" {
+		return def
+	}
+	return endpoint
+}
+
+// writerReporter writes spans as JSON, one per line.
+type writerReporter struct {
+	mux    sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+func newWriterReporter(w io.Writer, closer io.Closer) *writerReporter {
+	return &writerReporter{
+		enc:    json.NewEncoder(w),
+		closer: closer,
+	}
+}
+
+// Send writes a span.
+func (r *writerReporter) Send(s zipkinmodel.SpanModel) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.enc.Encode(s)
+}
+
+// Close closes the underlying writer, if any.
+func (r *writerReporter) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// traceHTTP starts a server span for each routed request
+// as a child of the caller one, if any.
+// Both B3 and W3C trace context headers are supported.
+func traceHTTP(tracer *zipkin.Tracer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Method
+			if tpl, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+				name += " " + tpl
+			}
+
+			span := tracer.StartSpan(name, zipkin.Kind(zipkinmodel.Server), zipkin.Parent(extractHTTP(tracer, r)))
+			defer span.Finish()
+
+			zipkin.TagHTTPMethod.Set(span, r.Method)
+			zipkin.TagHTTPPath.Set(span, r.URL.Path)
+			if id := requestIDFromContext(r.Context()); id != "" {
+				span.Tag(requestIDTag, id)
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(zipkin.NewContext(r.Context(), span)))
+
+			zipkin.TagHTTPStatusCode.Set(span, strconv.Itoa(sw.status))
+			if sw.status >= http.StatusInternalServerError {
+				zipkin.TagError.Set(span, http.StatusText(sw.status))
+			}
+		})
+	}
+}
+
+// extractHTTP returns the caller span context from B3 headers
+// or, if there are none, from the W3C traceparent header.
+func extractHTTP(tracer *zipkin.Tracer, r *http.Request) zipkinmodel.SpanContext {
+	sc := tracer.Extract(b3.ExtractHTTP(r))
+	if sc.Err != nil || !sc.TraceID.Empty() {
+		return sc
+	}
+
+	if sc, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+		return sc
+	}
+
+	return zipkinmodel.SpanContext{}
+}
+
+// parseTraceparent parses a W3C trace context traceparent header
+// (i.e.: '00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01').
+func parseTraceparent(h string) (sc zipkinmodel.SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	// Later versions may append fields.
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+
+	traceID, err := zipkinmodel.TraceIDFromHex(parts[1])
+	if err != nil || traceID.Empty() {
+		return sc, false
+	}
+
+	id, err := strconv.ParseUint(parts[2], 16, 64)
+	if err != nil || id == 0 {
+		return sc, false
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return sc, false
+	}
+
+	sampled := flags&1 == 1
+	return zipkinmodel.SpanContext{
+		TraceID: traceID,
+		ID:      zipkinmodel.ID(id),
+		Sampled: &sampled,
+	}, true
+}
+
+// statusWriter keeps the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// finishSpan tags the span with the error, if any, and finishes it.
+func finishSpan(span zipkin.Span, err error) {
+	if err != nil {
+		zipkin.TagError.Set(span, err.Error())
+	}
+	span.Finish()
+}
+
+type tracingMiddleware struct {
+	ctx    context.Context
+	logger log.Logger
+	tracer *zipkin.Tracer
+	next   Service
+}
+
+// SignIn is a tracing middleware wrapper over another interface implementation of SignIn.
+func (mw tracingMiddleware) SignIn(ctx context.Context, clientID, secret string) (output string, err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "SignIn")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.SignIn(ctx, clientID, secret)
+}
+
+// SignOut is a tracing middleware wrapper over another interface implementation of SignOut.
+func (mw tracingMiddleware) SignOut(ctx context.Context, id uuid.UUID) (err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "SignOut")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.SignOut(ctx, id)
+}
+
+// Send is a tracing middleware wrapper over another interface implementation of Send.
+func (mw tracingMiddleware) Send(ctx context.Context, email *model.Email) (output *model.Email, err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "Send")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.Send(ctx, email)
+}
+
+// SendBatch is a tracing middleware wrapper over another interface implementation of SendBatch.
+func (mw tracingMiddleware) SendBatch(ctx context.Context, email *model.Email) (output *model.Email, err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "SendBatch")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.SendBatch(ctx, email)
+}
+
+// Message is a tracing middleware wrapper over another interface implementation of Message.
+func (mw tracingMiddleware) Message(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "Message")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.Message(ctx, id)
+}
+
+// Cancel is a tracing middleware wrapper over another interface implementation of Cancel.
+func (mw tracingMiddleware) Cancel(ctx context.Context, id uuid.UUID) (output *model.MessageStatus, err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "Cancel")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.Cancel(ctx, id)
+}
+
+// Reschedule is a tracing middleware wrapper over another interface implementation of Reschedule.
+func (mw tracingMiddleware) Reschedule(ctx context.Context, id uuid.UUID, sendAt time.Time) (output *model.MessageStatus, err error) {
+	span, ctx := mw.tracer.StartSpanFromContext(ctx, "Reschedule")
+	defer func() { finishSpan(span, err) }()
+
+	return mw.next.Reschedule(ctx, id, sendAt)
+}
+
+// Context returns service context.
+func (mw tracingMiddleware) Context() context.Context {
+	return mw.ctx
+}
+
+// Config returns service config.
+func (mw tracingMiddleware) Config() *config.Config {
+	return mw.next.Config()
+}
+
+// Logger returns service logger.
+func (mw tracingMiddleware) Logger() log.Logger {
+	return mw.logger
+}
+
+// Tracer returns service tracer.
+func (mw tracingMiddleware) Tracer() *zipkin.Tracer {
+	return mw.tracer
+}
@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"testing"
+)
+
+// TestParseTraceparent checks W3C trace context parsing.
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true, true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", true, false},
+		{"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", true, true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", false, false},
+		{"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", false, false},
+		{"00-00000000000000000000000000000000-b7ad6b7169203331-01", false, false},
+		{"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", false, false},
+		{"00-0af7651916cd43dd-b7ad6b7169203331-01", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		sc, ok := parseTraceparent(tt.header)
+		if ok != tt.ok {
+			t.Errorf("%s - Expected: %t | Received: %t", tt.header, tt.ok, ok)
+			continue
+		}
+
+		if ok && (sc.TraceID.String() != "0af7651916cd43dd8448eb211c80319c" || sc.ID.String() != "b7ad6b7169203331" || *sc.Sampled != tt.sampled) {
+			t.Errorf("%s - Expected: valid span context | Received: %+v", tt.header, sc)
+		}
+	}
+}
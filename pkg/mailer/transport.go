@@ -5,12 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	c "github.com/adrianpk/poslan/internal/config"
+	js "github.com/adrianpk/poslan/internal/jsonschema"
 	"github.com/adrianpk/poslan/pkg/auth"
+	"github.com/go-kit/kit/log/level"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 )
 
 const (
@@ -26,30 +35,105 @@ var (
 )
 
 // Run the mailer service.
-func Run() {
+// Config is loaded from the file provided, if any,
+// and environment variables.
+func Run(configFile string) {
 	// Context
 	ctx, cancel := context.WithCancel(context.Background())
 	go checkSigTerm(cancel)
 
 	// Logger
-	logger := makeLogger()
+	logger := makeLogger(string(c.LogFormat.Logfmt), string(c.LogLevel.Info), string(c.PrivacyLevel.Hash))
 
 	// Config
-	cfg, err := c.Load(logger)
-	checkError(err)
+	cfg, err := c.Load(logger, configFile)
+	checkConfigError(logger, err)
+
+	logger = makeLogger(string(cfg.App.LogFormat), string(cfg.App.LogLevel), string(cfg.App.LogPrivacy))
+	level.Info(logger).Log("message", "Config Logger started.")
 
 	// Service
-	svc, err := makeService(ctx, cfg, logger).Init()
+	s := makeService(ctx, cfg, logger)
+	svc, err := s.Init()
 	checkError(err)
 
-	// Handlers
-	http.Handle("/signin", SignInHandler(svc))
-	http.Handle("/signout", SignOutHandler(svc))
-	http.Handle("/send", SendHandler(svc))
+	// Admin
+	if cfg.App.AdminPort > 0 {
+		go func() {
+			if err := runAdmin(ctx, cfg, s, logger); err != nil {
+				level.Error(logger).Log("msg", err.Error())
+			}
+		}()
+	}
+
+	// gRPC
+	if cfg.App.GRPCPort > 0 {
+		go func() {
+			if err := runGRPC(ctx, cfg, svc, logger); err != nil {
+				level.Error(logger).Log("msg", err.Error())
+			}
+		}()
+	}
+
+	// SMTP
+	if cfg.App.SMTP.Enabled {
+		go func() {
+			if err := runSMTP(ctx, cfg, svc, logger); err != nil {
+				level.Error(logger).Log("msg", err.Error())
+			}
+		}()
+	}
+
+	listener, err := net.Listen("tcp", cfg.App.ServerPortFmt())
+	checkError(err)
+
+	s.Start()
+	go watchConfig(ctx, s, configFile)
+
+	err = http.Serve(listener, MakeHTTPHandler(svc))
+
+	level.Error(logger).Log("msg", err.Error())
+}
+
+// MakeHTTPHandler returns the service HTTP API handler.
+// Every request is identified by its X-Request-ID header
+// or by a generated ID.
+// API is versioned under '/v1'.
+// Unversioned routes are kept for backward compatibility.
+func MakeHTTPHandler(svc Service) http.Handler {
+	r := mux.NewRouter()
+	r.NotFoundHandler = notFoundHandler()
+	r.MethodNotAllowedHandler = methodNotAllowedHandler()
+	r.Use(traceHTTP(svc.Tracer()))
+
+	signIn := SignInHandler(svc)
+	signOut := SignOutHandler(svc)
+	send := http.Handler(SendHandler(svc))
+	sendBatch := http.Handler(SendBatchHandler(svc))
+
+	// Send requests with an idempotency key are only processed once.
+	if ttl := svc.Config().App.IdempotencyTTL; ttl > 0 {
+		is := newIdempotencyStore(time.Duration(ttl) * time.Second)
+		as := auth.Server{Logger: svc.Logger()}
+		send = idempotent(is, as, send)
+		sendBatch = idempotent(is, as, sendBatch)
+	}
 
-	err = http.ListenAndServe(cfg.App.ServerPortFmt(), nil)
+	r.Methods(http.MethodPost).Path("/v1/signin").Handler(signIn)
+	r.Methods(http.MethodPost).Path("/v1/signout").Handler(signOut)
+	r.Methods(http.MethodPost).Path("/v1/send").Handler(send)
+	r.Methods(http.MethodPost).Path("/v1/send/batch").Handler(sendBatch)
+	r.Methods(http.MethodGet).Path("/v1/messages/{id}").Handler(MessageHandler(svc))
+	r.Methods(http.MethodPatch).Path("/v1/messages/{id}").Handler(RescheduleHandler(svc))
+	r.Methods(http.MethodDelete).Path("/v1/messages/{id}").Handler(CancelHandler(svc))
+	r.Methods(http.MethodGet).Path("/v1/openapi.json").Handler(OpenAPIHandler())
 
-	logger.Log("level", c.LogLevel.Error, "msg", err.Error())
+	// Deprecated: use '/v1' routes.
+	r.Methods(http.MethodPost).Path("/signin").Handler(signIn)
+	r.Methods(http.MethodPost).Path("/signout").Handler(signOut)
+	r.Methods(http.MethodPost).Path("/send").Handler(send)
+
+	return requestIDHTTP(r)
 }
 
 // SignInHandler manages signin up process.
@@ -58,35 +142,80 @@ func SignInHandler(svc Service) *httptransport.Server {
 		makeSignInEndpoint(svc),
 		decodeSignInRequest,
 		encodeResponse,
+		httptransport.ServerErrorEncoder(encodeError),
 	)
 }
 
 // SignOutHandler manages signout up process.
 func SignOutHandler(svc Service) *httptransport.Server {
-	opts := httptransport.ServerBefore(userDataToContext)
 	return httptransport.NewServer(
 		makeSignOutEndpoint(svc),
 		decodeSignOutRequest,
 		encodeResponse,
-		opts,
+		httptransport.ServerBefore(userDataToContext),
+		httptransport.ServerErrorEncoder(encodeError),
 	)
 }
 
 // SendHandler manages email sending.
 func SendHandler(svc Service) *httptransport.Server {
-	opts := httptransport.ServerBefore(userDataToContext)
 	return httptransport.NewServer(
 		makeSendEndpoint(svc),
 		decodeSendRequest,
 		encodeResponse,
-		opts,
+		httptransport.ServerBefore(userDataToContext),
+		httptransport.ServerErrorEncoder(encodeError),
+	)
+}
+
+// SendBatchHandler manages batch email sending.
+func SendBatchHandler(svc Service) *httptransport.Server {
+	return httptransport.NewServer(
+		makeSendBatchEndpoint(svc),
+		decodeSendBatchRequest,
+		encodeResponse,
+		httptransport.ServerBefore(userDataToContext),
+		httptransport.ServerErrorEncoder(encodeError),
+	)
+}
+
+// MessageHandler manages message status lookups.
+func MessageHandler(svc Service) *httptransport.Server {
+	return httptransport.NewServer(
+		makeMessageEndpoint(svc),
+		decodeMessageRequest,
+		encodeResponse,
+		httptransport.ServerBefore(userDataToContext),
+		httptransport.ServerErrorEncoder(encodeError),
+	)
+}
+
+// CancelHandler manages scheduled message cancellations.
+func CancelHandler(svc Service) *httptransport.Server {
+	return httptransport.NewServer(
+		makeCancelEndpoint(svc),
+		decodeMessageRequest,
+		encodeResponse,
+		httptransport.ServerBefore(userDataToContext),
+		httptransport.ServerErrorEncoder(encodeError),
+	)
+}
+
+// RescheduleHandler manages scheduled message send time changes.
+func RescheduleHandler(svc Service) *httptransport.Server {
+	return httptransport.NewServer(
+		makeRescheduleEndpoint(svc),
+		decodeRescheduleRequest,
+		encodeResponse,
+		httptransport.ServerBefore(userDataToContext),
+		httptransport.ServerErrorEncoder(encodeError),
 	)
 }
 
 // Decoders
 func decodeSignInRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var request signInRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+	if err := decodeJSON(r, signInSchema, &request); err != nil {
 		return nil, err
 	}
 	return request, nil
@@ -94,35 +223,162 @@ func decodeSignInRequest(ctx context.Context, r *http.Request) (interface{}, err
 
 func decodeSignOutRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var request signOutRequest
-	userDataToContext(ctx, r)
-
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+	if err := decodeJSON(r, signOutSchema, &request); err != nil {
 		return nil, err
 	}
-
 	return request, nil
 }
 
 func decodeSendRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var request sendRequest
-	userDataToContext(ctx, r)
+	if err := decodeJSON(r, sendSchema, &request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+// decodeSendBatchRequest rejects the request if it does not match
+// the schema other than in its recipients, invalid ones are
+// kept along with their errors so that they can be reported.
+func decodeSendBatchRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	body, err := readJSON(r)
+	if err != nil {
 		return nil, err
 	}
 
+	var errs []js.FieldError
+	rerrs := make(map[int][]js.FieldError)
+
+	for _, fe := range sendBatchSchema.ValidateJSON(body) {
+		i, ok := recipientIndex(fe.Field)
+		if !ok {
+			errs = append(errs, fe)
+			continue
+		}
+		rerrs[i] = append(rerrs[i], fe)
+	}
+
+	if len(errs) > 0 {
+		return nil, validationError{errs: errs}
+	}
+
+	var request struct {
+		sendBatchRequest
+		Recipients []json.RawMessage `json:"recipients"`
+	}
+
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, wrapError(errInvalidRequest, err)
+	}
+
+	req := request.sendBatchRequest
+	req.Recipients = make([]batchRecipient, len(request.Recipients))
+
+	// Invalid recipients are decoded as far as
+	// possible to report them along with their errors.
+	for i, raw := range request.Recipients {
+		json.Unmarshal(raw, &req.Recipients[i].personalization)
+		req.Recipients[i].Errors = rerrs[i]
+	}
+
+	return req, nil
+}
+
+// recipientIndex returns the index of the recipient
+// a batch request field error refers to.
+func recipientIndex(field string) (int, bool) {
+	parts := strings.SplitN(field, ".", 3)
+	if len(parts) < 2 || parts[0] != "recipients" {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(parts[1])
+	return i, err == nil
+}
+
+func decodeMessageRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := messageID(r)
+	if err != nil {
+		return nil, err
+	}
+	return messageRequest{ID: id}, nil
+}
+
+func decodeRescheduleRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := messageID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var request rescheduleRequest
+	if err := decodeJSON(r, rescheduleSchema, &request); err != nil {
+		return nil, err
+	}
+
+	request.ID = id
 	return request, nil
 }
 
+// messageID returns the message ID in request path.
+func messageID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return uuid.Nil, wrapError(errInvalidRequest, errors.New("invalid message id"))
+	}
+	return id, nil
+}
+
+// decodeJSON validates the request body against
+// the schema and decodes it into v.
+func decodeJSON(r *http.Request, s *js.Schema, v interface{}) error {
+	body, err := readJSON(r)
+	if err != nil {
+		return err
+	}
+
+	if errs := s.ValidateJSON(body); len(errs) > 0 {
+		return validationError{errs: errs}
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return wrapError(errInvalidRequest, err)
+	}
+
+	return nil
+}
+
+// readJSON reads a JSON request body.
+func readJSON(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		return nil, wrapError(errInvalidRequest, err)
+	}
+
+	if len(body) > maxBodyBytes {
+		return nil, wrapError(errInvalidRequest, errors.New("request body too large"))
+	}
+
+	if !json.Valid(body) {
+		return nil, wrapError(errInvalidRequest, errors.New("malformed JSON"))
+	}
+
+	return body, nil
+}
+
 // Encoders
 func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	return json.NewEncoder(w).Encode(response)
 }
 
-// userDataToContext read bearer token from request header.
+// readToken read bearer token from request header.
 func readToken(r *http.Request) (string, error) {
-	token := r.Header.Get("Authorization")
-	splitToken := strings.Split(token, "Bearer")
+	return bearerToken(r.Header.Get("Authorization"))
+}
+
+// bearerToken returns the token of a bearer authorization value.
+func bearerToken(value string) (string, error) {
+	splitToken := strings.Split(value, "Bearer")
 	if len(splitToken) != 2 {
 		return "", errors.New("invalid token format")
 	}
@@ -132,12 +388,16 @@ func readToken(r *http.Request) (string, error) {
 // userDataToContext extracts bearer token from request header and stores it
 // in context.
 func userDataToContext(ctx context.Context, r *http.Request) context.Context {
-	tk := r.Header.Get("Authorization")
 	tk, err := readToken(r)
 	if err != nil {
 		return ctx
 	}
 
+	return tokenToContext(ctx, tk)
+}
+
+// tokenToContext stores the token and its user data in context.
+func tokenToContext(ctx context.Context, tk string) context.Context {
 	ud, err := auth.UserData(tk)
 	if err != nil {
 		return ctx
@@ -8,6 +8,9 @@
 package mailer
 
 import (
+	"time"
+
+	js "github.com/adrianpk/poslan/internal/jsonschema"
 	"github.com/adrianpk/poslan/pkg/model"
 	"github.com/google/uuid"
 )
@@ -23,7 +26,6 @@ type signInRequest struct {
 
 type signInResponse struct {
 	Token string `json:"token,omitempty"`
-	Err   string `json:"error,omitempty"`
 }
 
 // Sign out
@@ -31,22 +33,198 @@ type signOutRequest struct {
 	ID uuid.UUID `json:"id,omitempty"`
 }
 
-type signOutResponse struct {
-	Err string `json:"error,omitempty"`
-}
+type signOutResponse struct{}
 
 // Send
 type sendRequest struct {
-	To      string `json:"to,omitempty"`
-	Cc      string `json:"cc,omitempty"`
-	Bcc     string `json:"bcc,omitempty"`
-	Subject string `json:"subject,omitempty"`
-	Body    string `json:"body,omitempty"`
+	To       string            `json:"to,omitempty"`
+	Cc       string            `json:"cc,omitempty"`
+	Bcc      string            `json:"bcc,omitempty"`
+	ReplyTo  string            `json:"replyTo,omitempty"`
+	Subject  string            `json:"subject,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	// Personalizations let send the same message to
+	// many recipients with per recipient substitutions.
+	Personalizations []personalization `json:"personalizations,omitempty"`
+	schedule
 }
 
+// schedule sets the send time of a message
+// either as an absolute time or a delay.
+type schedule struct {
+	SendAt *time.Time `json:"sendAt,omitempty"`
+	Delay  string     `json:"delay,omitempty"`
+}
+
+type personalization struct {
+	To            string            `json:"to"`
+	Substitutions map[string]string `json:"substitutions,omitempty"`
+}
+
+// sendResponse is a sent or scheduled message.
 type sendResponse struct {
-	Email *model.Email `json:"email,omitempty"`
-	Err   string       `json:"error,omitempty"`
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	// SendAt is the delivery time of scheduled messages.
+	SendAt           *time.Time        `json:"sendAt,omitempty"`
+	RequestID        string            `json:"requestID,omitempty"`
+	Name             string            `json:"name,omitempty"`
+	From             string            `json:"from,omitempty"`
+	To               string            `json:"to,omitempty"`
+	Cc               string            `json:"cc,omitempty"`
+	Bcc              string            `json:"bcc,omitempty"`
+	ReplyTo          string            `json:"replyTo,omitempty"`
+	Subject          string            `json:"subject,omitempty"`
+	Body             string            `json:"body,omitempty"`
+	Charset          string            `json:"charset,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Personalizations []personalization `json:"personalizations,omitempty"`
+}
+
+// Send batch
+type sendBatchRequest struct {
+	// Template is not supported yet.
+	Template string            `json:"template,omitempty"`
+	Cc       string            `json:"cc,omitempty"`
+	Bcc      string            `json:"bcc,omitempty"`
+	ReplyTo  string            `json:"replyTo,omitempty"`
+	Subject  string            `json:"subject,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	// Recipients are decoded one by one so that
+	// invalid ones do not reject the whole batch.
+	Recipients []batchRecipient `json:"-"`
+}
+
+type batchRecipient struct {
+	personalization
+	// Errors are the recipient validation errors.
+	Errors []js.FieldError
+}
+
+type sendBatchResponse struct {
+	// ID is the batch ID.
+	ID       uuid.UUID `json:"id"`
+	Accepted int       `json:"accepted"`
+	Rejected int       `json:"rejected"`
+	// Failed are valid recipients not delivered.
+	Failed int         `json:"failed"`
+	Items  []batchItem `json:"items"`
+}
+
+// batchItem is the result of a batch recipient.
+type batchItem struct {
+	Index int    `json:"index"`
+	To    string `json:"to,omitempty"`
+	// ID is the message ID of valid recipients.
+	ID     string          `json:"id,omitempty"`
+	Status string          `json:"status"`
+	Errors []js.FieldError `json:"errors,omitempty"`
+	// Error is the delivery error of failed recipients.
+	Error string `json:"error,omitempty"`
+}
+
+// Batch item statuses.
+const (
+	batchAccepted = "accepted"
+	batchRejected = "rejected"
+	batchFailed   = "failed"
+)
+
+// Message
+type messageRequest struct {
+	ID uuid.UUID `json:"id,omitempty"`
+}
+
+type messageResponse struct {
+	Status *messageStatus `json:"status,omitempty"`
+}
+
+// messageStatus is the delivery status of a message.
+type messageStatus struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	// Provider that delivered the message.
+	Provider string `json:"provider,omitempty"`
+	// Attempts is the number of providers tried.
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	// SendAt is the delivery time of scheduled messages.
+	SendAt    *time.Time `json:"sendAt,omitempty"`
+	RequestID string     `json:"requestID,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Reschedule
+type rescheduleRequest struct {
+	ID uuid.UUID `json:"-"`
+	schedule
+}
+
+// newSendResponse returns the response of a message
+// sent, or scheduled if its send time is after now.
+func newSendResponse(em *model.Email, now time.Time) sendResponse {
+	res := sendResponse{
+		ID:        em.ID,
+		Status:    model.StatusSent,
+		RequestID: em.RequestID,
+		Name:      em.Name,
+		From:      em.From,
+		To:        em.To,
+		Cc:        em.CC,
+		Bcc:       em.BCC,
+		ReplyTo:   em.ReplyTo,
+		Subject:   em.Subject,
+		Body:      em.Body,
+		Charset:   em.Charset,
+		Headers:   em.Headers,
+		Metadata:  em.Metadata,
+		Tags:      em.Tags,
+	}
+
+	if em.SendAt.After(now) {
+		sendAt := em.SendAt
+		res.Status = model.StatusScheduled
+		res.SendAt = &sendAt
+	}
+
+	for _, p := range em.Personalizations {
+		res.Personalizations = append(res.Personalizations, personalization{
+			To:            p.To,
+			Substitutions: p.Substitutions,
+		})
+	}
+
+	return res
+}
+
+// newMessageResponse returns the response of a message status.
+func newMessageResponse(st *model.MessageStatus) messageResponse {
+	ms := &messageStatus{
+		ID:        st.ID,
+		Status:    st.Status,
+		Provider:  st.Provider,
+		Attempts:  st.Attempts,
+		Error:     st.Error,
+		RequestID: st.RequestID,
+		CreatedAt: st.CreatedAt,
+		UpdatedAt: st.UpdatedAt,
+	}
+
+	if !st.SendAt.IsZero() {
+		sendAt := st.SendAt
+		ms.SendAt = &sendAt
+	}
+
+	return messageResponse{Status: ms}
 }
 
 func (c contextKey) String() string {
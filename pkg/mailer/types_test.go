@@ -0,0 +1,102 @@
+package mailer
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	js "github.com/adrianpk/poslan/internal/jsonschema"
+	"github.com/adrianpk/poslan/pkg/model"
+	"github.com/google/uuid"
+)
+
+// TestResponseJSON checks send and message response
+// field names against their OpenAPI schemas.
+func TestResponseJSON(t *testing.T) {
+	now := time.Now()
+
+	em := &model.Email{
+		ID:               uuid.New(),
+		SendAt:           now.Add(time.Hour),
+		RequestID:        "req-1",
+		Name:             "Sender",
+		From:             "sender@example.com",
+		To:               "to@example.com",
+		CC:               "cc@example.com",
+		BCC:              "bcc@example.com",
+		ReplyTo:          "reply@example.com",
+		Subject:          "Subject",
+		Body:             "Body",
+		Charset:          charset,
+		Headers:          map[string]string{"X-Campaign": "welcome"},
+		Metadata:         map[string]string{"campaign": "welcome"},
+		Tags:             []string{"welcome"},
+		Personalizations: []model.Personalization{{To: "to@example.com"}},
+	}
+
+	st := &model.MessageStatus{
+		ID:        em.ID,
+		ClientID:  clientID,
+		Status:    model.StatusScheduled,
+		Provider:  "sendgrid",
+		Attempts:  1,
+		Error:     "error",
+		SendAt:    em.SendAt,
+		RequestID: em.RequestID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tests := []struct {
+		name     string
+		res      interface{}
+		schema   *js.Schema
+		expected []string
+	}{
+		{
+			"send", newSendResponse(em, now), sendResponseSchema,
+			[]string{"bcc", "body", "cc", "charset", "from", "headers", "id", "metadata", "name",
+				"personalizations", "replyTo", "requestID", "sendAt", "status", "subject", "tags", "to"},
+		},
+		{
+			"message", newMessageResponse(st).Status, messageStatusSchema,
+			[]string{"attempts", "createdAt", "error", "id", "provider", "requestID", "sendAt", "status", "updatedAt"},
+		},
+	}
+
+	for _, tc := range tests {
+		data, err := json.Marshal(tc.res)
+		if err != nil {
+			t.Fatalf("%s - Expected: no error | Received: %s", tc.name, err.Error())
+		}
+
+		var fields map[string]interface{}
+		json.Unmarshal(data, &fields)
+
+		var names []string
+		for name := range fields {
+			names = append(names, name)
+			if _, ok := tc.schema.Properties[name]; !ok {
+				t.Errorf("%s - Expected: '%s' in schema | Received: not found", tc.name, name)
+			}
+		}
+		sort.Strings(names)
+
+		if len(names) != len(tc.expected) {
+			t.Errorf("%s - Expected: %v | Received: %v", tc.name, tc.expected, names)
+			continue
+		}
+
+		for i := range names {
+			if names[i] != tc.expected[i] {
+				t.Errorf("%s - Expected: %v | Received: %v", tc.name, tc.expected, names)
+				break
+			}
+		}
+	}
+
+	if res := newSendResponse(em, em.SendAt); res.Status != model.StatusSent || res.SendAt != nil {
+		t.Errorf("Expected: '%s' without send time | Received: '%s' %v", model.StatusSent, res.Status, res.SendAt)
+	}
+}
@@ -0,0 +1,106 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Expand returns one email per personalization
+// with its ID and substitutions applied to subject and body.
+// Cc and Bcc recipients are only kept in the first one
+// so that they receive a single copy.
+// If there are no personalizations the email itself is returned.
+func (e *Email) Expand() []*Email {
+	if len(e.Personalizations) == 0 {
+		return []*Email{e}
+	}
+
+	es := make([]*Email, len(e.Personalizations))
+	for i, p := range e.Personalizations {
+		pe := *e
+		pe.Personalizations = nil
+		if p.ID != uuid.Nil {
+			pe.ID = p.ID
+		}
+		pe.To = p.To
+		pe.Subject = substitute(e.Subject, p.Substitutions)
+		pe.Body = substitute(e.Body, p.Substitutions)
+		if i > 0 {
+			pe.CC = ""
+			pe.BCC = ""
+		}
+		es[i] = &pe
+	}
+
+	return es
+}
+
+func substitute(s string, subs map[string]string) string {
+	if len(subs) == 0 {
+		return s
+	}
+	// Sorted keys give a stable result
+	// when some of them overlap.
+	keys := make([]string, 0, len(subs))
+	for k := range subs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	oldnew := make([]string, 0, len(subs)*2)
+	for _, k := range keys {
+		oldnew = append(oldnew, k, subs[k])
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
+
+// Without returns a copy of the email without the
+// personalizations at the indexes provided.
+func (e *Email) Without(idx []int) *Email {
+	skip := make(map[int]bool, len(idx))
+	for _, i := range idx {
+		skip[i] = true
+	}
+
+	we := *e
+	we.Personalizations = nil
+	for i, p := range e.Personalizations {
+		if !skip[i] {
+			we.Personalizations = append(we.Personalizations, p)
+		}
+	}
+
+	return &we
+}
+
+// PartialError is returned by providers that delivered
+// some of the personalizations of an email but not all of them.
+// Resend only applies to the ones that were not delivered.
+type PartialError struct {
+	// Sent are the indexes of the delivered personalizations.
+	Sent []int
+	Err  error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("partially delivered (%d sent): %s", len(e.Sent), e.Err.Error())
+}
+
+// Partial returns a PartialError if some
+// personalizations were sent, otherwise err.
+func Partial(sent []int, err error) error {
+	if len(sent) == 0 {
+		return err
+	}
+	return &PartialError{Sent: sent, Err: err}
+}
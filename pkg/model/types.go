@@ -8,6 +8,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -24,6 +26,7 @@ type User struct {
 // Email model
 // A non PoC implementations should accespt lists, slices,
 // for to, cc, and bcc fields
+// Meanwhile they accept comma separated address lists.
 type Email struct {
 	ID      uuid.UUID
 	Name    string
@@ -31,7 +34,65 @@ type Email struct {
 	To      string
 	CC      string
 	BCC     string
+	ReplyTo string
 	Subject string
 	Body    string
 	Charset string
+	// Headers are custom message headers (i.e.: List-Unsubscribe, X-Campaign).
+	Headers map[string]string
+	// Metadata are name/value pairs attached to the message
+	// (i.e.: SES message tags, SendGrid custom args).
+	Metadata map[string]string
+	// Tags categorize the message (i.e.: SendGrid categories).
+	Tags []string
+	// Personalizations, if provided, replace To:
+	// each one is delivered as an individual message.
+	Personalizations []Personalization
+	// SendAt, if in the future, delays delivery until then.
+	SendAt time.Time
+	// RequestID identifies the request that sent the message.
+	// It is forwarded to providers.
+	RequestID string
+}
+
+// Personalization stores per recipient data.
+type Personalization struct {
+	// ID identifies the message delivered to this recipient.
+	// If not set the email ID is used.
+	ID uuid.UUID
+	To string
+	// Substitutions are replaced in subject and body
+	// by its value for this recipient.
+	Substitutions map[string]string
+}
+
+// Message delivery statuses.
+const (
+	// StatusSent messages were accepted by a provider.
+	StatusSent = "sent"
+	// StatusFailed messages were rejected by every provider.
+	StatusFailed = "failed"
+	// StatusScheduled messages are waiting to be sent.
+	StatusScheduled = "scheduled"
+	// StatusCanceled messages were canceled before being sent.
+	StatusCanceled = "canceled"
+)
+
+// MessageStatus stores the delivery status of a message.
+type MessageStatus struct {
+	ID       uuid.UUID
+	ClientID string
+	Status   string
+	// Provider that delivered the message.
+	Provider string
+	// Attempts is the number of providers tried.
+	Attempts int
+	Error    string
+	// SendAt is the delivery time of scheduled messages.
+	SendAt time.Time
+	// RequestID identifies the request that sent the message.
+	RequestID string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
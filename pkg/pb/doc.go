@@ -0,0 +1,17 @@
+/**
+ * Copyright (c) 2019 Adrian K <adrian.git@kuguar.dev>
+ *
+ * This software is released under the MIT License.
+ * https://opensource.org/licenses/MIT
+ */
+
+// Package pb provides Poslan gRPC API messages and client.
+//
+//	conn, _ := grpc.Dial("localhost:8081", grpc.WithInsecure())
+//	client := pb.NewPoslanClient(conn)
+//	res, _ := client.SignIn(ctx, &pb.SignInRequest{ClientId: id, Secret: secret})
+//	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+res.Token)
+//	client.Send(ctx, &pb.SendRequest{To: "to@example.com", Subject: "Subject", Body: "Body"})
+package pb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. poslan.proto
@@ -0,0 +1,979 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: poslan.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type SignInRequest struct {
+	ClientId             string   `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	Secret               string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignInRequest) Reset()         { *m = SignInRequest{} }
+func (m *SignInRequest) String() string { return proto.CompactTextString(m) }
+func (*SignInRequest) ProtoMessage()    {}
+func (*SignInRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{0}
+}
+
+func (m *SignInRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignInRequest.Unmarshal(m, b)
+}
+func (m *SignInRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignInRequest.Marshal(b, m, deterministic)
+}
+func (m *SignInRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignInRequest.Merge(m, src)
+}
+func (m *SignInRequest) XXX_Size() int {
+	return xxx_messageInfo_SignInRequest.Size(m)
+}
+func (m *SignInRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignInRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignInRequest proto.InternalMessageInfo
+
+func (m *SignInRequest) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *SignInRequest) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+type SignInResponse struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SignInResponse) Reset()         { *m = SignInResponse{} }
+func (m *SignInResponse) String() string { return proto.CompactTextString(m) }
+func (*SignInResponse) ProtoMessage()    {}
+func (*SignInResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{1}
+}
+
+func (m *SignInResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SignInResponse.Unmarshal(m, b)
+}
+func (m *SignInResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SignInResponse.Marshal(b, m, deterministic)
+}
+func (m *SignInResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SignInResponse.Merge(m, src)
+}
+func (m *SignInResponse) XXX_Size() int {
+	return xxx_messageInfo_SignInResponse.Size(m)
+}
+func (m *SignInResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SignInResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SignInResponse proto.InternalMessageInfo
+
+func (m *SignInResponse) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type Personalization struct {
+	To                   string            `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
+	Substitutions        map[string]string `protobuf:"bytes,2,rep,name=substitutions,proto3" json:"substitutions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *Personalization) Reset()         { *m = Personalization{} }
+func (m *Personalization) String() string { return proto.CompactTextString(m) }
+func (*Personalization) ProtoMessage()    {}
+func (*Personalization) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{2}
+}
+
+func (m *Personalization) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Personalization.Unmarshal(m, b)
+}
+func (m *Personalization) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Personalization.Marshal(b, m, deterministic)
+}
+func (m *Personalization) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Personalization.Merge(m, src)
+}
+func (m *Personalization) XXX_Size() int {
+	return xxx_messageInfo_Personalization.Size(m)
+}
+func (m *Personalization) XXX_DiscardUnknown() {
+	xxx_messageInfo_Personalization.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Personalization proto.InternalMessageInfo
+
+func (m *Personalization) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
+func (m *Personalization) GetSubstitutions() map[string]string {
+	if m != nil {
+		return m.Substitutions
+	}
+	return nil
+}
+
+type SendRequest struct {
+	To               string             `protobuf:"bytes,1,opt,name=to,proto3" json:"to,omitempty"`
+	Cc               string             `protobuf:"bytes,2,opt,name=cc,proto3" json:"cc,omitempty"`
+	Bcc              string             `protobuf:"bytes,3,opt,name=bcc,proto3" json:"bcc,omitempty"`
+	ReplyTo          string             `protobuf:"bytes,4,opt,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	Subject          string             `protobuf:"bytes,5,opt,name=subject,proto3" json:"subject,omitempty"`
+	Body             string             `protobuf:"bytes,6,opt,name=body,proto3" json:"body,omitempty"`
+	Headers          map[string]string  `protobuf:"bytes,7,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Metadata         map[string]string  `protobuf:"bytes,8,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Tags             []string           `protobuf:"bytes,9,rep,name=tags,proto3" json:"tags,omitempty"`
+	Personalizations []*Personalization `protobuf:"bytes,10,rep,name=personalizations,proto3" json:"personalizations,omitempty"`
+	// Template, if provided, is rendered with template data
+	// to compose subject and body.
+	Template             string            `protobuf:"bytes,11,opt,name=template,proto3" json:"template,omitempty"`
+	TemplateData         map[string]string `protobuf:"bytes,12,rep,name=template_data,json=templateData,proto3" json:"template_data,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *SendRequest) Reset()         { *m = SendRequest{} }
+func (m *SendRequest) String() string { return proto.CompactTextString(m) }
+func (*SendRequest) ProtoMessage()    {}
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{3}
+}
+
+func (m *SendRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SendRequest.Unmarshal(m, b)
+}
+func (m *SendRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SendRequest.Marshal(b, m, deterministic)
+}
+func (m *SendRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SendRequest.Merge(m, src)
+}
+func (m *SendRequest) XXX_Size() int {
+	return xxx_messageInfo_SendRequest.Size(m)
+}
+func (m *SendRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SendRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SendRequest proto.InternalMessageInfo
+
+func (m *SendRequest) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
+func (m *SendRequest) GetCc() string {
+	if m != nil {
+		return m.Cc
+	}
+	return ""
+}
+
+func (m *SendRequest) GetBcc() string {
+	if m != nil {
+		return m.Bcc
+	}
+	return ""
+}
+
+func (m *SendRequest) GetReplyTo() string {
+	if m != nil {
+		return m.ReplyTo
+	}
+	return ""
+}
+
+func (m *SendRequest) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *SendRequest) GetBody() string {
+	if m != nil {
+		return m.Body
+	}
+	return ""
+}
+
+func (m *SendRequest) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *SendRequest) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *SendRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *SendRequest) GetPersonalizations() []*Personalization {
+	if m != nil {
+		return m.Personalizations
+	}
+	return nil
+}
+
+func (m *SendRequest) GetTemplate() string {
+	if m != nil {
+		return m.Template
+	}
+	return ""
+}
+
+func (m *SendRequest) GetTemplateData() map[string]string {
+	if m != nil {
+		return m.TemplateData
+	}
+	return nil
+}
+
+type Email struct {
+	Id                   string             `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string             `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	From                 string             `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	To                   string             `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`
+	Cc                   string             `protobuf:"bytes,5,opt,name=cc,proto3" json:"cc,omitempty"`
+	Bcc                  string             `protobuf:"bytes,6,opt,name=bcc,proto3" json:"bcc,omitempty"`
+	ReplyTo              string             `protobuf:"bytes,7,opt,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	Subject              string             `protobuf:"bytes,8,opt,name=subject,proto3" json:"subject,omitempty"`
+	Body                 string             `protobuf:"bytes,9,opt,name=body,proto3" json:"body,omitempty"`
+	Charset              string             `protobuf:"bytes,10,opt,name=charset,proto3" json:"charset,omitempty"`
+	Headers              map[string]string  `protobuf:"bytes,11,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Metadata             map[string]string  `protobuf:"bytes,12,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Tags                 []string           `protobuf:"bytes,13,rep,name=tags,proto3" json:"tags,omitempty"`
+	Personalizations     []*Personalization `protobuf:"bytes,14,rep,name=personalizations,proto3" json:"personalizations,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *Email) Reset()         { *m = Email{} }
+func (m *Email) String() string { return proto.CompactTextString(m) }
+func (*Email) ProtoMessage()    {}
+func (*Email) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{4}
+}
+
+func (m *Email) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Email.Unmarshal(m, b)
+}
+func (m *Email) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Email.Marshal(b, m, deterministic)
+}
+func (m *Email) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Email.Merge(m, src)
+}
+func (m *Email) XXX_Size() int {
+	return xxx_messageInfo_Email.Size(m)
+}
+func (m *Email) XXX_DiscardUnknown() {
+	xxx_messageInfo_Email.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Email proto.InternalMessageInfo
+
+func (m *Email) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Email) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Email) GetFrom() string {
+	if m != nil {
+		return m.From
+	}
+	return ""
+}
+
+func (m *Email) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
+func (m *Email) GetCc() string {
+	if m != nil {
+		return m.Cc
+	}
+	return ""
+}
+
+func (m *Email) GetBcc() string {
+	if m != nil {
+		return m.Bcc
+	}
+	return ""
+}
+
+func (m *Email) GetReplyTo() string {
+	if m != nil {
+		return m.ReplyTo
+	}
+	return ""
+}
+
+func (m *Email) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *Email) GetBody() string {
+	if m != nil {
+		return m.Body
+	}
+	return ""
+}
+
+func (m *Email) GetCharset() string {
+	if m != nil {
+		return m.Charset
+	}
+	return ""
+}
+
+func (m *Email) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *Email) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *Email) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Email) GetPersonalizations() []*Personalization {
+	if m != nil {
+		return m.Personalizations
+	}
+	return nil
+}
+
+type SendResponse struct {
+	Email                *Email   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SendResponse) Reset()         { *m = SendResponse{} }
+func (m *SendResponse) String() string { return proto.CompactTextString(m) }
+func (*SendResponse) ProtoMessage()    {}
+func (*SendResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{5}
+}
+
+func (m *SendResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SendResponse.Unmarshal(m, b)
+}
+func (m *SendResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SendResponse.Marshal(b, m, deterministic)
+}
+func (m *SendResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SendResponse.Merge(m, src)
+}
+func (m *SendResponse) XXX_Size() int {
+	return xxx_messageInfo_SendResponse.Size(m)
+}
+func (m *SendResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SendResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SendResponse proto.InternalMessageInfo
+
+func (m *SendResponse) GetEmail() *Email {
+	if m != nil {
+		return m.Email
+	}
+	return nil
+}
+
+type GetMessageRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetMessageRequest) Reset()         { *m = GetMessageRequest{} }
+func (m *GetMessageRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMessageRequest) ProtoMessage()    {}
+func (*GetMessageRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{6}
+}
+
+func (m *GetMessageRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMessageRequest.Unmarshal(m, b)
+}
+func (m *GetMessageRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMessageRequest.Marshal(b, m, deterministic)
+}
+func (m *GetMessageRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMessageRequest.Merge(m, src)
+}
+func (m *GetMessageRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMessageRequest.Size(m)
+}
+func (m *GetMessageRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMessageRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMessageRequest proto.InternalMessageInfo
+
+func (m *GetMessageRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type MessageStatus struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Status is 'sent' or 'failed'.
+	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// Provider that delivered the message.
+	Provider string `protobuf:"bytes,3,opt,name=provider,proto3" json:"provider,omitempty"`
+	// Attempts is the number of providers tried.
+	Attempts int32  `protobuf:"varint,4,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	Error    string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	// Unix timestamps in seconds.
+	CreatedAt            int64    `protobuf:"varint,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            int64    `protobuf:"varint,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MessageStatus) Reset()         { *m = MessageStatus{} }
+func (m *MessageStatus) String() string { return proto.CompactTextString(m) }
+func (*MessageStatus) ProtoMessage()    {}
+func (*MessageStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{7}
+}
+
+func (m *MessageStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MessageStatus.Unmarshal(m, b)
+}
+func (m *MessageStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MessageStatus.Marshal(b, m, deterministic)
+}
+func (m *MessageStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MessageStatus.Merge(m, src)
+}
+func (m *MessageStatus) XXX_Size() int {
+	return xxx_messageInfo_MessageStatus.Size(m)
+}
+func (m *MessageStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_MessageStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MessageStatus proto.InternalMessageInfo
+
+func (m *MessageStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetProvider() string {
+	if m != nil {
+		return m.Provider
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetAttempts() int32 {
+	if m != nil {
+		return m.Attempts
+	}
+	return 0
+}
+
+func (m *MessageStatus) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *MessageStatus) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *MessageStatus) GetUpdatedAt() int64 {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return 0
+}
+
+type ListTemplatesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListTemplatesRequest) Reset()         { *m = ListTemplatesRequest{} }
+func (m *ListTemplatesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListTemplatesRequest) ProtoMessage()    {}
+func (*ListTemplatesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{8}
+}
+
+func (m *ListTemplatesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListTemplatesRequest.Unmarshal(m, b)
+}
+func (m *ListTemplatesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListTemplatesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListTemplatesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListTemplatesRequest.Merge(m, src)
+}
+func (m *ListTemplatesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListTemplatesRequest.Size(m)
+}
+func (m *ListTemplatesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListTemplatesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListTemplatesRequest proto.InternalMessageInfo
+
+type Template struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Subject              string   `protobuf:"bytes,2,opt,name=subject,proto3" json:"subject,omitempty"`
+	Body                 string   `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Template) Reset()         { *m = Template{} }
+func (m *Template) String() string { return proto.CompactTextString(m) }
+func (*Template) ProtoMessage()    {}
+func (*Template) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{9}
+}
+
+func (m *Template) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Template.Unmarshal(m, b)
+}
+func (m *Template) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Template.Marshal(b, m, deterministic)
+}
+func (m *Template) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Template.Merge(m, src)
+}
+func (m *Template) XXX_Size() int {
+	return xxx_messageInfo_Template.Size(m)
+}
+func (m *Template) XXX_DiscardUnknown() {
+	xxx_messageInfo_Template.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Template proto.InternalMessageInfo
+
+func (m *Template) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Template) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *Template) GetBody() string {
+	if m != nil {
+		return m.Body
+	}
+	return ""
+}
+
+type ListTemplatesResponse struct {
+	Templates            []*Template `protobuf:"bytes,1,rep,name=templates,proto3" json:"templates,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *ListTemplatesResponse) Reset()         { *m = ListTemplatesResponse{} }
+func (m *ListTemplatesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListTemplatesResponse) ProtoMessage()    {}
+func (*ListTemplatesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6019f6a77035a732, []int{10}
+}
+
+func (m *ListTemplatesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListTemplatesResponse.Unmarshal(m, b)
+}
+func (m *ListTemplatesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListTemplatesResponse.Marshal(b, m, deterministic)
+}
+func (m *ListTemplatesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListTemplatesResponse.Merge(m, src)
+}
+func (m *ListTemplatesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListTemplatesResponse.Size(m)
+}
+func (m *ListTemplatesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListTemplatesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListTemplatesResponse proto.InternalMessageInfo
+
+func (m *ListTemplatesResponse) GetTemplates() []*Template {
+	if m != nil {
+		return m.Templates
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*SignInRequest)(nil), "poslan.SignInRequest")
+	proto.RegisterType((*SignInResponse)(nil), "poslan.SignInResponse")
+	proto.RegisterType((*Personalization)(nil), "poslan.Personalization")
+	proto.RegisterMapType((map[string]string)(nil), "poslan.Personalization.SubstitutionsEntry")
+	proto.RegisterType((*SendRequest)(nil), "poslan.SendRequest")
+	proto.RegisterMapType((map[string]string)(nil), "poslan.SendRequest.HeadersEntry")
+	proto.RegisterMapType((map[string]string)(nil), "poslan.SendRequest.MetadataEntry")
+	proto.RegisterMapType((map[string]string)(nil), "poslan.SendRequest.TemplateDataEntry")
+	proto.RegisterType((*Email)(nil), "poslan.Email")
+	proto.RegisterMapType((map[string]string)(nil), "poslan.Email.HeadersEntry")
+	proto.RegisterMapType((map[string]string)(nil), "poslan.Email.MetadataEntry")
+	proto.RegisterType((*SendResponse)(nil), "poslan.SendResponse")
+	proto.RegisterType((*GetMessageRequest)(nil), "poslan.GetMessageRequest")
+	proto.RegisterType((*MessageStatus)(nil), "poslan.MessageStatus")
+	proto.RegisterType((*ListTemplatesRequest)(nil), "poslan.ListTemplatesRequest")
+	proto.RegisterType((*Template)(nil), "poslan.Template")
+	proto.RegisterType((*ListTemplatesResponse)(nil), "poslan.ListTemplatesResponse")
+}
+
+func init() { proto.RegisterFile("poslan.proto", fileDescriptor_6019f6a77035a732) }
+
+var fileDescriptor_6019f6a77035a732 = []byte{
+	// 831 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x96, 0xdb, 0x8e, 0xdb, 0x44,
+	0x18, 0xc7, 0x15, 0xe7, 0xe4, 0x7c, 0x89, 0x97, 0xed, 0xb0, 0x5d, 0xa6, 0x2e, 0x95, 0x16, 0x17,
+	0xaa, 0x8a, 0x8b, 0x44, 0x6c, 0x91, 0x8a, 0xb6, 0xe2, 0x50, 0x68, 0x55, 0x8a, 0xb6, 0xd2, 0xca,
+	0xe9, 0x15, 0x37, 0xd1, 0xd8, 0x1e, 0xb2, 0x26, 0x89, 0xc7, 0x78, 0xc6, 0x95, 0xc2, 0x25, 0xef,
+	0xc0, 0xa3, 0xf0, 0x04, 0xbc, 0x17, 0x42, 0x73, 0x72, 0xec, 0x1c, 0x54, 0xe5, 0xaa, 0x77, 0xf3,
+	0x1d, 0xfe, 0xe3, 0x6f, 0xbe, 0xf9, 0xcd, 0x8c, 0x61, 0x94, 0x33, 0xbe, 0x24, 0xd9, 0x38, 0x2f,
+	0x98, 0x60, 0xa8, 0xa7, 0xad, 0xe0, 0x05, 0x78, 0xd3, 0x74, 0x9e, 0xbd, 0xce, 0x42, 0xfa, 0x47,
+	0x49, 0xb9, 0x40, 0xf7, 0x61, 0x10, 0x2f, 0x53, 0x9a, 0x89, 0x59, 0x9a, 0xe0, 0xd6, 0x45, 0xeb,
+	0xf1, 0x20, 0x74, 0xb5, 0xe3, 0x75, 0x82, 0xce, 0xa1, 0xc7, 0x69, 0x5c, 0x50, 0x81, 0x1d, 0x15,
+	0x31, 0x56, 0xf0, 0x08, 0x4e, 0xec, 0x2c, 0x3c, 0x67, 0x19, 0xa7, 0xe8, 0x0c, 0xba, 0x82, 0x2d,
+	0x68, 0x66, 0xa6, 0xd0, 0x46, 0xf0, 0x4f, 0x0b, 0x3e, 0xba, 0xa1, 0x05, 0x67, 0x19, 0x59, 0xa6,
+	0x7f, 0x12, 0x91, 0xb2, 0x0c, 0x9d, 0x80, 0x23, 0x98, 0x49, 0x73, 0x04, 0x43, 0x37, 0xe0, 0xf1,
+	0x32, 0xe2, 0x22, 0x15, 0xa5, 0x8c, 0x73, 0xec, 0x5c, 0xb4, 0x1f, 0x0f, 0x2f, 0xbf, 0x1c, 0x9b,
+	0xfa, 0xb7, 0xf4, 0xe3, 0x69, 0x3d, 0xf9, 0x65, 0x26, 0x8a, 0x75, 0xd8, 0x9c, 0xc0, 0xff, 0x01,
+	0xd0, 0x6e, 0x12, 0x3a, 0x85, 0xf6, 0x82, 0xae, 0xcd, 0x87, 0xe5, 0x50, 0xd6, 0xfc, 0x8e, 0x2c,
+	0x4b, 0x6a, 0x16, 0xa7, 0x8d, 0x2b, 0xe7, 0x9b, 0x56, 0xf0, 0x5f, 0x07, 0x86, 0x53, 0x9a, 0x25,
+	0xb6, 0x49, 0xdb, 0x35, 0x9f, 0x80, 0x13, 0xc7, 0x46, 0xe6, 0xc4, 0xb1, 0x9c, 0x3b, 0x8a, 0x63,
+	0xdc, 0xd6, 0x73, 0x47, 0x71, 0x8c, 0xee, 0x81, 0x5b, 0xd0, 0x7c, 0xb9, 0x9e, 0x09, 0x86, 0x3b,
+	0xca, 0xdd, 0x57, 0xf6, 0x5b, 0x86, 0x30, 0xf4, 0x79, 0x19, 0xfd, 0x4e, 0x63, 0x81, 0xbb, 0x3a,
+	0x62, 0x4c, 0x84, 0xa0, 0x13, 0xb1, 0x64, 0x8d, 0x7b, 0xca, 0xad, 0xc6, 0xe8, 0x0a, 0xfa, 0xb7,
+	0x94, 0x24, 0xb4, 0xe0, 0xb8, 0xaf, 0x1a, 0x73, 0x61, 0x1b, 0x53, 0x2b, 0x70, 0xfc, 0xb3, 0x4e,
+	0xd1, 0xed, 0xb0, 0x02, 0xf4, 0x2d, 0xb8, 0x2b, 0x2a, 0x48, 0x42, 0x04, 0xc1, 0xae, 0x12, 0x7f,
+	0xb6, 0x4f, 0xfc, 0xc6, 0xe4, 0x68, 0x75, 0x25, 0x91, 0xe5, 0x08, 0x32, 0xe7, 0x78, 0x70, 0xd1,
+	0x96, 0xe5, 0xc8, 0x31, 0xfa, 0x09, 0x4e, 0xf3, 0xe6, 0x86, 0x70, 0x0c, 0x6a, 0xea, 0x4f, 0x0e,
+	0x6c, 0x58, 0xb8, 0x23, 0x40, 0x3e, 0xb8, 0x82, 0xae, 0xf2, 0x25, 0x11, 0x14, 0x0f, 0x35, 0x72,
+	0xd6, 0x46, 0xbf, 0x80, 0x67, 0xc7, 0x33, 0x55, 0xf8, 0x48, 0xcd, 0xfe, 0xc5, 0xbe, 0xc2, 0xdf,
+	0x9a, 0xc4, 0x17, 0x55, 0xf1, 0x23, 0x51, 0x73, 0xf9, 0x57, 0x30, 0xaa, 0x37, 0xe6, 0x18, 0x04,
+	0xfc, 0x67, 0xe0, 0x35, 0xfa, 0x72, 0x94, 0xf8, 0x7b, 0xb8, 0xb3, 0x53, 0xdb, 0x51, 0x00, 0xfe,
+	0xdd, 0x81, 0xee, 0xcb, 0x15, 0x49, 0x97, 0x12, 0xb5, 0xea, 0x60, 0x3a, 0x69, 0x22, 0x37, 0x25,
+	0x23, 0x2b, 0x2b, 0x51, 0x63, 0xe9, 0xfb, 0xad, 0x60, 0x2b, 0xc3, 0x9f, 0x1a, 0x1b, 0x64, 0x3b,
+	0x5b, 0xc8, 0x76, 0xb7, 0x91, 0xed, 0xed, 0x47, 0xb6, 0x7f, 0x10, 0x59, 0x77, 0x3f, 0xb2, 0x83,
+	0x1a, 0xb2, 0x18, 0xfa, 0xf1, 0x2d, 0x29, 0x38, 0x15, 0x18, 0x74, 0xb6, 0x31, 0xd1, 0xd7, 0x1b,
+	0x98, 0x87, 0x6a, 0x5b, 0x7d, 0xbb, 0xad, 0x6a, 0xb1, 0x07, 0x30, 0x7e, 0x5a, 0xc3, 0x58, 0xd3,
+	0x70, 0xbf, 0x29, 0x7b, 0x1f, 0xc0, 0xde, 0x7b, 0x00, 0x3e, 0x39, 0x12, 0xe0, 0x0f, 0x06, 0x56,
+	0xf0, 0x04, 0x46, 0xfa, 0x00, 0x98, 0x6b, 0xf7, 0x21, 0x74, 0xa9, 0x6c, 0x81, 0x52, 0x0f, 0x2f,
+	0xbd, 0x46, 0x5f, 0x42, 0x1d, 0x0b, 0x1e, 0xc2, 0x9d, 0x57, 0x54, 0xbc, 0xa1, 0x9c, 0x93, 0x39,
+	0xad, 0x5d, 0x69, 0x75, 0xae, 0x82, 0x7f, 0x5b, 0xe0, 0x99, 0x94, 0xa9, 0x20, 0xa2, 0xe4, 0x3b,
+	0xe4, 0xc9, 0xc7, 0x40, 0x45, 0xaa, 0xc7, 0x40, 0xe7, 0xf9, 0xe0, 0xe6, 0x05, 0x7b, 0x97, 0x26,
+	0xb4, 0x30, 0x04, 0x56, 0xb6, 0x8c, 0x11, 0x21, 0xcf, 0xa4, 0xe0, 0x8a, 0xc5, 0x6e, 0x58, 0xd9,
+	0x72, 0x95, 0xb4, 0x28, 0x58, 0x61, 0xa0, 0xd4, 0x06, 0x7a, 0x00, 0x10, 0x17, 0x94, 0x08, 0x9a,
+	0xcc, 0x88, 0x50, 0x78, 0xb6, 0xc3, 0x81, 0xf1, 0x3c, 0x17, 0x32, 0x5c, 0xe6, 0x89, 0x0d, 0xf7,
+	0x75, 0xd8, 0x78, 0x9e, 0x8b, 0xe0, 0x1c, 0xce, 0xae, 0x53, 0x2e, 0xec, 0xe1, 0xe3, 0x66, 0xb5,
+	0xc1, 0x35, 0xb8, 0xd6, 0x57, 0x9d, 0xa0, 0x56, 0xed, 0x04, 0xd5, 0x00, 0x77, 0xf6, 0x03, 0xde,
+	0xde, 0x00, 0x1e, 0xbc, 0x82, 0xbb, 0x5b, 0x5f, 0x31, 0xdb, 0x31, 0x86, 0x81, 0xbd, 0x80, 0x38,
+	0x6e, 0x29, 0xaa, 0x4e, 0xed, 0x96, 0xd8, 0xec, 0x70, 0x93, 0x72, 0xf9, 0x97, 0x03, 0xbd, 0x1b,
+	0x15, 0x46, 0x4f, 0xa1, 0xa7, 0x9f, 0x54, 0x74, 0xb7, 0xba, 0xea, 0xea, 0x0f, 0xb5, 0x7f, 0xbe,
+	0xed, 0x36, 0xdf, 0xfc, 0x0a, 0x3a, 0x12, 0x09, 0xf4, 0xf1, 0x9e, 0x1b, 0xd2, 0x3f, 0x6b, 0x3a,
+	0x8d, 0xe4, 0x3b, 0x80, 0x0d, 0x10, 0xe8, 0x9e, 0xcd, 0xd9, 0x81, 0xc4, 0xaf, 0x4a, 0x69, 0x92,
+	0x71, 0x0d, 0x5e, 0x63, 0xfd, 0xe8, 0x53, 0x9b, 0xb7, 0xaf, 0xf9, 0xfe, 0x83, 0x03, 0x51, 0x5d,
+	0xcd, 0x8f, 0x8f, 0x7e, 0xfd, 0x7c, 0x9e, 0x8a, 0xdb, 0x32, 0x1a, 0xc7, 0x6c, 0x35, 0x21, 0x49,
+	0x91, 0x92, 0x2c, 0x5f, 0x4c, 0xb4, 0x66, 0x92, 0x2f, 0xe6, 0x93, 0x3c, 0x7a, 0x96, 0x47, 0x51,
+	0x4f, 0xfd, 0xc9, 0x3c, 0xf9, 0x7f, 0x00, 0xc3, 0xef, 0xbf, 0xe8, 0xd9, 0x08, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// PoslanClient is the client API for Poslan service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PoslanClient interface {
+	// SignIn returns an auth token for client credentials.
+	SignIn(ctx context.Context, in *SignInRequest, opts ...grpc.CallOption) (*SignInResponse, error)
+	// Send delivers a message through configured providers.
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	// GetMessage returns the delivery status of a sent message.
+	GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*MessageStatus, error)
+	// ListTemplates returns available message templates.
+	ListTemplates(ctx context.Context, in *ListTemplatesRequest, opts ...grpc.CallOption) (*ListTemplatesResponse, error)
+}
+
+type poslanClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewPoslanClient(cc *grpc.ClientConn) PoslanClient {
+	return &poslanClient{cc}
+}
+
+func (c *poslanClient) SignIn(ctx context.Context, in *SignInRequest, opts ...grpc.CallOption) (*SignInResponse, error) {
+	out := new(SignInResponse)
+	err := c.cc.Invoke(ctx, "/poslan.Poslan/SignIn", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *poslanClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error) {
+	out := new(SendResponse)
+	err := c.cc.Invoke(ctx, "/poslan.Poslan/Send", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *poslanClient) GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*MessageStatus, error) {
+	out := new(MessageStatus)
+	err := c.cc.Invoke(ctx, "/poslan.Poslan/GetMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *poslanClient) ListTemplates(ctx context.Context, in *ListTemplatesRequest, opts ...grpc.CallOption) (*ListTemplatesResponse, error) {
+	out := new(ListTemplatesResponse)
+	err := c.cc.Invoke(ctx, "/poslan.Poslan/ListTemplates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PoslanServer is the server API for Poslan service.
+type PoslanServer interface {
+	// SignIn returns an auth token for client credentials.
+	SignIn(context.Context, *SignInRequest) (*SignInResponse, error)
+	// Send delivers a message through configured providers.
+	Send(context.Context, *SendRequest) (*SendResponse, error)
+	// GetMessage returns the delivery status of a sent message.
+	GetMessage(context.Context, *GetMessageRequest) (*MessageStatus, error)
+	// ListTemplates returns available message templates.
+	ListTemplates(context.Context, *ListTemplatesRequest) (*ListTemplatesResponse, error)
+}
+
+func RegisterPoslanServer(s *grpc.Server, srv PoslanServer) {
+	s.RegisterService(&_Poslan_serviceDesc, srv)
+}
+
+func _Poslan_SignIn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignInRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoslanServer).SignIn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/poslan.Poslan/SignIn",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoslanServer).SignIn(ctx, req.(*SignInRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Poslan_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoslanServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/poslan.Poslan/Send",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoslanServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Poslan_GetMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoslanServer).GetMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/poslan.Poslan/GetMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoslanServer).GetMessage(ctx, req.(*GetMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Poslan_ListTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTemplatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PoslanServer).ListTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/poslan.Poslan/ListTemplates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PoslanServer).ListTemplates(ctx, req.(*ListTemplatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Poslan_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "poslan.Poslan",
+	HandlerType: (*PoslanServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SignIn",
+			Handler:    _Poslan_SignIn_Handler,
+		},
+		{
+			MethodName: "Send",
+			Handler:    _Poslan_Send_Handler,
+		},
+		{
+			MethodName: "GetMessage",
+			Handler:    _Poslan_GetMessage_Handler,
+		},
+		{
+			MethodName: "ListTemplates",
+			Handler:    _Poslan_ListTemplates_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "poslan.proto",
+}
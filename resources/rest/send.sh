@@ -3,8 +3,8 @@
 # Vars
 HOST="localhost"
 PORT="8080"
-PATH="send"
-TOKEN=$(/usr/bin/curl -s -X POST -H 'Accept: application/json' -H 'Content-Type: application/json' --data '{"clientID":"dd74cb9cfb5a4f1cac4d","secret":"a5ee54c8a21a4c61820f88f14c30fa5b","rememberMe":false}' http://localhost:8080/signin | /Users/adrian/.nix-profile/bin/jq -r '.token')
+PATH="v1/send"
+TOKEN=$(/usr/bin/curl -s -X POST -H 'Accept: application/json' -H 'Content-Type: application/json' --data '{"clientID":"dd74cb9cfb5a4f1cac4d","secret":"a5ee54c8a21a4c61820f88f14c30fa5b"}' http://localhost:8080/v1/signin | /Users/adrian/.nix-profile/bin/jq -r '.token')
 
 # Pre
 # Curl and jq installed using nix not found if path not appropriately set
@@ -3,7 +3,7 @@
 # Vars
 HOST="10.35.240.75"
 PORT="8080"
-PATH="signin"
+PATH="v1/signin"
 
 # Pre
 # Curl and jq installed using nix not found if path not appropriately set
@@ -21,6 +21,7 @@ export PROVIDER_ENABLED_1=true
 export PROVIDER_PRIORITY_1=1
 export PROVIDER_SENDER_NAME_1=SendMailTest
 export PROVIDER_SENDER_EMAIL_1=sendmailtest@sharklasers.com
+export PROVIDER_REGION_1=eu-west-1
 # These are example values.
 # The keys are not real.
 # These kind of values should not be control versioned.